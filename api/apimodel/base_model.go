@@ -17,6 +17,15 @@ const (
 	ValidOptCreateOrUpdate = "save"
 	ValidOptList           = "query"
 	ValidOptDel            = "del"
+
+	FramePixel      = "pixel"      //图片像素坐标系
+	FrameWorld      = "world"      //地图世界坐标系(米)
+	FrameCompressed = "compressed" //压缩图片像素坐标系
+
+	// 读取接口未指定stage时统一按published处理(切片从未发布时回退为草稿)；
+	// 节点、路径列表未指定切片时跨切片查询，只能读取草稿
+	StagePublished = "published" //已发布的路径网络
+	StageDraft     = "draft"     //编辑中的草稿
 )
 
 var (
@@ -28,6 +37,26 @@ var (
 	}
 )
 
+// ValidFrame 坐标系参数是否合法，为空时按pixel处理
+func ValidFrame(frame string) bool {
+	return IsPixelFrame(frame) || frame == FrameWorld || frame == FrameCompressed
+}
+
+// IsPixelFrame 是否为数据库存储使用的原图像素坐标系，无需转换
+func IsPixelFrame(frame string) bool {
+	return frame == "" || frame == FramePixel
+}
+
+// ValidStage 数据阶段参数是否合法，为空时按默认阶段处理
+func ValidStage(stage string) bool {
+	return stage == "" || stage == StagePublished || stage == StageDraft
+}
+
+// ReadsPublished 节点、路径列表是否读取已发布快照：指定切片且未要求draft时读取已发布数据
+func ReadsPublished(stage string, infoID int) bool {
+	return stage != StageDraft && infoID > 0
+}
+
 type PaginationRequest struct {
 	PageNo   int    `json:"page_no" form:"page_no"`
 	PageSize int    `json:"page_size" form:"page_size"`
@@ -43,6 +72,12 @@ func (req MapRequest) Valid(opt string) error {
 		if req.Name == "" {
 			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "name")
 		}
+		if req.NodeWidth < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "node_width")
+		}
+		if req.NodeStart < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "node_start")
+		}
 	} else if opt == ValidOptDel {
 		if req.ID <= 0 {
 			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
@@ -0,0 +1,107 @@
+package apimodel
+
+import (
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+)
+
+type MapConnectorInfo struct {
+	ID            int     `json:"id"`
+	CreateAt      string  `json:"created_time"`
+	UpdateAt      string  `json:"updated_time"`
+	Name          string  `json:"name"`
+	MapID         int     `json:"map_id"`
+	Type          string  `json:"type"` //连接器类型：电梯/楼梯/坡道
+	FromInfoID    int     `json:"from_info_id"`
+	FromNodeID    int     `json:"from_node_id"`
+	ToInfoID      int     `json:"to_info_id"`
+	ToNodeID      int     `json:"to_node_id"`
+	Cost          float64 `json:"cost"`
+	Available     bool    `json:"available"`
+	Bidirectional bool    `json:"bidirectional"`
+}
+
+type MapConnectorRequest struct {
+	ID            int     `json:"id" uri:"id" form:"id"`
+	Name          string  `json:"name" form:"name"`
+	MapID         int     `json:"map_id" form:"map_id"`
+	Type          string  `json:"type" form:"type"` //连接器类型：电梯/楼梯/坡道
+	FromInfoID    int     `json:"from_info_id"`     //起始地图切片id
+	FromNodeID    int     `json:"from_node_id"`     //起始切片上的节点id
+	ToInfoID      int     `json:"to_info_id"`       //目标地图切片id
+	ToNodeID      int     `json:"to_node_id"`       //目标切片上的节点id
+	Cost          float64 `json:"cost"`             //通行代价，与路径长度(像素)同量纲
+	Available     *bool   `json:"available"`        //是否可用，默认可用
+	Bidirectional *bool   `json:"bidirectional"`    //是否双向通行，默认双向
+	PaginationRequest
+	EditContext
+}
+
+type MapConnectorResponse struct {
+	List []MapConnectorInfo `json:"list"`
+	PaginationResponse
+}
+
+func (m *MapConnectorInfo) Load(connector model.MapConnector) {
+	m.ID = connector.ID
+	m.CreateAt = connector.CreatedAt.String()
+	m.UpdateAt = connector.UpdatedAt.String()
+	m.Name = connector.Name
+	m.MapID = connector.MapID
+	m.Type = connector.Type
+	m.FromInfoID = connector.FromInfoID
+	m.FromNodeID = connector.FromNodeID
+	m.ToInfoID = connector.ToInfoID
+	m.ToNodeID = connector.ToNodeID
+	m.Cost = connector.Cost
+	m.Available = connector.Available
+	m.Bidirectional = connector.Bidirectional
+}
+
+func (resp *MapConnectorResponse) Load(total int64, list []model.MapConnector) {
+	resp.List = make([]MapConnectorInfo, 0, len(list))
+	for _, v := range list {
+		info := MapConnectorInfo{}
+		info.Load(v)
+		resp.List = append(resp.List, info)
+	}
+	resp.TotalSize = int(total)
+}
+
+func (req MapConnectorRequest) Valid(opt string) error {
+	if opt == ValidOptCreateOrUpdate {
+		if req.ID < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
+		}
+		if req.Name == "" {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "name")
+		}
+		if req.MapID <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "map_id")
+		}
+		if !model.ValidConnectorType(req.Type) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "type")
+		}
+		if req.FromInfoID <= 0 || req.ToInfoID <= 0 || req.FromInfoID == req.ToInfoID {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "from_info_id/to_info_id")
+		}
+		if req.FromNodeID <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "from_node_id")
+		}
+		if req.ToNodeID <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "to_node_id")
+		}
+		if req.Cost < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "cost")
+		}
+	} else if opt == ValidOptDel {
+		if req.ID <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
+		}
+	} else {
+		orderByFields := []string{model.FieldID, model.FieldName, model.FieldMapId, model.FieldCreatedTime, model.FieldUpdatedTime}
+		return req.PaginationRequest.Valid(orderByFields)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package apimodel
+
+import (
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+)
+
+type EditLockRequest struct {
+	InfoID int `json:"info_id" uri:"info_id"`
+	EditContext
+}
+
+// EditLockInfo 切片编辑锁的持有情况
+type EditLockInfo struct {
+	InfoID     int    `json:"info_id"`
+	Locked     bool   `json:"locked"`
+	Holder     string `json:"holder"`
+	Token      string `json:"token,omitempty"` //仅获取锁时返回给持有者，后续修改请求通过X-Edit-Token请求头携带
+	AcquiredAt string `json:"acquired_time"`
+	ExpiresAt  string `json:"expires_time"`
+}
+
+func (req EditLockRequest) Valid(opt string) error {
+	if req.InfoID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+	}
+	// 获取锁需标明持有人
+	if opt == ValidOptCreateOrUpdate && req.Author == "" {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, HeaderEditAuthor)
+	}
+	return nil
+}
+
+// ValidToken 续期、释放编辑锁的参数校验
+func (req EditLockRequest) ValidToken() error {
+	if req.InfoID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+	}
+	if req.Token == "" {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, HeaderEditToken)
+	}
+	return nil
+}
@@ -3,102 +3,221 @@ package apimodel
 import (
 	"demo-gogo/database/model"
 	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils/storage"
 	"fmt"
 	"github.com/lib/pq"
 	"math"
+	"mime/multipart"
 )
 
 type MapInfo struct {
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	CreatedAt string `json:"created_time"`
-	UpdatedAt string `json:"updated_time"`
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	CreatedAt  string `json:"created_time"`
+	UpdatedAt  string `json:"updated_time"`
+	NodePrefix string `json:"node_prefix"`
+	NodeWidth  int    `json:"node_width"`
+	NodeStart  int    `json:"node_start"`
 }
 
 type MapInfoInfo struct {
-	ID             int     `json:"id"`
-	Name           string  `json:"name"`
-	CreateAt       string  `json:"created_time"`
-	UpdateAt       string  `json:"updated_time"`
-	MapID          int     `json:"map_id"`
-	MapURL         string  `json:"map_url"`
-	MapURLCompress string  `json:"map_url_compress"`
-	PointCloud     string  `json:"point_cloud"` //点云
-	Origin         float64 `json:"origin"`      //z轴起点
-	Destination    float64 `json:"destination"` //z轴终点
+	ID               int             `json:"id"`
+	Name             string          `json:"name"`
+	CreateAt         string          `json:"created_time"`
+	UpdateAt         string          `json:"updated_time"`
+	MapID            int             `json:"map_id"`
+	MapURL           string          `json:"map_url"`
+	MapURLCompress   string          `json:"map_url_compress"`
+	PointCloud       string          `json:"point_cloud"` //点云
+	Origin           float64         `json:"origin"`      //z轴起点
+	Destination      float64         `json:"destination"` //z轴终点
+	Negate           bool            `json:"negate"`
+	OccupiedThresh   *float64        `json:"occupied_thresh"` //为空表示使用默认配置
+	FreeThresh       *float64        `json:"free_thresh"`     //为空表示使用默认配置
+	AllowUnknown     bool            `json:"allow_unknown"`
+	RobotRadius      float64         `json:"robot_radius"`
+	Resolution       float64         `json:"resolution"`
+	MapOrigin        pq.Float64Array `json:"map_origin"`
+	YAxis            string          `json:"y_axis"`
+	PublishedVersion int             `json:"published_version"` //已发布版本号，0表示从未发布
+	NodePrefix       string          `json:"node_prefix"`       //楼层节点名称前缀
+	NodeSeq          int             `json:"node_seq"`          //已分配的最大节点序号
+	SnapTolerance    float64         `json:"snap_tolerance"`    //节点吸附到路径的最大距离(像素)
+	CompressScale    float64         `json:"compress_scale"`    //压缩图片与原图的坐标比例
 }
 type RouteNodesInfo struct {
-	ID       int             `json:"id"`
-	CreateAt string          `json:"created_time"`
-	UpdateAt string          `json:"updated_time"`
-	NodeName string          `json:"name"`
-	InfoID   int             `json:"info_id"`
-	Angle    float64         `json:"angle"`   //节点角度
-	Comment  string          `json:"comment"` //标签
-	Roi      pq.Float64Array `json:"roi"`     //节点坐标,[33,66]=>(x,y)
+	ID              int             `json:"id"`
+	CreateAt        string          `json:"created_time"`
+	UpdateAt        string          `json:"updated_time"`
+	NodeName        string          `json:"name"`
+	InfoID          int             `json:"info_id"`
+	Angle           float64         `json:"angle"`                      //节点角度
+	Comment         string          `json:"comment"`                    //标签
+	Roi             pq.Float64Array `json:"roi"`                        //节点坐标,[33,66]=>(x,y)
+	Type            string          `json:"type"`                       //节点类型：普通/充电桩/停靠点/取货点/放货点/等待区
+	ChargerID       string          `json:"charger_id,omitempty"`       //充电桩编号
+	ApproachHeading *float64        `json:"approach_heading,omitempty"` //充电桩进站朝向(弧度)
+	ShelfID         string          `json:"shelf_id,omitempty"`         //取放货点货架编号
+	Capacity        int             `json:"capacity,omitempty"`         //等待区容量
 }
 type MapRoutesInfo struct {
-	ID         int             `json:"id"`
-	CreateAt   string          `json:"created_time"`
-	UpdateAt   string          `json:"updated_time"`
-	RoutesName string          `json:"name"` //路径名称
-	InfoID     int             `json:"info_id"`
-	Start      string          `json:"start"`      //起点
-	End        string          `json:"end" `       //终点
-	StartToEnd string          `json:"start_end"`  //运行方向
-	EndToStart string          `json:"end_start"`  //运行方向
-	PathRole   string          `json:"path_role"`  //路径运行规则
-	StartRoi   pq.Float64Array `json:"start_roi" ` //起点坐标
-	EndRoi     pq.Float64Array `json:"end_roi"`    //终点坐标
+	ID            int                    `json:"id"`
+	CreateAt      string                 `json:"created_time"`
+	UpdateAt      string                 `json:"updated_time"`
+	RoutesName    string                 `json:"name"` //路径名称
+	InfoID        int                    `json:"info_id"`
+	Start         string                 `json:"start"`              //起点名称(仅展示)
+	End           string                 `json:"end" `               //终点名称(仅展示)
+	StartNodeID   int                    `json:"start_node_id"`      //起点节点id
+	EndNodeID     int                    `json:"end_node_id"`        //终点节点id
+	StartToEnd    string                 `json:"start_end"`          //运行方向
+	EndToStart    string                 `json:"end_start"`          //运行方向
+	PathRole      string                 `json:"path_role"`          //路径运行规则
+	Shape         string                 `json:"shape"`              //几何形状：line/polyline/bezier
+	ControlPoints pq.Float64Array        `json:"control_points"`     //中间控制点[x1,y1,x2,y2...]
+	MaxSpeed      float64                `json:"max_speed"`          //最大速度(米/秒)，0表示不限速
+	Width         float64                `json:"width"`              //通道宽度(米)，0表示未设置
+	CostFactor    float64                `json:"cost_factor"`        //通行代价系数
+	Priority      string                 `json:"priority"`           //优先级：主干/普通/支线
+	ClosedWindows model.RouteTimeWindows `json:"closed_windows"`     //不可通行的时间窗
+	StartRoi      pq.Float64Array        `json:"start_roi" `         //起点坐标
+	EndRoi        pq.Float64Array        `json:"end_roi"`            //终点坐标
+	Geometry      []pq.Float64Array      `json:"geometry,omitempty"` //采样几何，依次为起点、途经/采样点、终点
+	Length        float64                `json:"length,omitempty"`   //路径长度，与坐标同单位
 }
 
 type MapInfoRequest struct {
-	ID             int     `json:"id" uri:"id" form:"id"`
-	Name           string  `json:"name" form:"name"`
-	MapURL         string  `json:"map_url"`
-	MapURLCompress string  `json:"map_url_compress"`
-	PointCloud     string  `json:"point_cloud" gorm:"column:point_cloud"` //点云
-	MapID          int     `json:"map_id" form:"map_id"`
-	Origin         float64 `json:"origin"`      //z轴起点
-	Destination    float64 `json:"destination"` //z轴终点
+	ID             int             `json:"id" uri:"id" form:"id"`
+	Name           string          `json:"name" form:"name"`
+	MapURL         string          `json:"map_url"`
+	MapURLCompress string          `json:"map_url_compress"`                      //由服务端按map_url在后台生成，请求中的取值不生效
+	PointCloud     string          `json:"point_cloud" gorm:"column:point_cloud"` //点云
+	MapID          int             `json:"map_id" form:"map_id"`
+	DryRun         bool            `json:"-" form:"dry_run"` //删除时仅统计将级联删除的数据
+	Origin         float64         `json:"origin"`           //z轴起点
+	Destination    float64         `json:"destination"`      //z轴终点
+	Negate         bool            `json:"negate"`           //是否反转灰度
+	OccupiedThresh *float64        `json:"occupied_thresh"`  //占用概率阈值[0,1]，为空使用默认配置
+	FreeThresh     *float64        `json:"free_thresh"`      //空闲概率阈值[0,1)，为空使用默认配置
+	AllowUnknown   bool            `json:"allow_unknown"`    //是否允许路径经过未知区域
+	RobotRadius    float64         `json:"robot_radius"`     //机器人外接圆半径(像素)，不超过MaxRobotRadius
+	Resolution     float64         `json:"resolution"`       //分辨率(米/像素)
+	MapOrigin      pq.Float64Array `json:"map_origin"`       //图片左下角像素在世界坐标系下的位姿[x,y,yaw]
+	YAxis          string          `json:"y_axis"`           //像素y轴方向：down/up
+	NodePrefix     string          `json:"node_prefix"`      //楼层节点名称前缀，拼接在地图前缀之前
+	SnapTolerance  float64         `json:"snap_tolerance"`   //节点吸附到路径的最大距离(像素)，0使用默认配置
 	PaginationRequest
+	EditContext
 }
 
 type RouteNodesRequest struct {
-	ID       int             `json:"id" uri:"id" form:"id"`
-	NodeName string          `json:"name" form:"name"`
-	InfoID   int             `json:"info_id" form:"info_id"`
-	Angle    float64         `json:"angle"`   //节点角度
-	Comment  string          `json:"comment"` //标签
-	Roi      pq.Float64Array `json:"roi"`     //节点坐标,[33,66]=>(x,y)
+	ID              int             `json:"id" uri:"id" form:"id"`
+	NodeName        string          `json:"name" form:"name"`
+	InfoID          int             `json:"info_id" form:"info_id"`
+	Angle           float64         `json:"angle"`            //节点角度
+	Comment         string          `json:"comment"`          //标签
+	Roi             pq.Float64Array `json:"roi"`              //节点坐标,[33,66]=>(x,y)
+	Type            string          `json:"type" form:"type"` //节点类型，为空按普通处理；查询时按类型筛选
+	ChargerID       string          `json:"charger_id"`       //充电桩编号，充电桩必填
+	ApproachHeading *float64        `json:"approach_heading"` //充电桩进站朝向(弧度)，[-π,π]
+	ShelfID         string          `json:"shelf_id"`         //取放货点货架编号
+	Capacity        int             `json:"capacity"`         //等待区容量
+	Frame           string          `json:"-" form:"frame"`   //返回坐标系：pixel/world/compressed
+	Stage           string          `json:"-" form:"stage"`   //数据阶段：published(默认)/draft，未指定切片时只能查询draft
 	PaginationRequest
+	EditContext
 }
 
 type MapRoutesArrRequest struct {
+	InfoID int                 `json:"-"`
 	Nodes  []RouteNodesRequest `json:"nodes" form:"nodes"`
 	Routes []MapRoutesRequest  `json:"routes" form:"routes"`
+	EditContext
+}
+
+// RosMapImportRequest ROS地图导入，上传包含yaml与图片的zip包(file)，或分别上传yaml与image
+type RosMapImportRequest struct {
+	MapID int                   `form:"map_id"`
+	Name  string                `form:"name"` //为空时取yaml中图片文件名
+	File  *multipart.FileHeader `form:"file"`
+	Yaml  *multipart.FileHeader `form:"yaml"`
+	Image *multipart.FileHeader `form:"image"`
+}
+
+// RosMapBundle ROS地图导出包
+type RosMapBundle struct {
+	FileName string
+	Data     []byte
+}
+
+// MaxRobotRadius 机器人外接圆半径上限(像素)，足迹检查按半径逐像素展开，过大的半径会耗尽内存
+const MaxRobotRadius = 500
+
+type CheckRouteRequest struct {
+	Routes      []MapRoutesRequest `json:"routes"`
+	RobotRadius float64            `json:"robot_radius"` //机器人外接圆半径(像素)，0使用地图切片配置
 }
 
 type MapRequest struct {
-	ID   int    `json:"id" uri:"id" form:"id"`
-	Name string `json:"name" form:"name"`
+	ID         int    `json:"id" uri:"id" form:"id"`
+	Name       string `json:"name" form:"name"`
+	DryRun     bool   `json:"-" form:"dry_run"` //删除时仅统计将级联删除的数据
+	NodePrefix string `json:"node_prefix"`      //节点名称前缀，为空使用默认值Site
+	NodeWidth  int    `json:"node_width"`       //节点序号最小位数，0使用默认值4
+	NodeStart  int    `json:"node_start"`       //节点序号起始值，0使用默认值1
 	PaginationRequest
 }
 
+// RenameNodeRequest 节点重命名
+type RenameNodeRequest struct {
+	ID       int    `json:"-" uri:"id"`
+	NodeName string `json:"name"`
+	EditContext
+}
+
+// CascadeCount 级联操作涉及的各类数据条数
+type CascadeCount struct {
+	Maps       int64 `json:"maps"`
+	MapInfos   int64 `json:"map_infos"`
+	Nodes      int64 `json:"nodes"`
+	Routes     int64 `json:"routes"`
+	Connectors int64 `json:"connectors"`
+	Zones      int64 `json:"zones"`
+}
+
+// CascadeDeleteResponse 级联删除(或dry_run预览)涉及的各类数据条数
+type CascadeDeleteResponse struct {
+	DryRun bool `json:"dry_run"`
+	CascadeCount
+}
+
 type BatchDeleteNodes struct {
 	IDs []int `json:"node_ids"`
+	EditContext
 }
 
 type MapRoutesRequest struct {
-	ID         int    `json:"id" uri:"id" form:"id"`
-	RoutesName string `json:"name" form:"name"` //路径名称
-	InfoID     int    `json:"info_id" form:"info_id"`
-	PathRole   string `json:"path_role"`                         //路径运行规则
-	Start      string `json:"start"`                             //起点
-	End        string `json:"end" `                              //终点
-	StartToEnd string `json:"start_end" gorm:"column:start_end"` //运行方向
-	EndToStart string `json:"end_start" gorm:"column:end_start"` //运行方向
+	ID            int                    `json:"id" uri:"id" form:"id"`
+	RoutesName    string                 `json:"name" form:"name"` //路径名称
+	InfoID        int                    `json:"info_id" form:"info_id"`
+	PathRole      string                 `json:"path_role"`                         //路径运行规则：双向/单向正向/单向反向
+	Start         string                 `json:"start"`                             //起点名称，未指定节点id时在切片内按名称解析
+	End           string                 `json:"end" `                              //终点名称，未指定节点id时在切片内按名称解析
+	StartNodeID   int                    `json:"start_node_id"`                     //起点节点id
+	EndNodeID     int                    `json:"end_node_id"`                       //终点节点id
+	Shape         string                 `json:"shape"`                             //几何形状：line/polyline/bezier，为空按直线处理
+	ControlPoints pq.Float64Array        `json:"control_points"`                    //中间控制点[x1,y1,x2,y2...]：折线为途经点，贝塞尔曲线为两个控制点
+	MaxSpeed      float64                `json:"max_speed"`                         //最大速度(米/秒)，0表示不限速
+	Width         float64                `json:"width"`                             //通道宽度(米)，0表示未设置
+	CostFactor    float64                `json:"cost_factor"`                       //通行代价系数，0按1处理
+	Priority      string                 `json:"priority"`                          //优先级：主干/普通/支线，为空按普通处理
+	ClosedWindows model.RouteTimeWindows `json:"closed_windows"`                    //不可通行的时间窗，如[{"start":"12:00","end":"13:00"}]
+	StartToEnd    string                 `json:"start_end" gorm:"column:start_end"` //起点至终点行驶方式：正向行走/倒车行走
+	EndToStart    string                 `json:"end_start" gorm:"column:end_start"` //终点至起点行驶方式：正向行走/倒车行走
+	Frame         string                 `json:"-" form:"frame"`                    //返回坐标系：pixel/world/compressed
+	Stage         string                 `json:"-" form:"stage"`                    //数据阶段：published(默认)/draft，未指定切片时只能查询draft
 	PaginationRequest
+	EditContext
 }
 
 func (m *MapInfoInfo) Load(mapData model.MapInfo) {
@@ -107,11 +226,24 @@ func (m *MapInfoInfo) Load(mapData model.MapInfo) {
 	m.Name = mapData.Name
 	m.CreateAt = mapData.CreatedAt.String()
 	m.UpdateAt = mapData.UpdatedAt.String()
-	m.MapURL = mapData.MapURL
-	m.MapURLCompress = mapData.MapURLCompress
+	m.MapURL = storage.URL(mapData.MapURL)
+	m.MapURLCompress = storage.URL(mapData.MapURLCompress)
 	m.Origin = mapData.Origin
 	m.Destination = mapData.Destination
-	m.PointCloud = mapData.PointCloud
+	m.PointCloud = storage.URL(mapData.PointCloud)
+	m.Negate = mapData.Negate
+	m.OccupiedThresh = mapData.OccupiedThresh
+	m.FreeThresh = mapData.FreeThresh
+	m.AllowUnknown = mapData.AllowUnknown
+	m.RobotRadius = mapData.RobotRadius
+	m.SnapTolerance = mapData.SnapTolerance
+	m.CompressScale = mapData.CompressScale
+	m.Resolution = mapData.Resolution
+	m.MapOrigin = mapData.MapOrigin
+	m.YAxis = mapData.YAxis
+	m.PublishedVersion = mapData.PublishedVersion
+	m.NodePrefix = mapData.NodePrefix
+	m.NodeSeq = mapData.NodeSeq
 }
 
 func (m *RouteNodesInfo) Load(nodeData model.MapRouteNodes) {
@@ -121,6 +253,14 @@ func (m *RouteNodesInfo) Load(nodeData model.MapRouteNodes) {
 	m.Angle = nodeData.Angle
 	m.Comment = nodeData.Comment
 	m.Roi = nodeData.Roi
+	m.Type = nodeData.Type
+	if m.Type == "" {
+		m.Type = model.NodeTypeNormal
+	}
+	m.ChargerID = nodeData.ChargerID
+	m.ApproachHeading = nodeData.ApproachHeading
+	m.ShelfID = nodeData.ShelfID
+	m.Capacity = nodeData.Capacity
 	m.CreateAt = nodeData.CreatedAt.String()
 	m.UpdateAt = nodeData.UpdatedAt.String()
 }
@@ -134,8 +274,23 @@ func (m *MapRoutesInfo) Load(routeData model.MapRoutes) {
 	m.UpdateAt = routeData.UpdatedAt.String()
 	m.Start = routeData.Start
 	m.End = routeData.End
+	m.StartNodeID = routeData.StartNodeID
+	m.EndNodeID = routeData.EndNodeID
 	m.StartToEnd = routeData.StartToEnd
 	m.EndToStart = routeData.EndToStart
+	m.Shape = routeData.Shape
+	if m.Shape == "" {
+		m.Shape = model.RouteShapeLine
+	}
+	m.ControlPoints = routeData.ControlPoints
+	m.MaxSpeed = routeData.MaxSpeed
+	m.Width = routeData.Width
+	m.CostFactor = routeData.CostFactor
+	m.Priority = routeData.Priority
+	if m.Priority == "" {
+		m.Priority = model.RoutePriorityNormal
+	}
+	m.ClosedWindows = routeData.ClosedWindows
 	m.StartRoi = routeData.StartRoi
 	m.EndRoi = routeData.EndRoi
 }
@@ -151,6 +306,30 @@ func (req MapInfoRequest) Valid(opt string) error {
 		if req.MapID < 0 {
 			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "map_id")
 		}
+		if req.OccupiedThresh != nil && (*req.OccupiedThresh < 0 || *req.OccupiedThresh > 1) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "occupied_thresh")
+		}
+		if req.FreeThresh != nil && (*req.FreeThresh < 0 || *req.FreeThresh >= 1) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "free_thresh")
+		}
+		if req.OccupiedThresh != nil && req.FreeThresh != nil && *req.FreeThresh > *req.OccupiedThresh {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "free_thresh")
+		}
+		if req.RobotRadius < 0 || req.RobotRadius > MaxRobotRadius {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "robot_radius")
+		}
+		if req.Resolution < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "resolution")
+		}
+		if req.SnapTolerance < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "snap_tolerance")
+		}
+		if len(req.MapOrigin) != 0 && len(req.MapOrigin) != 3 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "map_origin")
+		}
+		if req.YAxis != "" && !model.ValidYAxis(req.YAxis) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "y_axis")
+		}
 	} else if opt == ValidOptDel {
 		if req.ID <= 0 {
 			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
@@ -162,6 +341,16 @@ func (req MapInfoRequest) Valid(opt string) error {
 	return nil
 }
 
+func (req RosMapImportRequest) Valid() error {
+	if req.MapID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "map_id")
+	}
+	if req.File == nil && (req.Yaml == nil || req.Image == nil) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "file")
+	}
+	return nil
+}
+
 func (req RouteNodesRequest) Valid(opt string) error {
 	if opt == ValidOptCreateOrUpdate {
 		if req.ID < 0 {
@@ -173,17 +362,69 @@ func (req RouteNodesRequest) Valid(opt string) error {
 		if req.InfoID == 0 {
 			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
 		}
+		return req.validType()
 	} else if opt == ValidOptDel {
 		if req.ID <= 0 {
 			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
 		}
 	} else {
+		if !ValidFrame(req.Frame) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "frame")
+		}
+		if req.Type != "" && !model.ValidNodeType(req.Type) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "type")
+		}
+		if !ValidStage(req.Stage) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "stage")
+		}
+		// 已发布数据按切片保存，查询时需指定切片
+		if req.Stage == StagePublished && req.InfoID <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+		}
 		orderByFields := []string{model.FieldID, model.FieldName, model.FieldInfoId, model.FieldCreatedTime, model.FieldUpdatedTime}
 		return req.PaginationRequest.Valid(orderByFields)
 	}
 	return nil
 }
 
+// validType 校验节点类型及类型专属属性，不属于该类型的属性不允许设置
+func (req RouteNodesRequest) validType() error {
+	nodeType := req.Type
+	if nodeType == "" {
+		nodeType = model.NodeTypeNormal
+	}
+	if !model.ValidNodeType(nodeType) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "type")
+	}
+	if nodeType == model.NodeTypeCharger {
+		if req.ChargerID == "" {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "charger_id")
+		}
+		if req.ApproachHeading != nil && math.Abs(*req.ApproachHeading) > math.Pi {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "approach_heading")
+		}
+	} else if req.ChargerID != "" || req.ApproachHeading != nil {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "charger_id/approach_heading")
+	}
+	if req.ShelfID != "" && nodeType != model.NodeTypePickup && nodeType != model.NodeTypeDropoff {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "shelf_id")
+	}
+	if req.Capacity < 0 || (req.Capacity > 0 && nodeType != model.NodeTypeWaiting) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "capacity")
+	}
+	return nil
+}
+
+func (req RenameNodeRequest) Valid() error {
+	if req.ID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
+	}
+	if req.NodeName == "" {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "name")
+	}
+	return nil
+}
+
 func (req MapRoutesRequest) Valid(opt string) error {
 	if opt == ValidOptCreateOrUpdate {
 		if req.ID < 0 {
@@ -195,11 +436,55 @@ func (req MapRoutesRequest) Valid(opt string) error {
 		if req.InfoID == 0 {
 			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
 		}
+		if req.StartNodeID < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "start_node_id")
+		}
+		if req.EndNodeID < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "end_node_id")
+		}
+		//运行规则为空时使用默认值
+		if req.PathRole != "" && !model.ValidPathRole(req.PathRole) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "path_role")
+		}
+		if req.StartToEnd != "" && !model.ValidDrive(req.StartToEnd) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "start_end")
+		}
+		if req.EndToStart != "" && !model.ValidDrive(req.EndToStart) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "end_start")
+		}
+		if !model.ValidRouteShape(req.Shape, req.ControlPoints) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "shape/control_points")
+		}
+		if req.MaxSpeed < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "max_speed")
+		}
+		if req.Width < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "width")
+		}
+		if req.CostFactor < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "cost_factor")
+		}
+		if req.Priority != "" && !model.ValidRoutePriority(req.Priority) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "priority")
+		}
+		if !req.ClosedWindows.Valid() {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "closed_windows")
+		}
 	} else if opt == ValidOptDel {
 		if req.ID <= 0 {
 			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
 		}
 	} else {
+		if !ValidFrame(req.Frame) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "frame")
+		}
+		if !ValidStage(req.Stage) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "stage")
+		}
+		// 已发布数据按切片保存，查询时需指定切片
+		if req.Stage == StagePublished && req.InfoID <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+		}
 		orderByFields := []string{model.FieldID, model.FieldName, model.FieldInfoId, model.FieldCreatedTime, model.FieldUpdatedTime}
 		return req.PaginationRequest.Valid(orderByFields)
 	}
@@ -212,12 +497,14 @@ type MapInfoPageResponse struct {
 }
 
 type RouteNodesResponse struct {
-	List []RouteNodesInfo `json:"list"`
+	Frame string           `json:"frame"`
+	List  []RouteNodesInfo `json:"list"`
 	PaginationResponse
 }
 
 type MapRoutesResponse struct {
-	List []MapRoutesInfo `json:"list"`
+	Frame string          `json:"frame"`
+	List  []MapRoutesInfo `json:"list"`
 	PaginationResponse
 }
 
@@ -227,8 +514,25 @@ type MapPageResponse struct {
 }
 
 type MapInfosResponse struct {
-	Nodes  []RouteNodesInfo `json:"nodes"`
-	Routes []MapRoutesInfo  `json:"routes"`
+	Frame   string           `json:"frame"`
+	Stage   string           `json:"stage"`   //实际返回的数据阶段
+	Version int              `json:"version"` //切片已发布版本号，机器人可据此判断路径网络是否更新
+	Nodes   []RouteNodesInfo `json:"nodes"`
+	Routes  []MapRoutesInfo  `json:"routes"`
+}
+
+// RouteCheckResult 单条路径的校验结果
+type RouteCheckResult struct {
+	RouteID      int    `json:"route_id"`
+	Name         string `json:"name"`
+	Pass         bool   `json:"pass"`
+	BlockedPoint []int  `json:"blocked_point"` //首个阻塞像素坐标[x,y]
+	Message      string `json:"message"`
+}
+
+type CheckRouteResponse struct {
+	Pass   bool               `json:"pass"` //全部路径是否通过
+	Routes []RouteCheckResult `json:"routes"`
 }
 
 func (m *MapInfo) Load(mapData model.Map) {
@@ -236,6 +540,9 @@ func (m *MapInfo) Load(mapData model.Map) {
 	m.Name = mapData.Name
 	m.CreatedAt = mapData.CreatedAt.String()
 	m.UpdatedAt = mapData.UpdatedAt.String()
+	m.NodePrefix = mapData.NodePrefix
+	m.NodeWidth = mapData.NodeWidth
+	m.NodeStart = mapData.NodeStart
 }
 
 func (resp *MapInfoPageResponse) Load(total int64, list []model.MapInfo) {
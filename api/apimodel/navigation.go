@@ -0,0 +1,163 @@
+package apimodel
+
+import (
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+)
+
+type PlanRouteRequest struct {
+	InfoID int    `json:"info_id"` //地图切片id
+	Start  string `json:"start"`   //起点节点名称
+	End    string `json:"end"`     //终点节点名称
+	Frame  string `json:"frame"`   //返回坐标系：pixel/world/compressed
+	Stage  string `json:"stage"`   //规划使用的数据阶段：published/draft，默认published
+	PlanOptions
+}
+
+// PlanOptions 规划时对路径的通行限制
+type PlanOptions struct {
+	DepartAt   *model.LocalTime `json:"depart_at"`   //出发时间，处于不可通行时间窗内的路径不参与规划，为空使用当前时间
+	RobotWidth float64          `json:"robot_width"` //机器人宽度(米)，通道宽度不足的路径不参与规划，0不限制
+}
+
+type PlanRouteResponse struct {
+	Frame    string           `json:"frame"`
+	Nodes    []RouteNodesInfo `json:"nodes"`     //途经节点，按行走顺序
+	RouteIDs []int            `json:"route_ids"` //途经路径id
+	Steps    []PlanStep       `json:"steps"`     //逐段行驶信息
+	Length   float64          `json:"length"`    //总长度，pixel/compressed坐标系下单位为对应图片的像素，world坐标系下单位为米
+	Cost     float64          `json:"cost"`      //总通行代价(像素量纲)，为长度按路径代价系数、优先级加权之和
+}
+
+// PlanStep 规划结果中的一段路径及其行驶方式
+type PlanStep struct {
+	RouteID  int     `json:"route_id"`
+	From     string  `json:"from"`                //本段起点名称
+	To       string  `json:"to"`                  //本段终点名称
+	Drive    string  `json:"drive"`               //行驶方式：正向行走/倒车行走
+	Length   float64 `json:"length"`              //本段长度
+	MaxSpeed float64 `json:"max_speed,omitempty"` //本段最大速度(米/秒)，不限速时省略
+	SoundOff bool    `json:"sound_off,omitempty"` //本段途经静音区，需关闭提示音
+}
+
+// PlanNearestRequest 在地图切片内从起点出发查找通行代价最小的指定类型节点，如最近的空闲充电桩。
+// 本服务不记录机器人任务，节点占用情况由调度系统随请求传入
+type PlanNearestRequest struct {
+	InfoID      int    `json:"info_id"`       //地图切片id
+	StartNodeID int    `json:"start_node_id"` //起点节点id
+	Type        string `json:"type"`          //目标节点类型：充电桩/停靠点/取货点/放货点/等待区
+	Free        bool   `json:"free"`          //仅查找未被占满的节点
+	OccupiedIDs []int  `json:"occupied_ids"`  //被未结束任务作为目标的节点id，每个任务一项，同一节点可重复
+	Frame       string `json:"frame"`         //返回坐标系：pixel/world/compressed
+	Stage       string `json:"stage"`         //规划使用的数据阶段：published/draft，默认published
+	PlanOptions
+}
+
+type PlanNearestResponse struct {
+	Target   RouteNodesInfo `json:"target"`   //找到的目标节点
+	Occupied int            `json:"occupied"` //目标节点在occupied_ids中出现的次数
+	PlanRouteResponse
+}
+
+// PlanMapRouteRequest 在整张地图(多个切片/楼层)范围内按节点id规划路径
+type PlanMapRouteRequest struct {
+	MapID       int    `json:"map_id"`
+	StartNodeID int    `json:"start_node_id"` //起点节点id
+	EndNodeID   int    `json:"end_node_id"`   //终点节点id
+	Frame       string `json:"frame"`         //返回坐标系：pixel/world/compressed
+	Stage       string `json:"stage"`         //规划使用的数据阶段：published/draft，默认published
+	PlanOptions
+}
+
+type PlanMapRouteResponse struct {
+	Frame  string    `json:"frame"`
+	Legs   []PlanLeg `json:"legs"`   //按楼层拆分的各段路径，按行走顺序
+	Length float64   `json:"length"` //各段路径长度之和，不含连接器
+	Cost   float64   `json:"cost"`   //总通行代价(像素量纲)，含连接器代价
+}
+
+// PlanLeg 单个地图切片(楼层)内的一段路径
+type PlanLeg struct {
+	InfoID    int              `json:"info_id"`
+	InfoName  string           `json:"info_name"`
+	Nodes     []RouteNodesInfo `json:"nodes"`
+	RouteIDs  []int            `json:"route_ids"`
+	Steps     []PlanStep       `json:"steps"`
+	Length    float64          `json:"length"`
+	Connector *PlanTransfer    `json:"connector,omitempty"` //离开本楼层使用的连接器，最后一段为空
+}
+
+// PlanTransfer 规划结果中经过的楼层连接器
+type PlanTransfer struct {
+	ConnectorID int     `json:"connector_id"`
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	ToInfoID    int     `json:"to_info_id"`
+	ToNode      string  `json:"to_node"`
+	Cost        float64 `json:"cost"`
+}
+
+func (req PlanRouteRequest) Valid() error {
+	if req.InfoID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+	}
+	if req.Start == "" {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "start")
+	}
+	if req.End == "" {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "end")
+	}
+	if !ValidFrame(req.Frame) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "frame")
+	}
+	if !ValidStage(req.Stage) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "stage")
+	}
+	return req.PlanOptions.Valid()
+}
+
+func (req PlanNearestRequest) Valid() error {
+	if req.InfoID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+	}
+	if req.StartNodeID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "start_node_id")
+	}
+	if !model.ValidNodeType(req.Type) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "type")
+	}
+	if !ValidFrame(req.Frame) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "frame")
+	}
+	if !ValidStage(req.Stage) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "stage")
+	}
+	return req.PlanOptions.Valid()
+}
+
+func (req PlanMapRouteRequest) Valid() error {
+	if req.MapID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "map_id")
+	}
+	if req.StartNodeID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "start_node_id")
+	}
+	if req.EndNodeID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "end_node_id")
+	}
+	if !ValidFrame(req.Frame) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "frame")
+	}
+	if !ValidStage(req.Stage) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "stage")
+	}
+	return req.PlanOptions.Valid()
+}
+
+func (opt PlanOptions) Valid() error {
+	if opt.RobotWidth < 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "robot_width")
+	}
+	return nil
+}
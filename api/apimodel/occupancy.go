@@ -0,0 +1,51 @@
+package apimodel
+
+import (
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+	"math"
+)
+
+// 点云生成的占据栅格地图图片格式
+const (
+	OccupancyFormatPNG = "png"
+	OccupancyFormatPGM = "pgm"
+)
+
+// MaxOccupancyMinPoints 栅格点数按uint16饱和计数，占用点数阈值不能超过计数上限
+const MaxOccupancyMinPoints = math.MaxUint16 - 1
+
+// OccupancyRequest 由地图切片的点云按z轴范围[origin,destination]生成占据栅格地图
+type OccupancyRequest struct {
+	InfoID     int     `json:"-" uri:"id"`
+	Resolution float64 `json:"resolution"` //栅格分辨率(米/像素)，0使用切片分辨率或默认配置
+	MinPoints  int     `json:"min_points"` //栅格内z轴范围的点数达到该值视为占用，0按1处理，不超过MaxOccupancyMinPoints
+	Format     string  `json:"format"`     //图片格式：png/pgm，为空按png处理
+	EditContext
+}
+
+// OccupancyInfo 切片的点云、z轴范围与最近一次生成任务的进度
+type OccupancyInfo struct {
+	InfoID      int             `json:"info_id"`
+	PointCloud  string          `json:"point_cloud"`
+	Origin      float64         `json:"origin"`      //z轴起点
+	Destination float64         `json:"destination"` //z轴终点
+	MapURL      string          `json:"map_url"`     //当前地图图片，生成成功后为占据栅格地图
+	Progress    *MapJobProgress `json:"progress,omitempty"`
+}
+
+func (req OccupancyRequest) Valid() error {
+	if req.InfoID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
+	}
+	if req.Resolution < 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "resolution")
+	}
+	if req.MinPoints < 0 || req.MinPoints > MaxOccupancyMinPoints {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "min_points")
+	}
+	if req.Format != "" && req.Format != OccupancyFormatPNG && req.Format != OccupancyFormatPGM {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "format")
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package apimodel
+
+import (
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+)
+
+type PublishMapInfoRequest struct {
+	InfoID int `json:"info_id" uri:"info_id"`
+	EditContext
+}
+
+type PublishMapInfoResponse struct {
+	InfoID          int          `json:"info_id"`
+	Published       bool         `json:"published"`        //是否发布成功，草稿存在error级别问题时为false
+	Version         int          `json:"version"`          //发布后切片的已发布版本号，未发布成功时为当前版本号
+	RevisionVersion int          `json:"revision_version"` //本次发布对应的修订版本号
+	Issues          []GraphIssue `json:"issues"`           //草稿校验发现的问题，发布成功时可能含warning级别问题
+}
+
+func (req PublishMapInfoRequest) Valid() error {
+	if req.InfoID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+	}
+	return nil
+}
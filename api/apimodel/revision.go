@@ -0,0 +1,162 @@
+package apimodel
+
+import (
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+)
+
+const (
+	HeaderEditAuthor = "X-Edit-Author" //修改人请求头
+	HeaderEditToken  = "X-Edit-Token"  //切片编辑锁令牌请求头
+)
+
+// EditContext 修改操作的上下文，由handler从请求头填充
+type EditContext struct {
+	Author string `json:"-" form:"-" uri:"-"` //修改人
+	Token  string `json:"-" form:"-" uri:"-"` //编辑锁令牌，切片被锁定时仅持有人可修改
+}
+
+type MapRevisionInfo struct {
+	ID         int    `json:"id"`
+	CreateAt   string `json:"created_time"`
+	InfoID     int    `json:"info_id"`
+	Version    int    `json:"version"`
+	Author     string `json:"author"`
+	Operation  string `json:"operation"`
+	Summary    string `json:"summary"`
+	NodeCount  int    `json:"node_count"`
+	RouteCount int    `json:"route_count"`
+	ZoneCount  int    `json:"zone_count"`
+}
+
+type MapRevisionRequest struct {
+	InfoID  int `json:"info_id" uri:"info_id" form:"info_id"`
+	Version int `json:"version" form:"version"` //回滚的目标版本
+	From    int `json:"from" form:"from"`       //对比的起始版本
+	To      int `json:"to" form:"to"`           //对比的目标版本
+	PaginationRequest
+	EditContext
+}
+
+type MapRevisionResponse struct {
+	List []MapRevisionInfo `json:"list"`
+	PaginationResponse
+}
+
+// NodeChange 两个版本间同一节点的变化
+type NodeChange struct {
+	ID      int                `json:"id"`
+	Name    string             `json:"name"`
+	Moved   bool               `json:"moved"` //坐标是否变化
+	Changes string             `json:"changes"`
+	Before  model.RevisionNode `json:"before"`
+	After   model.RevisionNode `json:"after"`
+}
+
+// RouteChange 两个版本间同一路径的变化
+type RouteChange struct {
+	ID      int                 `json:"id"`
+	Name    string              `json:"name"`
+	Moved   bool                `json:"moved"` //起止节点或坐标是否变化
+	Changes string              `json:"changes"`
+	Before  model.RevisionRoute `json:"before"`
+	After   model.RevisionRoute `json:"after"`
+}
+
+// ZoneChange 两个版本间同一区域的变化
+type ZoneChange struct {
+	ID      int                `json:"id"`
+	Name    string             `json:"name"`
+	Changes string             `json:"changes"`
+	Before  model.RevisionZone `json:"before"`
+	After   model.RevisionZone `json:"after"`
+}
+
+type NodeDiff struct {
+	Added   []model.RevisionNode `json:"added"`
+	Removed []model.RevisionNode `json:"removed"`
+	Changed []NodeChange         `json:"changed"`
+}
+
+type RouteDiff struct {
+	Added   []model.RevisionRoute `json:"added"`
+	Removed []model.RevisionRoute `json:"removed"`
+	Changed []RouteChange         `json:"changed"`
+}
+
+// ZoneDiff 区域差异，任一版本为不含区域的早期快照时为空
+type ZoneDiff struct {
+	Added   []model.RevisionZone `json:"added"`
+	Removed []model.RevisionZone `json:"removed"`
+	Changed []ZoneChange         `json:"changed"`
+}
+
+type MapRevisionDiffResponse struct {
+	InfoID int       `json:"info_id"`
+	From   int       `json:"from"`
+	To     int       `json:"to"`
+	Nodes  NodeDiff  `json:"nodes"`
+	Routes RouteDiff `json:"routes"`
+	Zones  ZoneDiff  `json:"zones"`
+}
+
+func (m *MapRevisionInfo) Load(revision model.MapRevision) {
+	m.ID = revision.ID
+	m.CreateAt = revision.CreatedAt.String()
+	m.InfoID = revision.InfoID
+	m.Version = revision.Version
+	m.Author = revision.Author
+	m.Operation = revision.Operation
+	m.Summary = revision.Summary
+	m.NodeCount = len(revision.Snapshot.Nodes)
+	m.RouteCount = len(revision.Snapshot.Routes)
+	m.ZoneCount = len(revision.Snapshot.Zones)
+}
+
+func (resp *MapRevisionResponse) Load(total int64, list []model.MapRevision) {
+	resp.List = make([]MapRevisionInfo, 0, len(list))
+	for _, v := range list {
+		info := MapRevisionInfo{}
+		info.Load(v)
+		resp.List = append(resp.List, info)
+	}
+	resp.TotalSize = int(total)
+}
+
+// Summary 变更摘要
+func (resp MapRevisionDiffResponse) Summary() string {
+	return fmt.Sprintf("节点+%d -%d ~%d，路径+%d -%d ~%d，区域+%d -%d ~%d",
+		len(resp.Nodes.Added), len(resp.Nodes.Removed), len(resp.Nodes.Changed),
+		len(resp.Routes.Added), len(resp.Routes.Removed), len(resp.Routes.Changed),
+		len(resp.Zones.Added), len(resp.Zones.Removed), len(resp.Zones.Changed))
+}
+
+func (req MapRevisionRequest) Valid(opt string) error {
+	if req.InfoID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+	}
+	if opt == ValidOptCreateOrUpdate {
+		if req.Version <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "version")
+		}
+	} else if opt == ValidOptList {
+		orderByFields := []string{model.FieldID, model.FieldVersion, model.FieldCreatedTime}
+		return req.PaginationRequest.Valid(orderByFields)
+	}
+	return nil
+}
+
+// ValidDiff 版本对比参数校验
+func (req MapRevisionRequest) ValidDiff() error {
+	if req.InfoID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+	}
+	if req.From <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "from")
+	}
+	if req.To <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "to")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package apimodel
+
+import (
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MapTileSize 瓦片边长(像素)
+const MapTileSize = 256
+
+// MapTilesRequest 生成或查询地图切片的瓦片金字塔
+type MapTilesRequest struct {
+	ID int `json:"-" uri:"id"`
+}
+
+// MapTileRequest 读取单张瓦片，y为带.png后缀的行号
+type MapTileRequest struct {
+	ID      int    `json:"-" uri:"id"`
+	Z       int    `json:"-" uri:"z"`
+	X       int    `json:"-" uri:"x"`
+	Y       string `json:"-" uri:"y"`
+	Version string `json:"-" form:"v"` //瓦片版本，与当前版本一致时允许浏览器缓存
+}
+
+// MapJobProgress 地图后台任务进度
+type MapJobProgress struct {
+	Status    string  `json:"status"`
+	Message   string  `json:"message"`
+	Total     int64   `json:"total"`     //待处理总数(瓦片数、点数等)
+	Processed int64   `json:"processed"` //已处理数
+	Ratio     float64 `json:"ratio"`
+	Result    string  `json:"result"`
+}
+
+// MapTilesInfo 地图切片的瓦片金字塔，可直接用于Leaflet的L.tileLayer(CRS.Simple)
+type MapTilesInfo struct {
+	InfoID   int             `json:"info_id"`
+	Version  string          `json:"version"`  //当前可用的瓦片版本，为空表示尚未生成
+	MaxZoom  int             `json:"max_zoom"` //最大层级，该层级为原图分辨率，每降一级宽高减半
+	Width    int             `json:"width"`    //原图宽度(像素)
+	Height   int             `json:"height"`   //原图高度(像素)
+	TileSize int             `json:"tile_size"`
+	TileURL  string          `json:"tile_url,omitempty"` //瓦片地址模板，含{z}/{x}/{y}占位符
+	Progress *MapJobProgress `json:"progress,omitempty"` //最近一次生成任务的进度
+}
+
+// MapTileData 单张瓦片的png数据
+type MapTileData struct {
+	Data    []byte
+	Version string
+}
+
+func (req MapTilesRequest) Valid() error {
+	if req.ID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
+	}
+	return nil
+}
+
+// TileY 去掉.png后缀后的行号
+func (req MapTileRequest) TileY() (int, error) {
+	return strconv.Atoi(strings.TrimSuffix(req.Y, ".png"))
+}
+
+func (req MapTileRequest) Valid() error {
+	if req.ID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
+	}
+	if req.Z < 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "z")
+	}
+	if req.X < 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "x")
+	}
+	if y, err := req.TileY(); err != nil || y < 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "y")
+	}
+	return nil
+}
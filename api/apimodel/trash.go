@@ -0,0 +1,86 @@
+package apimodel
+
+import (
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+)
+
+// 回收站数据类型
+const (
+	TrashTypeMap     = "map"      //地图
+	TrashTypeMapInfo = "map_info" //地图切片
+	TrashTypeNode    = "node"     //路径节点
+	TrashTypeRoute   = "route"    //路径
+)
+
+type TrashInfo struct {
+	ID       int    `json:"id"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	MapID    int    `json:"map_id,omitempty"`
+	InfoID   int    `json:"info_id,omitempty"`
+	CreateAt string `json:"created_time"`
+	DeleteAt string `json:"deleted_time"` //删除时间，同一次删除的数据删除时间相同
+}
+
+type TrashRequest struct {
+	Type   string `json:"type" uri:"type" form:"type"` //map/map_info/node/route
+	ID     int    `json:"id" uri:"id" form:"id"`
+	MapID  int    `json:"map_id" form:"map_id"`   //按地图筛选切片
+	InfoID int    `json:"info_id" form:"info_id"` //按切片筛选节点、路径
+	PaginationRequest
+	EditContext
+}
+
+type TrashResponse struct {
+	List []TrashInfo `json:"list"`
+	PaginationResponse
+}
+
+type PurgeTrashRequest struct {
+	RetentionDays *int `json:"retention_days"` //保留天数，为空使用配置
+	DryRun        bool `json:"dry_run"`
+}
+
+type PurgeTrashResponse struct {
+	DryRun        bool   `json:"dry_run"`
+	DeletedBefore string `json:"deleted_before"` //清理该时间之前删除的数据
+	CascadeCount
+}
+
+// ValidTrashType 回收站数据类型是否合法
+func ValidTrashType(trashType string) bool {
+	return trashType == TrashTypeMap || trashType == TrashTypeMapInfo || trashType == TrashTypeNode || trashType == TrashTypeRoute
+}
+
+func (m *TrashInfo) Load(trashType string, base model.Model, name string, mapID, infoID int) {
+	m.ID = base.ID
+	m.Type = trashType
+	m.Name = name
+	m.MapID = mapID
+	m.InfoID = infoID
+	m.CreateAt = base.CreatedAt.String()
+	m.DeleteAt = model.LocalTime(base.DeletedAt.Time).String()
+}
+
+func (req TrashRequest) Valid(opt string) error {
+	if !ValidTrashType(req.Type) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "type")
+	}
+	if opt == ValidOptList {
+		orderByFields := []string{model.FieldID, model.FieldName, model.FieldCreatedTime, model.FieldDeletedTime}
+		return req.PaginationRequest.Valid(orderByFields)
+	}
+	if req.ID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
+	}
+	return nil
+}
+
+func (req PurgeTrashRequest) Valid() error {
+	if req.RetentionDays != nil && *req.RetentionDays < 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "retention_days")
+	}
+	return nil
+}
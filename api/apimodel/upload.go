@@ -0,0 +1,104 @@
+package apimodel
+
+import (
+	"demo-gogo/httpserver/errcode"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// 上传文件的用途，上传完成后写入地图切片的对应字段
+const (
+	UploadKindMap        = "map"         //地图图片，写入map_url
+	UploadKindCompress   = "compress"    //压缩图片，写入map_url_compress
+	UploadKindPointCloud = "point_cloud" //点云，写入point_cloud
+)
+
+// UploadInitRequest 创建分片上传任务
+type UploadInitRequest struct {
+	InfoID   int    `json:"info_id"`   //上传完成后关联的地图切片id
+	Kind     string `json:"kind"`      //文件用途：map/compress/point_cloud
+	FileName string `json:"file_name"` //原始文件名，用于保留扩展名
+	FileSize int64  `json:"file_size"` //文件总大小(字节)
+	Checksum string `json:"checksum"`  //文件sha256(十六进制)，可在完成上传时再提供
+}
+
+// UploadChunkRequest 上传一个分片，offset须等于已上传大小，分片内容为请求体
+type UploadChunkRequest struct {
+	Token  string    `json:"-" uri:"token"`
+	Offset int64     `json:"-" form:"offset"`
+	Data   io.Reader `json:"-"`
+}
+
+// UploadRequest 查询、完成或取消分片上传任务
+type UploadRequest struct {
+	Token    string `json:"-" uri:"token"`
+	Checksum string `json:"checksum"` //完成上传时校验的sha256，为空时使用创建任务时提供的值
+	EditContext
+}
+
+// UploadInfo 分片上传任务的进度
+type UploadInfo struct {
+	Token      string `json:"token"`
+	InfoID     int    `json:"info_id"`
+	Kind       string `json:"kind"`
+	FileName   string `json:"file_name"`
+	FileSize   int64  `json:"file_size"`
+	UploadSize int64  `json:"upload_size"`        //已上传大小，断点续传时作为下一个分片的offset
+	ChunkSize  int    `json:"chunk_size"`         //建议的分片大小(字节)
+	ExpiresAt  string `json:"expires_time"`       //无新分片时任务的过期时间
+	FileURL    string `json:"file_url,omitempty"` //上传完成后文件的访问地址
+}
+
+// ValidUploadKind 上传文件用途是否合法
+func ValidUploadKind(kind string) bool {
+	return kind == UploadKindMap || kind == UploadKindCompress || kind == UploadKindPointCloud
+}
+
+// validChecksum sha256校验和是否为64位十六进制，为空视为合法
+func validChecksum(checksum string) bool {
+	if checksum == "" {
+		return true
+	}
+	data, err := hex.DecodeString(checksum)
+	return err == nil && len(data) == 32
+}
+
+func (req UploadInitRequest) Valid() error {
+	if req.InfoID <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+	}
+	if !ValidUploadKind(req.Kind) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "kind")
+	}
+	if req.FileName == "" {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "file_name")
+	}
+	if req.FileSize <= 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "file_size")
+	}
+	if !validChecksum(req.Checksum) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "checksum")
+	}
+	return nil
+}
+
+func (req UploadChunkRequest) Valid() error {
+	if req.Token == "" {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "token")
+	}
+	if req.Offset < 0 {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "offset")
+	}
+	return nil
+}
+
+func (req UploadRequest) Valid() error {
+	if req.Token == "" {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "token")
+	}
+	if !validChecksum(req.Checksum) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "checksum")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package apimodel
+
+// 地图切片拓扑问题类型
+const (
+	IssueDanglingRoute     = "dangling_route"      //路径起点或终点节点不存在
+	IssueSelfLoopRoute     = "self_loop_route"     //路径起点与终点相同
+	IssueDuplicateRoute    = "duplicate_route"     //同一对节点间存在多条路径(含A-B与B-A)
+	IssueOrphanNode        = "orphan_node"         //节点未被任何路径或连接器引用
+	IssueDuplicateNodeName = "duplicate_node_name" //同一切片内节点重名
+	IssueNodeMissingRoi    = "node_missing_roi"    //节点缺少坐标
+	IssueCrossingRoutes    = "crossing_routes"     //两条路径相交但无公共节点
+	IssueDisconnected      = "disconnected"        //路径网络不连通
+	IssueUnreachable       = "unreachable"         //按通行方向无法从主路径网络到达
+	IssueDeadEnd           = "dead_end"            //按通行方向驶入后无法返回主路径网络
+)
+
+// 地图切片拓扑问题级别：error阻止发布，warning仅作提示
+const (
+	IssueLevelError   = "error"
+	IssueLevelWarning = "warning"
+)
+
+// IssueLevel 问题级别。相交路径(立交)、不连通分量(独立区域)及单向不可达、不可返回的节点可能是有意设计，孤立节点不影响行驶，均只作提示
+func IssueLevel(issueType string) string {
+	switch issueType {
+	case IssueOrphanNode, IssueCrossingRoutes, IssueDisconnected, IssueUnreachable, IssueDeadEnd:
+		return IssueLevelWarning
+	default:
+		return IssueLevelError
+	}
+}
+
+type ValidateMapInfoRequest struct {
+	InfoID int  `json:"-"`
+	Fix    bool `json:"fix"` //是否自动修复可修复的问题(事务内执行)
+	EditContext
+}
+
+// GraphIssue 一个拓扑问题及涉及的路径、节点
+type GraphIssue struct {
+	Type     string `json:"type"`
+	RouteIDs []int  `json:"route_ids"`
+	NodeIDs  []int  `json:"node_ids"`
+	Message  string `json:"message"`
+	Level    string `json:"level"`   //问题级别：error/warning
+	Fixable  bool   `json:"fixable"` //是否支持自动修复
+	Fixed    bool   `json:"fixed"`   //本次是否已修复
+}
+
+type ValidateMapInfoResponse struct {
+	InfoID    int          `json:"info_id"`
+	Valid     bool         `json:"valid"`               //(修复后)是否已无任何问题
+	Issues    []GraphIssue `json:"issues"`              //校验发现的问题
+	Remaining []GraphIssue `json:"remaining,omitempty"` //自动修复后复查仍存在的问题
+}
@@ -0,0 +1,123 @@
+package apimodel
+
+import (
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+	"github.com/lib/pq"
+	"math"
+)
+
+type MapZoneInfo struct {
+	ID       int             `json:"id"`
+	CreateAt string          `json:"created_time"`
+	UpdateAt string          `json:"updated_time"`
+	Name     string          `json:"name"`
+	InfoID   int             `json:"info_id"`
+	Type     string          `json:"type"`              //区域类型：禁行区/限速区/静音区/单向区
+	Polygon  pq.Float64Array `json:"polygon"`           //多边形顶点[x1,y1,x2,y2...]
+	MaxSpeed float64         `json:"max_speed"`         //限速区最大速度(米/秒)
+	Heading  *float64        `json:"heading,omitempty"` //单向区允许的通行朝向(弧度)
+}
+
+type MapZoneRequest struct {
+	ID       int             `json:"id" uri:"id" form:"id"`
+	Name     string          `json:"name" form:"name"`
+	InfoID   int             `json:"info_id" form:"info_id"`
+	Type     string          `json:"type" form:"type"`   //区域类型：禁行区/限速区/静音区/单向区
+	Polygon  pq.Float64Array `json:"polygon"`            //多边形顶点[x1,y1,x2,y2...]，至少3个顶点，首尾自动闭合
+	MaxSpeed float64         `json:"max_speed"`          //限速区最大速度(米/秒)，限速区必填
+	Heading  *float64        `json:"heading"`            //单向区允许的通行朝向(弧度)，[-π,π]，单向区必填
+	Frame    string          `json:"frame" form:"frame"` //多边形与朝向的坐标系：pixel/world/compressed，为空按pixel处理
+	Stage    string          `json:"-" form:"stage"`     //数据阶段：published(默认)/draft，未指定切片时只能查询draft
+	PaginationRequest
+	EditContext
+}
+
+type MapZoneResponse struct {
+	Frame string        `json:"frame"`
+	List  []MapZoneInfo `json:"list"`
+	PaginationResponse
+}
+
+func (m *MapZoneInfo) Load(zone model.MapZone) {
+	m.ID = zone.ID
+	m.CreateAt = zone.CreatedAt.String()
+	m.UpdateAt = zone.UpdatedAt.String()
+	m.Name = zone.Name
+	m.InfoID = zone.InfoID
+	m.Type = zone.Type
+	m.Polygon = zone.Polygon
+	m.MaxSpeed = zone.MaxSpeed
+	m.Heading = zone.Heading
+}
+
+func (resp *MapZoneResponse) Load(total int64, list []model.MapZone) {
+	resp.Frame = FramePixel
+	resp.List = make([]MapZoneInfo, 0, len(list))
+	for _, v := range list {
+		info := MapZoneInfo{}
+		info.Load(v)
+		resp.List = append(resp.List, info)
+	}
+	resp.TotalSize = int(total)
+}
+
+func (req MapZoneRequest) Valid(opt string) error {
+	if !ValidFrame(req.Frame) {
+		return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "frame")
+	}
+	if opt == ValidOptCreateOrUpdate {
+		if req.ID < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
+		}
+		if req.Name == "" {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "name")
+		}
+		if req.InfoID <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+		}
+		if !model.ValidZoneType(req.Type) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "type")
+		}
+		if len(req.Polygon) < 6 || len(req.Polygon)%2 != 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "polygon")
+		}
+		//限速仅对限速区有效，朝向仅对单向区有效
+		if req.Type == model.ZoneSlow {
+			if req.MaxSpeed <= 0 {
+				return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "max_speed")
+			}
+		} else if req.MaxSpeed != 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "max_speed")
+		}
+		if req.Type == model.ZoneOneWay {
+			if req.Heading == nil || math.Abs(*req.Heading) > math.Pi {
+				return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "heading")
+			}
+		} else if req.Heading != nil {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "heading")
+		}
+	} else if opt == ValidOptDel {
+		if req.ID <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "id")
+		}
+	} else {
+		if req.InfoID < 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+		}
+		if req.Type != "" && !model.ValidZoneType(req.Type) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "type")
+		}
+		if !ValidStage(req.Stage) {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "stage")
+		}
+		// 已发布数据按切片保存，查询时需指定切片
+		if req.Stage == StagePublished && req.InfoID <= 0 {
+			return fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "info_id")
+		}
+		orderByFields := []string{model.FieldID, model.FieldName, model.FieldInfoId, model.FieldCreatedTime, model.FieldUpdatedTime}
+		return req.PaginationRequest.Valid(orderByFields)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+func (handler *RestHandler) CreateOrUpdateMapConnector(c *gin.Context) {
+	var req apimodel.MapConnectorRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid(apimodel.ValidOptCreateOrUpdate)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	req.EditContext = editContext(c)
+	err = handler.Operator.CreateOrUpdateMapConnector(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgCreateOrUpdate, err)
+		return
+	}
+	app.Success(c, nil)
+}
+
+func (handler *RestHandler) ListMapConnectors(c *gin.Context) {
+	req := apimodel.MapConnectorRequest{
+		PaginationRequest: apimodel.DefaultPaginationRequest,
+	}
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid(apimodel.ValidOptList)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.ListMapConnectors(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgListData, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+func (handler *RestHandler) DeleteMapConnector(c *gin.Context) {
+	var req apimodel.MapConnectorRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid(apimodel.ValidOptDel)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	req.EditContext = editContext(c)
+	err = handler.Operator.DeleteMapConnector(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgDeleteData, err)
+		return
+	}
+	app.Success(c, nil)
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// AcquireEditLock 获取地图切片编辑锁
+func (handler *RestHandler) AcquireEditLock(c *gin.Context) {
+	var req apimodel.EditLockRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	req.EditContext = editContext(c)
+	err = req.Valid(apimodel.ValidOptCreateOrUpdate)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.AcquireEditLock(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgEditLock, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// RenewEditLock 续期地图切片编辑锁
+func (handler *RestHandler) RenewEditLock(c *gin.Context) {
+	var req apimodel.EditLockRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	req.EditContext = editContext(c)
+	err = req.ValidToken()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.RenewEditLock(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgEditLock, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// ReleaseEditLock 释放地图切片编辑锁
+func (handler *RestHandler) ReleaseEditLock(c *gin.Context) {
+	var req apimodel.EditLockRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	req.EditContext = editContext(c)
+	err = req.ValidToken()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	err = handler.Operator.ReleaseEditLock(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgEditLock, err)
+		return
+	}
+	app.Success(c, nil)
+}
+
+// GetEditLock 查询地图切片编辑锁持有人
+func (handler *RestHandler) GetEditLock(c *gin.Context) {
+	var req apimodel.EditLockRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid(apimodel.ValidOptList)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.GetEditLock(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgEditLock, err)
+		return
+	}
+	app.Success(c, resp)
+}
@@ -4,6 +4,7 @@ import (
 	"demo-gogo/api/apimodel"
 	"demo-gogo/httpserver/app"
 	"demo-gogo/httpserver/errcode"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"strconv"
 )
@@ -57,17 +58,22 @@ func (handler *RestHandler) DeleteMap(c *gin.Context) {
 		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
 		return
 	}
+	err = c.ShouldBindQuery(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
 	err = req.Valid(apimodel.ValidOptDel)
 	if err != nil {
 		app.SendParameterErrorResponse(c, err.Error())
 		return
 	}
-	err = handler.Operator.DeleteMap(&req)
+	resp, err := handler.Operator.DeleteMap(&req)
 	if err != nil {
 		app.SendServerErrorResponse(c, errcode.ErrorMsgDeleteData, err)
 		return
 	}
-	app.Success(c, nil)
+	app.Success(c, resp)
 }
 
 func (handler *RestHandler) CreateOrUpdateMapInfo(c *gin.Context) {
@@ -82,6 +88,7 @@ func (handler *RestHandler) CreateOrUpdateMapInfo(c *gin.Context) {
 		app.SendServerErrorResponse(c, errcode.ErrorMsgCreateOrUpdate, err)
 		return
 	}
+	req.EditContext = editContext(c)
 	err = handler.Operator.CreateOrUpdateMapInfo(&req)
 	if err != nil {
 		app.SendServerErrorResponse(c, errcode.ErrorMsgCreateOrUpdate, err)
@@ -119,17 +126,22 @@ func (handler *RestHandler) DeleteMapInfo(c *gin.Context) {
 		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
 		return
 	}
+	err = c.ShouldBindQuery(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
 	err = req.Valid(apimodel.ValidOptDel)
 	if err != nil {
 		app.SendParameterErrorResponse(c, err.Error())
 		return
 	}
-	err = handler.Operator.DeleteMapInfo(&req)
+	resp, err := handler.Operator.DeleteMapInfo(&req)
 	if err != nil {
 		app.SendServerErrorResponse(c, errcode.ErrorMsgDeleteData, err)
 		return
 	}
-	app.Success(c, nil)
+	app.Success(c, resp)
 }
 
 func (handler *RestHandler) CreateOrUpdateNode(c *gin.Context) {
@@ -139,7 +151,7 @@ func (handler *RestHandler) CreateOrUpdateNode(c *gin.Context) {
 		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
 		return
 	}
-	infoId, _ := strconv.Atoi(c.Param("path_id"))
+	infoId, _ := strconv.Atoi(c.Param("info_id"))
 	req.InfoID = infoId
 	req.NodeName = ""
 	err = req.Valid(apimodel.ValidOptCreateOrUpdate)
@@ -148,6 +160,7 @@ func (handler *RestHandler) CreateOrUpdateNode(c *gin.Context) {
 		return
 	}
 
+	req.EditContext = editContext(c)
 	err = handler.Operator.CreateOrUpdateNode(&req)
 	if err != nil {
 		app.SendServerErrorResponse(c, errcode.ErrorMsgCreateOrUpdate, err)
@@ -190,6 +203,7 @@ func (handler *RestHandler) DeleteMapNodes(c *gin.Context) {
 		app.SendParameterErrorResponse(c, err.Error())
 		return
 	}
+	req.EditContext = editContext(c)
 	err = handler.Operator.DeleteMapNodes(&req)
 	if err != nil {
 		app.SendServerErrorResponse(c, errcode.ErrorMsgDeleteData, err)
@@ -198,6 +212,33 @@ func (handler *RestHandler) DeleteMapNodes(c *gin.Context) {
 	app.Success(c, nil)
 }
 
+// RenameMapNode 重命名节点，同步更新引用该节点的路径
+func (handler *RestHandler) RenameMapNode(c *gin.Context) {
+	var req apimodel.RenameNodeRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	req.EditContext = editContext(c)
+	err = handler.Operator.RenameMapNode(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgUpdateData, err)
+		return
+	}
+	app.Success(c, nil)
+}
+
 // CreateOrUpdateMapRoutes 接收n个点位信息，将点位按照顺序存储并生成路径
 func (handler *RestHandler) CreateOrUpdateMapRoutes(c *gin.Context) {
 	var req apimodel.MapRoutesArrRequest
@@ -207,6 +248,11 @@ func (handler *RestHandler) CreateOrUpdateMapRoutes(c *gin.Context) {
 		return
 	}
 	infoID, _ := strconv.Atoi(c.Param("info_id"))
+	if infoID <= 0 {
+		app.SendParameterErrorResponse(c, fmt.Sprintf(errcode.ErrorMsgPrefixInvalidParameter, "info_id"))
+		return
+	}
+	req.InfoID = infoID
 	for i := range req.Nodes {
 		req.Nodes[i].InfoID = infoID
 		req.Nodes[i].NodeName = ""
@@ -216,6 +262,15 @@ func (handler *RestHandler) CreateOrUpdateMapRoutes(c *gin.Context) {
 			return
 		}
 	}
+	for i := range req.Routes {
+		req.Routes[i].InfoID = infoID
+		err = req.Routes[i].Valid(apimodel.ValidOptCreateOrUpdate)
+		if err != nil {
+			app.SendParameterErrorResponse(c, err.Error())
+			return
+		}
+	}
+	req.EditContext = editContext(c)
 	err = handler.Operator.CreateOrUpdateMapRoute(&req)
 	if err != nil {
 		app.SendServerErrorResponse(c, err.Error(), err)
@@ -256,6 +311,7 @@ func (handler *RestHandler) DeleteMapRoute(c *gin.Context) {
 		app.SendParameterErrorResponse(c, err.Error())
 		return
 	}
+	req.EditContext = editContext(c)
 	err = handler.Operator.DeleteMapRoute(&req)
 	if err != nil {
 		app.SendServerErrorResponse(c, errcode.ErrorMsgDeleteData, err)
@@ -266,12 +322,20 @@ func (handler *RestHandler) DeleteMapRoute(c *gin.Context) {
 
 // CheckRoute 导航路径校验
 func (handler *RestHandler) CheckRoute(c *gin.Context) {
-	var req apimodel.MapRoutesArrRequest
+	var req apimodel.CheckRouteRequest
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
 		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
 		return
 	}
+	if len(req.Routes) == 0 {
+		app.SendParameterErrorResponse(c, fmt.Sprintf(errcode.ErrorMsgPrefixInvalidParameter, "routes"))
+		return
+	}
+	if req.RobotRadius < 0 || req.RobotRadius > apimodel.MaxRobotRadius {
+		app.SendParameterErrorResponse(c, fmt.Sprintf(errcode.ErrorMsgPrefixInvalidParameter, "robot_radius"))
+		return
+	}
 	for _, routes := range req.Routes {
 		err = routes.Valid(apimodel.ValidOptCreateOrUpdate)
 		if err != nil {
@@ -279,13 +343,12 @@ func (handler *RestHandler) CheckRoute(c *gin.Context) {
 			return
 		}
 	}
-	err = handler.Operator.CheckRoute(&req)
+	resp, err := handler.Operator.CheckRoute(&req)
 	if err != nil {
-		app.SendServerErrorResponse(c, err.Error(), err)
+		app.SendServerErrorResponse(c, errcode.ErrorMsgCheckRoute, err)
 		return
 	}
-
-	app.Success(c, nil)
+	app.Success(c, resp)
 }
 
 // ListMapInfo 获取地图切片上所有路径以及点位信息
@@ -293,10 +356,20 @@ func (handler *RestHandler) ListMapInfo(c *gin.Context) {
 	var req apimodel.RouteNodesRequest
 	infoID, _ := strconv.Atoi(c.Param("info_id"))
 	req.InfoID = infoID
+	req.Frame = c.Query("frame")
+	req.Stage = c.Query("stage")
 	if req.InfoID <= 0 {
 		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
 		return
 	}
+	if !apimodel.ValidFrame(req.Frame) {
+		app.SendParameterErrorResponse(c, fmt.Sprintf(errcode.ErrorMsgPrefixInvalidParameter, "frame"))
+		return
+	}
+	if !apimodel.ValidStage(req.Stage) {
+		app.SendParameterErrorResponse(c, fmt.Sprintf(errcode.ErrorMsgPrefixInvalidParameter, "stage"))
+		return
+	}
 	resp, err := handler.Operator.ListMapInfo(&req)
 	if err != nil {
 		app.SendServerErrorResponse(c, errcode.ErrorMsgListData, err)
@@ -317,6 +390,7 @@ func (handler *RestHandler) BatchDeleteMapNodes(c *gin.Context) {
 		return
 	}
 
+	req.EditContext = editContext(c)
 	err = handler.Operator.BatchDeleteMapNodes(&req)
 	if err != nil {
 		app.SendServerErrorResponse(c, errcode.ErrorMsgDeleteData, err)
@@ -324,3 +398,28 @@ func (handler *RestHandler) BatchDeleteMapNodes(c *gin.Context) {
 	}
 	app.Success(c, nil)
 }
+
+// ValidateMapInfo 地图切片路径网络拓扑校验，可选自动修复
+func (handler *RestHandler) ValidateMapInfo(c *gin.Context) {
+	var req apimodel.ValidateMapInfoRequest
+	// 请求体可为空，此时仅校验不修复
+	if c.Request.ContentLength > 0 {
+		err := c.ShouldBindJSON(&req)
+		if err != nil {
+			app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+			return
+		}
+	}
+	req.InfoID, _ = strconv.Atoi(c.Param("info_id"))
+	if req.InfoID <= 0 {
+		app.SendParameterErrorResponse(c, fmt.Sprintf(errcode.ErrorMsgPrefixInvalidParameter, "info_id"))
+		return
+	}
+	req.EditContext = editContext(c)
+	resp, err := handler.Operator.ValidateMapInfo(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgValidateMapInfo, err)
+		return
+	}
+	app.Success(c, resp)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// PlanRoute 地图切片内路径规划
+func (handler *RestHandler) PlanRoute(c *gin.Context) {
+	var req apimodel.PlanRouteRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.PlanRoute(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgPlanRoute, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// PlanMapRoute 跨楼层(地图切片)路径规划
+func (handler *RestHandler) PlanMapRoute(c *gin.Context) {
+	var req apimodel.PlanMapRouteRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.PlanMapRoute(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgPlanRoute, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// PlanNearestNode 查找最近的指定类型节点(如空闲充电桩)并规划路径
+func (handler *RestHandler) PlanNearestNode(c *gin.Context) {
+	var req apimodel.PlanNearestRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.PlanNearestNode(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgPlanRoute, err)
+		return
+	}
+	app.Success(c, resp)
+}
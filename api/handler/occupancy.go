@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// GenerateOccupancyMap 启动后台任务由切片点云生成占据栅格地图，请求体可省略
+func (handler *RestHandler) GenerateOccupancyMap(c *gin.Context) {
+	var req apimodel.OccupancyRequest
+	err := c.ShouldBindUri(&req)
+	if err == nil && c.Request.ContentLength > 0 {
+		err = c.ShouldBindJSON(&req)
+	}
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	req.EditContext = editContext(c)
+	resp, err := handler.Operator.GenerateOccupancyMap(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgOccupancy, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// GetOccupancyMap 查询切片点云信息与占据栅格生成进度
+func (handler *RestHandler) GetOccupancyMap(c *gin.Context) {
+	var req apimodel.OccupancyRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.GetOccupancyMap(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgListData, err)
+		return
+	}
+	app.Success(c, resp)
+}
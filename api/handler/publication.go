@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// PublishMapInfo 校验地图切片草稿并发布为新版本
+func (handler *RestHandler) PublishMapInfo(c *gin.Context) {
+	var req apimodel.PublishMapInfoRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	req.EditContext = editContext(c)
+	resp, err := handler.Operator.PublishMapInfo(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgPublishMapInfo, err)
+		return
+	}
+	app.Success(c, resp)
+}
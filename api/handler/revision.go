@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// editContext 从请求头读取修改人等修改上下文
+func editContext(c *gin.Context) apimodel.EditContext {
+	return apimodel.EditContext{
+		Author: c.GetHeader(apimodel.HeaderEditAuthor),
+		Token:  c.GetHeader(apimodel.HeaderEditToken),
+	}
+}
+
+// ListMapRevisions 地图切片修订版本列表
+func (handler *RestHandler) ListMapRevisions(c *gin.Context) {
+	req := apimodel.MapRevisionRequest{
+		PaginationRequest: apimodel.DefaultPaginationRequest,
+	}
+	req.OrderBy = model.FieldVersion
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = c.ShouldBindQuery(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid(apimodel.ValidOptList)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.ListMapRevisions(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgListData, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// DiffMapRevisions 对比地图切片的两个修订版本
+func (handler *RestHandler) DiffMapRevisions(c *gin.Context) {
+	var req apimodel.MapRevisionRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = c.ShouldBindQuery(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.ValidDiff()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.DiffMapRevisions(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgDiffRevision, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// RollbackMapRevision 地图切片回滚至指定版本
+func (handler *RestHandler) RollbackMapRevision(c *gin.Context) {
+	var req apimodel.MapRevisionRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid(apimodel.ValidOptCreateOrUpdate)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	req.EditContext = editContext(c)
+	err = handler.Operator.RollbackMapRevision(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgRollbackRevision, err)
+		return
+	}
+	app.Success(c, nil)
+}
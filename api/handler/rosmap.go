@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+)
+
+// ImportRosMap 导入ROS map_server地图(yaml+pgm)
+func (handler *RestHandler) ImportRosMap(c *gin.Context) {
+	var req apimodel.RosMapImportRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.ImportRosMap(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgImportRosMap, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// ExportRosMap 导出地图切片为ROS map_server地图包
+func (handler *RestHandler) ExportRosMap(c *gin.Context) {
+	var req apimodel.MapInfoRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid(apimodel.ValidOptDel)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.ExportRosMap(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgExportRosMap, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(resp.FileName)))
+	c.Data(http.StatusOK, "application/zip", resp.Data)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GenerateMapTiles 启动后台任务生成地图切片的瓦片金字塔
+func (handler *RestHandler) GenerateMapTiles(c *gin.Context) {
+	var req apimodel.MapTilesRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.GenerateMapTiles(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgMapTiles, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// GetMapTiles 查询瓦片金字塔信息与生成进度
+func (handler *RestHandler) GetMapTiles(c *gin.Context) {
+	var req apimodel.MapTilesRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.GetMapTiles(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgListData, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// GetMapTile 读取单张瓦片，地址携带当前版本时允许浏览器缓存
+func (handler *RestHandler) GetMapTile(c *gin.Context) {
+	var req apimodel.MapTileRequest
+	err := c.ShouldBindUri(&req)
+	if err == nil {
+		err = c.ShouldBindQuery(&req)
+	}
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.GetMapTile(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgMapTile, err)
+		return
+	}
+	if req.Version != "" && req.Version == resp.Version {
+		c.Header("Cache-Control", "public, max-age=86400")
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+	c.Data(http.StatusOK, "image/png", resp.Data)
+}
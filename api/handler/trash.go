@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+func (handler *RestHandler) ListTrash(c *gin.Context) {
+	req := apimodel.TrashRequest{
+		PaginationRequest: apimodel.DefaultPaginationRequest,
+	}
+	req.OrderBy = model.FieldDeletedTime
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid(apimodel.ValidOptList)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.ListTrash(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgListData, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+func (handler *RestHandler) RestoreTrash(c *gin.Context) {
+	var req apimodel.TrashRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid(apimodel.ValidOptCreateOrUpdate)
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	req.EditContext = editContext(c)
+	resp, err := handler.Operator.RestoreTrash(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgRestoreTrash, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+func (handler *RestHandler) PurgeTrash(c *gin.Context) {
+	var req apimodel.PurgeTrashRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.PurgeTrash(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgPurgeTrash, err)
+		return
+	}
+	app.Success(c, resp)
+}
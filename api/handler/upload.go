@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/httpserver/app"
+	"demo-gogo/httpserver/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// InitUpload 创建分片上传任务
+func (handler *RestHandler) InitUpload(c *gin.Context) {
+	var req apimodel.UploadInitRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.InitUpload(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgUpload, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// GetUpload 查询上传进度
+func (handler *RestHandler) GetUpload(c *gin.Context) {
+	var req apimodel.UploadRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	resp, err := handler.Operator.GetUpload(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgListData, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// UploadChunk 上传分片，offset通过查询参数传递，请求体为分片的原始字节
+func (handler *RestHandler) UploadChunk(c *gin.Context) {
+	var req apimodel.UploadChunkRequest
+	err := c.ShouldBindUri(&req)
+	if err == nil {
+		err = c.ShouldBindQuery(&req)
+	}
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	req.Data = c.Request.Body
+	resp, err := handler.Operator.UploadChunk(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgUpload, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// CompleteUpload 校验并完成上传，文件写入地图切片
+func (handler *RestHandler) CompleteUpload(c *gin.Context) {
+	var req apimodel.UploadRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	// 请求体可选，为空时使用创建任务时提供的校验和
+	if c.Request.ContentLength != 0 {
+		err = c.ShouldBindJSON(&req)
+		if err != nil {
+			app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+			return
+		}
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	req.EditContext = editContext(c)
+	resp, err := handler.Operator.CompleteUpload(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgUpload, err)
+		return
+	}
+	app.Success(c, resp)
+}
+
+// AbortUpload 取消上传任务
+func (handler *RestHandler) AbortUpload(c *gin.Context) {
+	var req apimodel.UploadRequest
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		app.SendParameterErrorResponse(c, errcode.ErrorMsgLoadParam)
+		return
+	}
+	err = req.Valid()
+	if err != nil {
+		app.SendParameterErrorResponse(c, err.Error())
+		return
+	}
+	err = handler.Operator.AbortUpload(&req)
+	if err != nil {
+		app.SendServerErrorResponse(c, errcode.ErrorMsgDeleteData, err)
+		return
+	}
+	app.Success(c, nil)
+}
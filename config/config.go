@@ -27,6 +27,7 @@ var DefaultConfig = Config{
 		ContextPath:        "/api",
 		UploadBasePath:     "files/any_files/",
 		UploadFileSize:     10485760,
+		UploadExpireHours:  24,
 	},
 	DB: DB{
 		Name:            "demo-gogo",
@@ -52,6 +53,7 @@ var DefaultConfig = Config{
 		Password:     "hsradmin",
 		UseSSL:       false,
 		Bucket:       "mateach",
+		SignExpires:  3600,
 	},
 	HSR: HSR{
 		IP:   "127.0.0.1",
@@ -81,6 +83,27 @@ var DefaultConfig = Config{
 	Emq: Emq{
 		Broker: "tcp://120.46.48.255:1883",
 	},
+	Map: Map{
+		OccupiedThresh:      0.65,
+		FreeThresh:          0.196,
+		RobotRadius:         0,
+		Resolution:          0.05,
+		EditLockSeconds:     300,
+		EditLockWaitSeconds: 3,
+		RobotSpeed:          1.0,
+		SnapTolerance:       4,
+		CompressMaxWidth:    2048,
+		CompressMaxHeight:   2048,
+		CompressQuality:     75,
+		OccupancyMaxSize:    10000,
+		OccupancyMaxCells:   1 << 25,
+		RosMapMaxBytes:      256 << 20,
+		RosMapMaxPixels:     1 << 26,
+		GridCacheSize:       8,
+	},
+	Trash: Trash{
+		RetentionDays: 30,
+	},
 }
 
 type Config struct {
@@ -94,6 +117,8 @@ type Config struct {
 	Match   Match   `json:"match" yaml:"match"`
 	Robot   Robot   `json:"robot" yaml:"robot"`
 	Emq     Emq     `json:"emq" yaml:"emq"`
+	Map     Map     `json:"map" yaml:"map"`
+	Trash   Trash   `json:"trash" yaml:"trash"`
 }
 
 type APP struct {
@@ -105,6 +130,7 @@ type APP struct {
 	ContextPath        string `yaml:"context_path" json:"context_path"`
 	UploadBasePath     string `yaml:"upload_base_path" json:"upload_base_path"`
 	UploadFileSize     int    `yaml:"upload_file_size" json:"upload_file_size"`
+	UploadExpireHours  int    `yaml:"upload_expire_hours" json:"upload_expire_hours"` //分片上传任务无新分片后保留的小时数
 }
 
 type DB struct {
@@ -133,6 +159,8 @@ type OSS struct {
 	Password     string `yaml:"password" json:"password"`
 	UseSSL       bool   `yaml:"use_ssl" json:"use_ssl"`
 	Bucket       string `yaml:"bucket" json:"bucket"`
+	Region       string `yaml:"region" json:"region"`             //S3签名使用的区域，为空按us-east-1处理
+	SignExpires  int    `yaml:"sign_expires" json:"sign_expires"` //S3预签名下载地址的有效期(秒)
 }
 
 type HSR struct {
@@ -169,6 +197,31 @@ type Emq struct {
 	Broker string `yaml:"broker" json:"broker"`
 }
 
+// Map 地图切片未单独配置时使用的默认参数
+type Map struct {
+	OccupiedThresh      float64 `yaml:"occupied_thresh" json:"occupied_thresh"`               //占用概率阈值
+	FreeThresh          float64 `yaml:"free_thresh" json:"free_thresh"`                       //空闲概率阈值
+	RobotRadius         float64 `yaml:"robot_radius" json:"robot_radius"`                     //机器人外接圆半径(像素)
+	Resolution          float64 `yaml:"resolution" json:"resolution"`                         //地图分辨率(米/像素)
+	EditLockSeconds     int     `yaml:"edit_lock_seconds" json:"edit_lock_seconds"`           //切片编辑锁有效期(秒)，编辑期间需在过期前续期
+	EditLockWaitSeconds int     `yaml:"edit_lock_wait_seconds" json:"edit_lock_wait_seconds"` //获取编辑锁时等待他人释放的最长时间(秒)
+	RobotSpeed          float64 `yaml:"robot_speed" json:"robot_speed"`                       //机器人默认行驶速度(米/秒)，路径未限速时用于计算限速区的通行代价
+	SnapTolerance       float64 `yaml:"snap_tolerance" json:"snap_tolerance"`                 //节点吸附到路径的默认最大距离(像素)
+	CompressMaxWidth    int     `yaml:"compress_max_width" json:"compress_max_width"`         //压缩地图图片的最大宽度(像素)
+	CompressMaxHeight   int     `yaml:"compress_max_height" json:"compress_max_height"`       //压缩地图图片的最大高度(像素)
+	CompressQuality     int     `yaml:"compress_quality" json:"compress_quality"`             //压缩地图图片的jpeg质量[1,100]
+	OccupancyMaxSize    int     `yaml:"occupancy_max_size" json:"occupancy_max_size"`         //点云生成占据栅格地图时图片的最大边长(像素)
+	OccupancyMaxCells   int     `yaml:"occupancy_max_cells" json:"occupancy_max_cells"`       //点云生成占据栅格地图时的最大栅格总数(宽x高)，每个栅格约占3字节内存
+	RosMapMaxBytes      int64   `yaml:"ros_map_max_bytes" json:"ros_map_max_bytes"`           //导入ROS地图时上传文件及zip内单个文件解压后的最大字节数
+	RosMapMaxPixels     int64   `yaml:"ros_map_max_pixels" json:"ros_map_max_pixels"`         //导入ROS地图时图片的最大像素数(宽x高)，解码前按图片头校验
+	GridCacheSize       int     `yaml:"grid_cache_size" json:"grid_cache_size"`               //路径校验缓存的已解码占用栅格数量，超出时淘汰最早缓存的栅格
+}
+
+// Trash 回收站，逻辑删除超过保留天数的数据可被永久清理
+type Trash struct {
+	RetentionDays int `yaml:"retention_days" json:"retention_days"` //保留天数
+}
+
 func InitConfig() error {
 	Conf = &DefaultConfig
 	confPath := "./conf/config.yml"
@@ -265,6 +318,21 @@ func LoadConfFromEnv(conf *Config) {
 	if fileSavePath, ok := os.LookupEnv("FILE_SAVE_PATH"); ok {
 		conf.OSS.FileSavePath = fileSavePath
 	}
+	if ossType, ok := os.LookupEnv("OSS_TYPE"); ok {
+		conf.OSS.Type = ossType
+	}
+	if ossEndpoint, ok := os.LookupEnv("OSS_ENDPOINT"); ok {
+		conf.OSS.Endpoint = ossEndpoint
+	}
+	if ossBucket, ok := os.LookupEnv("OSS_BUCKET"); ok {
+		conf.OSS.Bucket = ossBucket
+	}
+	if ossUser, ok := os.LookupEnv("OSS_USER"); ok {
+		conf.OSS.User = ossUser
+	}
+	if ossPassword, ok := os.LookupEnv("OSS_PASSWORD"); ok {
+		conf.OSS.Password = ossPassword
+	}
 	// todo 临时更新 不读环境变量
 	if fileSaveNginx, ok := os.LookupEnv("FILE_SAVE_NGINX"); ok {
 		conf.OSS.Endpoint = fileSaveNginx
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	log "github.com/wonderivan/logger"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"reflect"
 )
 
@@ -94,7 +95,7 @@ func (db *OrmDB) GetEntityForUpdate(table string, id int, entity interface{}) er
 		return errors.New("GetEntityByID [entity] Kind Must Ptr")
 	}
 	defer utils.TimeCost()(fmt.Sprintf("[%s]GetEntityForUpdate_timeCost", table))
-	if err := db.Set("gorm:query_option", "FOR UPDATE").Table(table).Where("id = ?", id).First(entity).Error; err != nil {
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Table(table).Where("id = ?", id).First(entity).Error; err != nil {
 		log.Error("[%s]GetEntityByID Error.id[%#v] err[%#v]", table, id, err)
 		return err
 	}
@@ -284,3 +285,17 @@ func (db *OrmDB) DeleteUnscopedEntityByFilter(table string, filter map[string]in
 	}
 	return nil
 }
+
+// ListUnscopedEntityByFilter 多条件查询实体，包含已逻辑删除的数据 entities是一个实体对象切片对象
+func (db *OrmDB) ListUnscopedEntityByFilter(table string, filter map[string]interface{}, params model.QueryParams, entities interface{}) error {
+	if reflect.ValueOf(entities).Kind() != reflect.Ptr {
+		return errors.New("ListUnscopedEntityByFilter [entities] Kind Must Ptr")
+	}
+	defer utils.TimeCost()(fmt.Sprintf("[%s]ListUnscopedEntityByFilter_timeCost", table))
+	tx := db.Unscoped().Table(table).Where(filter)
+	if err := ProcessQueryParams(tx, params).Find(entities).Error; err != nil {
+		log.Error("[%s]ListUnscopedEntityByFilter Error.filter[%#v] params[%#v] err[%#v]", table, filter, params, err)
+		return err
+	}
+	return nil
+}
@@ -37,6 +37,7 @@ type Database interface {
 	DeleteEntity(mode interface{}) error
 
 	DeleteUnscopedEntityByFilter(table string, filter map[string]interface{}, params model.QueryParams, mode interface{}) error
+	ListUnscopedEntityByFilter(table string, filter map[string]interface{}, params model.QueryParams, entities interface{}) error
 
 	Begin() (Database, error)
 	Commit() error
@@ -105,6 +106,98 @@ func initTable(db *gorm.DB) {
 	if err != nil {
 		log.Error("init table[%s] error.[%s]", model.TableNameMapRouteNodes, err.Error())
 	}
+	err = db.AutoMigrate(&model.MapConnector{})
+	if err != nil {
+		log.Error("init table[%s] error.[%s]", model.TableNameMapConnector, err.Error())
+	}
+	err = db.AutoMigrate(&model.MapZone{})
+	if err != nil {
+		log.Error("init table[%s] error.[%s]", model.TableNameMapZone, err.Error())
+	}
+	err = db.AutoMigrate(&model.MapRevision{})
+	if err != nil {
+		log.Error("init table[%s] error.[%s]", model.TableNameMapRevision, err.Error())
+	}
+	err = db.AutoMigrate(&model.MapPublication{})
+	if err != nil {
+		log.Error("init table[%s] error.[%s]", model.TableNameMapPublication, err.Error())
+	}
+	err = db.AutoMigrate(&model.SchemaMigration{})
+	if err != nil {
+		log.Error("init table[%s] error.[%s]", model.TableNameSchemaMigration, err.Error())
+		return
+	}
+	runMigration(db, "map_routes_node_id", migrateRouteNodeIDs)
+	runMigration(db, "map_info_thresh_null", migrateMapInfoThresh)
+}
+
+// runMigration 在事务内执行一次性数据迁移并记录迁移名称，已记录的迁移不再执行
+func runMigration(db *gorm.DB, name string, migrate func(tx *gorm.DB) error) {
+	var count int64
+	err := db.Model(&model.SchemaMigration{}).Where("name = ?", name).Count(&count).Error
+	if err != nil {
+		log.Error("migrate[%s] error.[%s]", name, err.Error())
+		return
+	}
+	if count > 0 {
+		return
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := migrate(tx); err != nil {
+			return err
+		}
+		return tx.Create(&model.SchemaMigration{Name: name}).Error
+	})
+	if err != nil {
+		log.Error("migrate[%s] error.[%s]", name, err.Error())
+		return
+	}
+	log.Info("migrate[%s] done", name)
+}
+
+// migrateRouteNodeIDs 按同一切片内的节点名称为历史路径补全起终点节点id，优先匹配未删除的节点。
+// 名称无法匹配节点的路径不参与路径规划，逐条记录告警以便人工修复
+func migrateRouteNodeIDs(db *gorm.DB) error {
+	for _, column := range [][2]string{{model.FieldStartNodeID, "start"}, {model.FieldEndNodeID, "end"}} {
+		for _, cond := range []string{" AND n.deleted_at IS NULL", ""} {
+			match := fmt.Sprintf(`FROM %[2]s n WHERE n.info_id = %[1]s.info_id AND n.name = %[1]s."%[3]s"%[4]s`,
+				model.TableNameMapRoutes, model.TableNameMapRouteNodes, column[1], cond)
+			sql := fmt.Sprintf(`UPDATE %s SET %s = (SELECT n.id %s ORDER BY n.id DESC LIMIT 1) WHERE COALESCE(%s, 0) = 0 AND EXISTS (SELECT 1 %s)`,
+				model.TableNameMapRoutes, column[0], match, column[0], match)
+			result := db.Exec(sql)
+			if result.Error != nil {
+				return fmt.Errorf("table[%s] column[%s]: %v", model.TableNameMapRoutes, column[0], result.Error)
+			}
+			if result.RowsAffected > 0 {
+				log.Info("migrate table[%s] column[%s] rows[%d]", model.TableNameMapRoutes, column[0], result.RowsAffected)
+			}
+		}
+	}
+	var unresolved []model.MapRoutes
+	err := db.Where(fmt.Sprintf("COALESCE(%s, 0) = 0 OR COALESCE(%s, 0) = 0", model.FieldStartNodeID, model.FieldEndNodeID)).
+		Find(&unresolved).Error
+	if err != nil {
+		return fmt.Errorf("table[%s] unresolved routes: %v", model.TableNameMapRoutes, err)
+	}
+	for _, route := range unresolved {
+		log.Warn("migrate table[%s] route[%d] info[%d] start[%s] end[%s] node id unresolved, skipped by planning",
+			model.TableNameMapRoutes, route.ID, route.InfoID, route.Start, route.End)
+	}
+	return nil
+}
+
+// migrateMapInfoThresh 历史数据以0表示使用默认阈值，改为空值后0可作为实际阈值
+func migrateMapInfoThresh(db *gorm.DB) error {
+	for _, column := range []string{model.FieldOccupiedThresh, model.FieldFreeThresh} {
+		result := db.Exec(fmt.Sprintf(`UPDATE %s SET %s = NULL WHERE %s = 0`, model.TableNameMapInfo, column, column))
+		if result.Error != nil {
+			return fmt.Errorf("table[%s] column[%s]: %v", model.TableNameMapInfo, column, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Info("migrate table[%s] column[%s] rows[%d]", model.TableNameMapInfo, column, result.RowsAffected)
+		}
+	}
+	return nil
 }
 
 func (db *OrmDB) Begin() (Database, error) {
@@ -24,6 +24,14 @@ const (
 	FilePath = "file_path"
 	// FileUploadSize 文件已经上传的大小
 	FileUploadSize = "upload_size"
+	// FileUploadInfoID 上传完成后关联的地图切片id
+	FileUploadInfoID = "info_id"
+	// FileUploadKind 上传文件的用途
+	FileUploadKind = "kind"
+	// FileChecksum 文件的sha256校验和
+	FileChecksum = "checksum"
+	// MapJobProgress 地图后台任务(瓦片、占据栅格等)的进度
+	MapJobProgress = "progress"
 )
 
 const (
@@ -31,6 +39,9 @@ const (
 
 	redisKeyStopLocation    = "%s:stop_compute:%s:%d"
 	redisKeyBigFileUpload   = "%s:upload:big_file:%s"
+	redisKeyMapTiles        = "%s:map:tiles:%d"
+	redisKeyMapOccupancy    = "%s:map:occupancy:%d"
+	redisKeyMapCompress     = "%s:map:compress:%d"
 	redisKeyNerfProcessData = "%s:nerf:process_data:%d"
 	redisKeyTrainNerfModel  = "%s:nerf:train:%d"
 	redisKeyNerfModelViewer = "%s:nerf:model_viewer:%d"
@@ -48,6 +59,21 @@ func GetFileUploadKey(token string) string {
 	return fmt.Sprintf(redisKeyBigFileUpload, config.Conf.APP.Name, token)
 }
 
+// GetMapTilesKey 生成地图瓦片生成任务Redis Key
+func GetMapTilesKey(infoID int) string {
+	return fmt.Sprintf(redisKeyMapTiles, config.Conf.APP.Name, infoID)
+}
+
+// GetMapOccupancyKey 生成点云转占据栅格地图任务Redis Key
+func GetMapOccupancyKey(infoID int) string {
+	return fmt.Sprintf(redisKeyMapOccupancy, config.Conf.APP.Name, infoID)
+}
+
+// GetMapCompressKey 生成地图压缩图片生成任务Redis Key
+func GetMapCompressKey(infoID int) string {
+	return fmt.Sprintf(redisKeyMapCompress, config.Conf.APP.Name, infoID)
+}
+
 func GetNerfVerifyTaskKey() string {
 	return fmt.Sprintf(redisKeyVerifyTask, config.Conf.APP.Name)
 }
@@ -114,12 +140,24 @@ const (
 	TableNameMapRoutes            = "map_routes"
 	TableNameMapRouteNodes        = "map_route_nodes"
 	TableNameMapInfo              = "map_info"
+	TableNameMapConnector         = "map_connector"
+	TableNameMapZone              = "map_zone"
+	TableNameMapRevision          = "map_revision"
+	TableNameMapPublication       = "map_publication"
+	TableNameSchemaMigration      = "schema_migration"
 
 	FieldID     = "id"
 	FieldName   = "name"
 	FieldMapId  = "map_id"
 	FieldInfoId = "info_id"
 
+	FieldType       = "type"
+	FieldAvailable  = "available"
+	FieldFromInfoID = "from_info_id"
+	FieldFromNodeID = "from_node_id"
+	FieldToInfoID   = "to_info_id"
+	FieldToNodeID   = "to_node_id"
+
 	FieldCarriageNumber = "carriage_number"
 	FieldTrainTypeID    = "train_type_id"
 	FieldCarriageName   = "carriage_name"
@@ -220,9 +258,20 @@ const (
 	FieldNerfDataGroupProcessed = "processed"
 	FieldNerfDataImageSize      = "size"
 
-	FieldNerfModelID = "nerf_model_id"
-	FieldPhotoPath   = "photo_path"
-	FieldState       = "state"
+	FieldNerfModelID      = "nerf_model_id"
+	FieldPhotoPath        = "photo_path"
+	FieldState            = "state"
+	FieldVersion          = "version"
+	FieldPublishedVersion = "published_version"
+	FieldNodeSeq          = "node_seq"
+	FieldTileVersion      = "tile_version"
+	FieldTileMaxZoom      = "tile_max_zoom"
+	FieldTileWidth        = "tile_width"
+	FieldTileHeight       = "tile_height"
+	FieldStartNodeID      = "start_node_id"
+	FieldEndNodeID        = "end_node_id"
+	FieldOccupiedThresh   = "occupied_thresh"
+	FieldFreeThresh       = "free_thresh"
 
 	FieldCreatedTime = "created_at"
 	FieldUpdatedTime = "updated_at"
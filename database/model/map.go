@@ -2,42 +2,167 @@ package model
 
 import "github.com/lib/pq"
 
+// 路径运行规则
+const (
+	PathRoleBidirectional = "双向"   //双向通行
+	PathRoleStartToEnd    = "单向正向" //仅允许起点驶向终点
+	PathRoleEndToStart    = "单向反向" //仅允许终点驶向起点
+)
+
+// 路径各方向的行驶方式
+const (
+	DriveForward  = "正向行走" //车头朝前行驶
+	DriveBackward = "倒车行走" //倒车行驶
+)
+
+// 节点类型
+const (
+	NodeTypeNormal  = "普通"
+	NodeTypeCharger = "充电桩" //充电桩，需指定充电桩编号，可指定进站朝向
+	NodeTypeDocking = "停靠点"
+	NodeTypePickup  = "取货点" //可指定货架编号
+	NodeTypeDropoff = "放货点" //可指定货架编号
+	NodeTypeWaiting = "等待区" //可指定容量
+)
+
+// 路径优先级，规划时按系数调整通行代价
+const (
+	RoutePriorityMain   = "主干" //优先通行
+	RoutePriorityNormal = "普通"
+	RoutePriorityBranch = "支线" //尽量避开
+)
+
+// 路径几何形状，控制点按[x1,y1,x2,y2...]展开存储
+const (
+	RouteShapeLine     = "line"     //起终点之间的直线段，无控制点
+	RouteShapePolyline = "polyline" //折线，控制点为依次途经的中间点
+	RouteShapeBezier   = "bezier"   //三次贝塞尔曲线，控制点为两个中间控制点
+)
+
+// 地图图片像素y轴方向
+const (
+	YAxisDown = "down" //图片行号自上而下增长(常规图片/ROS地图)
+	YAxisUp   = "up"   //图片行号自下而上增长
+)
+
+// 节点自动命名规则的默认值
+const (
+	DefaultNodePrefix = "Site" //节点名称前缀
+	DefaultNodeWidth  = 4      //序号最小位数，不足补0，超出时按实际位数
+	DefaultNodeStart  = 1      //序号起始值
+)
+
+// 跨地图切片连接器类型
+const (
+	ConnectorElevator = "电梯"
+	ConnectorStairs   = "楼梯"
+	ConnectorRamp     = "坡道"
+)
+
+// 地图切片区域类型
+const (
+	ZoneForbidden = "禁行区" //路径不得穿越
+	ZoneSlow      = "限速区" //区域内按限速计算通行代价
+	ZoneSoundOff  = "静音区" //区域内关闭提示音
+	ZoneOneWay    = "单向区" //区域内仅允许沿指定朝向通行
+)
+
 type Map struct {
 	Model
-	Name string `json:"name" gorm:"column:name"`
+	Name       string `json:"name" gorm:"column:name"`
+	NodePrefix string `json:"node_prefix" gorm:"column:node_prefix"` //节点名称前缀，为空使用默认值
+	NodeWidth  int    `json:"node_width" gorm:"column:node_width"`   //节点序号最小位数，0使用默认值
+	NodeStart  int    `json:"node_start" gorm:"column:node_start"`   //节点序号起始值，0使用默认值
 }
 
 type MapInfo struct {
 	Model
-	Name           string  `json:"name" gorm:"column:name"`
-	MapURL         string  `json:"map_url" gorm:"column:map_url"`
-	MapURLCompress string  `json:"map_url_compress" gorm:"column:map_url_compress"`
-	PointCloud     string  `json:"point_cloud" gorm:"column:point_cloud"` //点云
-	MapID          int     `json:"map_id" gorm:"column:map_id"`           //对应大路径id
-	Origin         float64 `json:"origin" gorm:"column:origin"`           //z轴起点
-	Destination    float64 `json:"destination" gorm:"column:destination"` //z轴终点
+	Name             string          `json:"name" gorm:"column:name"`
+	MapURL           string          `json:"map_url" gorm:"column:map_url"`
+	MapURLCompress   string          `json:"map_url_compress" gorm:"column:map_url_compress"`
+	PointCloud       string          `json:"point_cloud" gorm:"column:point_cloud"`             //点云
+	MapID            int             `json:"map_id" gorm:"column:map_id"`                       //对应大路径id
+	Origin           float64         `json:"origin" gorm:"column:origin"`                       //z轴起点
+	Destination      float64         `json:"destination" gorm:"column:destination"`             //z轴终点
+	Negate           bool            `json:"negate" gorm:"column:negate"`                       //是否反转灰度(同ROS map_server negate)
+	OccupiedThresh   *float64        `json:"occupied_thresh" gorm:"column:occupied_thresh"`     //占用概率阈值，为空使用默认配置
+	FreeThresh       *float64        `json:"free_thresh" gorm:"column:free_thresh"`             //空闲概率阈值，为空使用默认配置
+	AllowUnknown     bool            `json:"allow_unknown" gorm:"column:allow_unknown"`         //是否允许路径经过未知区域
+	RobotRadius      float64         `json:"robot_radius" gorm:"column:robot_radius"`           //机器人外接圆半径(像素)，0使用默认配置
+	Resolution       float64         `json:"resolution" gorm:"column:resolution"`               //分辨率(米/像素)，0使用默认配置
+	MapOrigin        pq.Float64Array `json:"map_origin" gorm:"column:map_origin;type:float8[]"` //图片左下角像素在世界坐标系下的位姿[x,y,yaw]
+	YAxis            string          `json:"y_axis" gorm:"column:y_axis"`                       //像素y轴方向：down/up，为空按down处理
+	PublishedVersion int             `json:"published_version" gorm:"column:published_version"` //已发布版本号，0表示从未发布
+	NodePrefix       string          `json:"node_prefix" gorm:"column:node_prefix"`             //楼层节点名称前缀，拼接在地图前缀之前
+	NodeSeq          int             `json:"node_seq" gorm:"column:node_seq"`                   //已分配的最大节点序号，只增不减
+	SnapTolerance    float64         `json:"snap_tolerance" gorm:"column:snap_tolerance"`       //节点吸附到路径的最大距离(像素)，0使用默认配置
+	CompressScale    float64         `json:"compress_scale" gorm:"column:compress_scale"`       //压缩图片像素坐标 = 原图像素坐标 * compress_scale，0表示无压缩图片
+	TileVersion      string          `json:"tile_version" gorm:"column:tile_version"`           //当前可用的瓦片版本，为空表示未生成瓦片
+	TileMaxZoom      int             `json:"tile_max_zoom" gorm:"column:tile_max_zoom"`         //瓦片金字塔最大层级，该层级为原图分辨率
+	TileWidth        int             `json:"tile_width" gorm:"column:tile_width"`               //生成瓦片时原图宽度(像素)
+	TileHeight       int             `json:"tile_height" gorm:"column:tile_height"`             //生成瓦片时原图高度(像素)
 }
 
 type MapRoutes struct {
 	Model
-	RoutesName string          `json:"name" gorm:"column:name"`           //路径名称
-	InfoID     int             `json:"info_id" gorm:"column:info_id"`     //对应大路径id
-	PathRole   string          `json:"path_role" gorm:"column:path_role"` //路径运行规则
-	Start      string          `json:"start" gorm:"column:start"`
-	End        string          `json:"end" gorm:"column:end"`
-	StartToEnd string          `json:"start_end" gorm:"column:start_end"` //运行方向
-	EndToStart string          `json:"end_start" gorm:"column:end_start"` //运行方向
-	StartRoi   pq.Float64Array `json:"start_roi" gorm:"column:start_roi;type:float8[]"`
-	EndRoi     pq.Float64Array `json:"end_roi" gorm:"column:end_point;type:float8[]"`
+	RoutesName    string           `json:"name" gorm:"column:name"`                                   //路径名称
+	InfoID        int              `json:"info_id" gorm:"column:info_id"`                             //对应大路径id
+	PathRole      string           `json:"path_role" gorm:"column:path_role"`                         //路径运行规则
+	Start         string           `json:"start" gorm:"column:start"`                                 //起点名称，仅用于展示
+	End           string           `json:"end" gorm:"column:end"`                                     //终点名称，仅用于展示
+	StartNodeID   int              `json:"start_node_id" gorm:"column:start_node_id;index"`           //起点节点id
+	EndNodeID     int              `json:"end_node_id" gorm:"column:end_node_id;index"`               //终点节点id
+	StartToEnd    string           `json:"start_end" gorm:"column:start_end"`                         //运行方向
+	EndToStart    string           `json:"end_start" gorm:"column:end_start"`                         //运行方向
+	Shape         string           `json:"shape" gorm:"column:shape"`                                 //几何形状：line/polyline/bezier，为空按直线处理
+	ControlPoints pq.Float64Array  `json:"control_points" gorm:"column:control_points;type:float8[]"` //中间控制点(像素)
+	MaxSpeed      float64          `json:"max_speed" gorm:"column:max_speed"`                         //最大速度(米/秒)，0表示不限速
+	Width         float64          `json:"width" gorm:"column:width"`                                 //通道宽度(米)，0表示未设置
+	CostFactor    float64          `json:"cost_factor" gorm:"column:cost_factor"`                     //通行代价系数，0按1处理
+	Priority      string           `json:"priority" gorm:"column:priority"`                           //优先级：主干/普通/支线，为空按普通处理
+	ClosedWindows RouteTimeWindows `json:"closed_windows" gorm:"column:closed_windows;type:jsonb"`    //不可通行的时间窗
+	StartRoi      pq.Float64Array  `json:"start_roi" gorm:"column:start_roi;type:float8[]"`
+	EndRoi        pq.Float64Array  `json:"end_roi" gorm:"column:end_point;type:float8[]"`
 }
 
 type MapRouteNodes struct {
 	Model
-	NodeName string          `json:"name" gorm:"column:name" ` //节点名称
-	InfoID   int             `json:"info_id" gorm:"column:info_id"`
-	Angle    float64         `json:"angle" gorm:"column:angle"`         //节点角度
-	Comment  string          `json:"comment" gorm:"column:comment"`     //标签
-	Roi      pq.Float64Array `gorm:"column:roi;type:float8[]" json:"-"` //节点坐标,[33,66]=>(x,y)
+	NodeName        string          `json:"name" gorm:"column:name" ` //节点名称
+	InfoID          int             `json:"info_id" gorm:"column:info_id"`
+	Angle           float64         `json:"angle" gorm:"column:angle"`                       //节点角度
+	Comment         string          `json:"comment" gorm:"column:comment"`                   //标签
+	Roi             pq.Float64Array `gorm:"column:roi;type:float8[]" json:"-"`               //节点坐标,[33,66]=>(x,y)
+	Type            string          `json:"type" gorm:"column:type;index;default:普通"`        //节点类型，为空按普通处理
+	ChargerID       string          `json:"charger_id" gorm:"column:charger_id"`             //充电桩编号
+	ApproachHeading *float64        `json:"approach_heading" gorm:"column:approach_heading"` //充电桩进站朝向(弧度)，为空不限制
+	ShelfID         string          `json:"shelf_id" gorm:"column:shelf_id"`                 //取放货点货架编号
+	Capacity        int             `json:"capacity" gorm:"column:capacity"`                 //等待区可同时停留的机器人数，0按1处理
+}
+
+// MapConnector 连接两个地图切片(楼层)节点的连接器，如电梯、楼梯、坡道
+type MapConnector struct {
+	Model
+	Name          string  `json:"name" gorm:"column:name"`
+	MapID         int     `json:"map_id" gorm:"column:map_id"`               //所属地图id
+	Type          string  `json:"type" gorm:"column:type"`                   //连接器类型：电梯/楼梯/坡道
+	FromInfoID    int     `json:"from_info_id" gorm:"column:from_info_id"`   //起始地图切片id
+	FromNodeID    int     `json:"from_node_id" gorm:"column:from_node_id"`   //起始切片上的节点id
+	ToInfoID      int     `json:"to_info_id" gorm:"column:to_info_id"`       //目标地图切片id
+	ToNodeID      int     `json:"to_node_id" gorm:"column:to_node_id"`       //目标切片上的节点id
+	Cost          float64 `json:"cost" gorm:"column:cost"`                   //通行代价，与路径长度(像素)同量纲
+	Available     bool    `json:"available" gorm:"column:available"`         //是否可用
+	Bidirectional bool    `json:"bidirectional" gorm:"column:bidirectional"` //是否可双向通行
+}
+
+// MapZone 地图切片上的多边形区域，如禁行区、限速区、静音区、单向区
+type MapZone struct {
+	Model
+	Name     string          `json:"name" gorm:"column:name"`
+	InfoID   int             `json:"info_id" gorm:"column:info_id;index"`         //所属地图切片id
+	Type     string          `json:"type" gorm:"column:type"`                     //区域类型：禁行区/限速区/静音区/单向区
+	Polygon  pq.Float64Array `json:"polygon" gorm:"column:polygon;type:float8[]"` //多边形顶点像素坐标[x1,y1,x2,y2...]，首尾自动闭合
+	MaxSpeed float64         `json:"max_speed" gorm:"column:max_speed"`           //限速区最大速度(米/秒)
+	Heading  *float64        `json:"heading" gorm:"column:heading"`               //单向区允许的通行朝向(像素坐标系，弧度)
 }
 
 func (m *Map) TableName() string {
@@ -53,3 +178,120 @@ func (m *MapRoutes) TableName() string {
 func (m *MapRouteNodes) TableName() string {
 	return TableNameMapRouteNodes
 }
+func (m *MapConnector) TableName() string {
+	return TableNameMapConnector
+}
+
+func (m *MapZone) TableName() string {
+	return TableNameMapZone
+}
+
+// ValidConnectorType 连接器类型是否合法
+func ValidConnectorType(connectorType string) bool {
+	return connectorType == ConnectorElevator || connectorType == ConnectorStairs || connectorType == ConnectorRamp
+}
+
+// ValidZoneType 区域类型是否合法
+func ValidZoneType(zoneType string) bool {
+	return zoneType == ZoneForbidden || zoneType == ZoneSlow || zoneType == ZoneSoundOff || zoneType == ZoneOneWay
+}
+
+// ValidYAxis 像素y轴方向是否合法
+func ValidYAxis(yAxis string) bool {
+	return yAxis == YAxisDown || yAxis == YAxisUp
+}
+
+// ValidPathRole 路径运行规则是否合法
+func ValidPathRole(role string) bool {
+	return role == PathRoleBidirectional || role == PathRoleStartToEnd || role == PathRoleEndToStart
+}
+
+// ValidRouteShape 路径几何形状与控制点是否匹配：直线无控制点，折线至少一个途经点，贝塞尔曲线恰好两个控制点
+func ValidRouteShape(shape string, points []float64) bool {
+	if len(points)%2 != 0 {
+		return false
+	}
+	switch shape {
+	case "", RouteShapeLine:
+		return len(points) == 0
+	case RouteShapePolyline:
+		return len(points) >= 2
+	case RouteShapeBezier:
+		return len(points) == 4
+	}
+	return false
+}
+
+// ValidNodeType 节点类型是否合法
+func ValidNodeType(nodeType string) bool {
+	switch nodeType {
+	case NodeTypeNormal, NodeTypeCharger, NodeTypeDocking, NodeTypePickup, NodeTypeDropoff, NodeTypeWaiting:
+		return true
+	}
+	return false
+}
+
+// NodeCapacity 节点可同时容纳的机器人数，仅等待区可配置
+func NodeCapacity(nodeType string, capacity int) int {
+	if nodeType == NodeTypeWaiting && capacity > 1 {
+		return capacity
+	}
+	return 1
+}
+
+// ValidRoutePriority 路径优先级是否合法
+func ValidRoutePriority(priority string) bool {
+	return priority == RoutePriorityMain || priority == RoutePriorityNormal || priority == RoutePriorityBranch
+}
+
+// RouteCostFactor 路径通行代价相对长度的系数，为人工代价系数与优先级系数之积
+func RouteCostFactor(costFactor float64, priority string) float64 {
+	if costFactor <= 0 {
+		costFactor = 1
+	}
+	switch priority {
+	case RoutePriorityMain:
+		return costFactor * 0.8
+	case RoutePriorityBranch:
+		return costFactor * 1.5
+	}
+	return costFactor
+}
+
+// ValidDrive 行驶方式是否合法
+func ValidDrive(drive string) bool {
+	return drive == DriveForward || drive == DriveBackward
+}
+
+// PathRoleAllowStartToEnd 是否允许由起点驶向终点，未识别的历史数据按双向处理
+func PathRoleAllowStartToEnd(role string) bool {
+	return role != PathRoleEndToStart
+}
+
+// PathRoleAllowEndToStart 是否允许由终点驶向起点，未识别的历史数据按双向处理
+func PathRoleAllowEndToStart(role string) bool {
+	return role != PathRoleStartToEnd
+}
+
+// DriveOrDefault 行驶方式为空或未识别时按正向行走处理
+func DriveOrDefault(drive string) string {
+	if ValidDrive(drive) {
+		return drive
+	}
+	return DriveForward
+}
+
+// MergePathRole 合并首尾相接(a.End == b.Start)两段路径的运行规则，合并后不可通行时返回false
+func MergePathRole(a, b string) (string, bool) {
+	forward := PathRoleAllowStartToEnd(a) && PathRoleAllowStartToEnd(b)
+	backward := PathRoleAllowEndToStart(a) && PathRoleAllowEndToStart(b)
+	switch {
+	case forward && backward:
+		return PathRoleBidirectional, true
+	case forward:
+		return PathRoleStartToEnd, true
+	case backward:
+		return PathRoleEndToStart, true
+	}
+	return "", false
+}
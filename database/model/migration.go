@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// SchemaMigration 已执行的一次性数据迁移，启动时据此跳过已完成的迁移
+type SchemaMigration struct {
+	Name      string    `json:"name" gorm:"column:name;primaryKey"` //迁移名称
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (m *SchemaMigration) TableName() string {
+	return TableNameSchemaMigration
+}
@@ -0,0 +1,15 @@
+package model
+
+// MapPublication 地图切片的发布记录，快照即机器人读取的已发布路径网络，创建后不再修改
+type MapPublication struct {
+	Model
+	InfoID          int              `json:"info_id" gorm:"column:info_id;uniqueIndex:idx_map_publication_version"` //地图切片id
+	Version         int              `json:"version" gorm:"column:version;uniqueIndex:idx_map_publication_version"` //发布版本号，切片内自1递增
+	RevisionVersion int              `json:"revision_version" gorm:"column:revision_version"`                       //发布时草稿对应的修订版本号，0表示尚无修订记录
+	Author          string           `json:"author" gorm:"column:author"`                                           //发布人
+	Snapshot        RevisionSnapshot `json:"snapshot" gorm:"column:snapshot;type:jsonb"`                            //发布的节点、路径与区域快照
+}
+
+func (m *MapPublication) TableName() string {
+	return TableNameMapPublication
+}
@@ -0,0 +1,217 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+// MapRevision 地图切片的修订版本，记录每次修改后切片全部节点、路径与区域的快照，创建后不再修改
+type MapRevision struct {
+	Model
+	InfoID    int              `json:"info_id" gorm:"column:info_id;uniqueIndex:idx_map_revision_version"` //地图切片id
+	Version   int              `json:"version" gorm:"column:version;uniqueIndex:idx_map_revision_version"` //版本号，切片内自1递增
+	Author    string           `json:"author" gorm:"column:author"`                                        //修改人
+	Operation string           `json:"operation" gorm:"column:operation"`                                  //修改操作
+	Summary   string           `json:"summary" gorm:"column:summary"`                                      //变更摘要
+	Snapshot  RevisionSnapshot `json:"snapshot" gorm:"column:snapshot;type:jsonb"`                         //节点、路径与区域快照
+}
+
+// RevisionSnapshot 地图切片节点、路径与区域的完整快照
+type RevisionSnapshot struct {
+	Nodes  []RevisionNode  `json:"nodes"`
+	Routes []RevisionRoute `json:"routes"`
+	Zones  []RevisionZone  `json:"zones"` //早期快照不含区域，为nil
+}
+
+// RevisionNode 快照中的路径节点
+type RevisionNode struct {
+	ID              int             `json:"id"`
+	Name            string          `json:"name"`
+	Angle           float64         `json:"angle"`
+	Comment         string          `json:"comment"`
+	Roi             pq.Float64Array `json:"roi"`
+	Type            string          `json:"type,omitempty"`
+	ChargerID       string          `json:"charger_id,omitempty"`
+	ApproachHeading *float64        `json:"approach_heading,omitempty"`
+	ShelfID         string          `json:"shelf_id,omitempty"`
+	Capacity        int             `json:"capacity,omitempty"`
+}
+
+// RevisionRoute 快照中的路径
+type RevisionRoute struct {
+	ID            int              `json:"id"`
+	Name          string           `json:"name"`
+	PathRole      string           `json:"path_role"`
+	Start         string           `json:"start"`
+	End           string           `json:"end"`
+	StartNodeID   int              `json:"start_node_id"`
+	EndNodeID     int              `json:"end_node_id"`
+	StartToEnd    string           `json:"start_end"`
+	EndToStart    string           `json:"end_start"`
+	Shape         string           `json:"shape,omitempty"`
+	ControlPoints pq.Float64Array  `json:"control_points,omitempty"`
+	MaxSpeed      float64          `json:"max_speed,omitempty"`
+	Width         float64          `json:"width,omitempty"`
+	CostFactor    float64          `json:"cost_factor,omitempty"`
+	Priority      string           `json:"priority,omitempty"`
+	ClosedWindows RouteTimeWindows `json:"closed_windows,omitempty"`
+	StartRoi      pq.Float64Array  `json:"start_roi"`
+	EndRoi        pq.Float64Array  `json:"end_roi"`
+}
+
+// RevisionZone 快照中的区域
+type RevisionZone struct {
+	ID       int             `json:"id"`
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Polygon  pq.Float64Array `json:"polygon"`
+	MaxSpeed float64         `json:"max_speed,omitempty"`
+	Heading  *float64        `json:"heading,omitempty"`
+}
+
+func (m *MapRevision) TableName() string {
+	return TableNameMapRevision
+}
+
+func (s RevisionSnapshot) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (s *RevisionSnapshot) Scan(v interface{}) error {
+	var err error
+	switch vt := v.(type) {
+	case []byte:
+		err = json.Unmarshal(vt, s)
+	case string:
+		err = json.Unmarshal([]byte(vt), s)
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("RevisionSnapshot不支持的数据类型[%T]", v)
+	}
+	if err != nil {
+		return err
+	}
+	s.fillRouteNodeIDs()
+	return nil
+}
+
+// fillRouteNodeIDs 早期快照的路径只记录了起终点名称，按快照内节点名称补全节点id
+func (s *RevisionSnapshot) fillRouteNodeIDs() {
+	ids := make(map[string]int, len(s.Nodes))
+	for _, v := range s.Nodes {
+		ids[v.Name] = v.ID
+	}
+	for i := range s.Routes {
+		if s.Routes[i].StartNodeID == 0 {
+			s.Routes[i].StartNodeID = ids[s.Routes[i].Start]
+		}
+		if s.Routes[i].EndNodeID == 0 {
+			s.Routes[i].EndNodeID = ids[s.Routes[i].End]
+		}
+	}
+}
+
+// NewRevisionNode 由节点生成快照
+func NewRevisionNode(node MapRouteNodes) RevisionNode {
+	return RevisionNode{
+		ID:              node.ID,
+		Name:            node.NodeName,
+		Angle:           node.Angle,
+		Comment:         node.Comment,
+		Roi:             node.Roi,
+		Type:            node.Type,
+		ChargerID:       node.ChargerID,
+		ApproachHeading: node.ApproachHeading,
+		ShelfID:         node.ShelfID,
+		Capacity:        node.Capacity,
+	}
+}
+
+// Apply 将快照内容写回节点
+func (n RevisionNode) Apply(node *MapRouteNodes) {
+	node.ID = n.ID
+	node.NodeName = n.Name
+	node.Angle = n.Angle
+	node.Comment = n.Comment
+	node.Roi = n.Roi
+	node.Type = n.Type
+	node.ChargerID = n.ChargerID
+	node.ApproachHeading = n.ApproachHeading
+	node.ShelfID = n.ShelfID
+	node.Capacity = n.Capacity
+}
+
+// NewRevisionRoute 由路径生成快照
+func NewRevisionRoute(route MapRoutes) RevisionRoute {
+	return RevisionRoute{
+		ID:            route.ID,
+		Name:          route.RoutesName,
+		PathRole:      route.PathRole,
+		Start:         route.Start,
+		End:           route.End,
+		StartNodeID:   route.StartNodeID,
+		EndNodeID:     route.EndNodeID,
+		StartToEnd:    route.StartToEnd,
+		EndToStart:    route.EndToStart,
+		Shape:         route.Shape,
+		ControlPoints: route.ControlPoints,
+		MaxSpeed:      route.MaxSpeed,
+		Width:         route.Width,
+		CostFactor:    route.CostFactor,
+		Priority:      route.Priority,
+		ClosedWindows: route.ClosedWindows,
+		StartRoi:      route.StartRoi,
+		EndRoi:        route.EndRoi,
+	}
+}
+
+// Apply 将快照内容写回路径
+func (r RevisionRoute) Apply(route *MapRoutes) {
+	route.ID = r.ID
+	route.RoutesName = r.Name
+	route.PathRole = r.PathRole
+	route.Start = r.Start
+	route.End = r.End
+	route.StartNodeID = r.StartNodeID
+	route.EndNodeID = r.EndNodeID
+	route.StartToEnd = r.StartToEnd
+	route.EndToStart = r.EndToStart
+	route.Shape = r.Shape
+	route.ControlPoints = r.ControlPoints
+	route.MaxSpeed = r.MaxSpeed
+	route.Width = r.Width
+	route.CostFactor = r.CostFactor
+	route.Priority = r.Priority
+	route.ClosedWindows = r.ClosedWindows
+	route.StartRoi = r.StartRoi
+	route.EndRoi = r.EndRoi
+}
+
+// NewRevisionZone 由区域生成快照
+func NewRevisionZone(zone MapZone) RevisionZone {
+	return RevisionZone{
+		ID:       zone.ID,
+		Name:     zone.Name,
+		Type:     zone.Type,
+		Polygon:  zone.Polygon,
+		MaxSpeed: zone.MaxSpeed,
+		Heading:  zone.Heading,
+	}
+}
+
+// Apply 将快照内容写回区域
+func (z RevisionZone) Apply(zone *MapZone) {
+	zone.ID = z.ID
+	zone.Name = z.Name
+	zone.Type = z.Type
+	zone.Polygon = z.Polygon
+	zone.MaxSpeed = z.MaxSpeed
+	zone.Heading = z.Heading
+}
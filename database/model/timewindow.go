@@ -0,0 +1,123 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const timeWindowFormat = "15:04"
+
+// RouteTimeWindow 路径不可通行的时间窗，按一天内的时刻表示，End早于Start时表示跨越零点
+type RouteTimeWindow struct {
+	Start    string `json:"start"`              //开始时刻，如12:00
+	End      string `json:"end"`                //结束时刻(不含)，如13:00
+	Weekdays []int  `json:"weekdays,omitempty"` //生效的星期(1-7，周一为1)，为空每天生效
+}
+
+// RouteTimeWindows 以jsonb存储的时间窗列表
+type RouteTimeWindows []RouteTimeWindow
+
+// Valid 时刻格式为HH:MM、起止不同且星期取值合法
+func (w RouteTimeWindow) Valid() bool {
+	start, err := time.Parse(timeWindowFormat, w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(timeWindowFormat, w.End)
+	if err != nil || start.Equal(end) {
+		return false
+	}
+	for _, v := range w.Weekdays {
+		if v < 1 || v > 7 {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains 时间t是否落在时间窗内，跨越零点的时间窗按开始时刻所在的星期判断
+func (w RouteTimeWindow) Contains(t time.Time) bool {
+	start, err := time.Parse(timeWindowFormat, w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(timeWindowFormat, w.End)
+	if err != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	from, to := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	day := t
+	if from < to {
+		if minute < from || minute >= to {
+			return false
+		}
+	} else {
+		if minute < from && minute >= to {
+			return false
+		}
+		//零点之后的部分属于前一天开始的时间窗
+		if minute < to {
+			day = t.AddDate(0, 0, -1)
+		}
+	}
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	weekday := int(day.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	for _, v := range w.Weekdays {
+		if v == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid 全部时间窗均合法
+func (ws RouteTimeWindows) Valid() bool {
+	for _, w := range ws {
+		if !w.Valid() {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains 时间t是否落在任一时间窗内
+func (ws RouteTimeWindows) Contains(t time.Time) bool {
+	for _, w := range ws {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ws RouteTimeWindows) Value() (driver.Value, error) {
+	if len(ws) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(ws)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (ws *RouteTimeWindows) Scan(v interface{}) error {
+	switch vt := v.(type) {
+	case []byte:
+		return json.Unmarshal(vt, ws)
+	case string:
+		return json.Unmarshal([]byte(vt), ws)
+	case nil:
+		*ws = nil
+		return nil
+	}
+	return fmt.Errorf("RouteTimeWindows不支持的数据类型[%T]", v)
+}
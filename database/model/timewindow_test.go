@@ -0,0 +1,99 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRouteTimeWindowValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		window RouteTimeWindow
+		want   bool
+	}{
+		{"合法", RouteTimeWindow{Start: "12:00", End: "13:00"}, true},
+		{"跨越零点", RouteTimeWindow{Start: "22:00", End: "06:00", Weekdays: []int{1, 7}}, true},
+		{"起止相同", RouteTimeWindow{Start: "12:00", End: "12:00"}, false},
+		{"开始时刻格式错误", RouteTimeWindow{Start: "12", End: "13:00"}, false},
+		{"结束时刻超出范围", RouteTimeWindow{Start: "12:00", End: "24:00"}, false},
+		{"星期为0", RouteTimeWindow{Start: "12:00", End: "13:00", Weekdays: []int{0}}, false},
+		{"星期超过7", RouteTimeWindow{Start: "12:00", End: "13:00", Weekdays: []int{8}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Valid(); got != tt.want {
+				t.Errorf("Valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteTimeWindowContains(t *testing.T) {
+	// 2024-01-01为周一
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+	}
+	lunch := RouteTimeWindow{Start: "12:00", End: "13:00"}
+	night := RouteTimeWindow{Start: "22:00", End: "06:00", Weekdays: []int{1}}
+	tests := []struct {
+		name   string
+		window RouteTimeWindow
+		t      time.Time
+		want   bool
+	}{
+		{"开始时刻", lunch, at(1, 12, 0), true},
+		{"窗口内", lunch, at(1, 12, 59), true},
+		{"结束时刻不含", lunch, at(1, 13, 0), false},
+		{"窗口前", lunch, at(1, 11, 59), false},
+		{"跨零点当天部分", night, at(1, 23, 0), true},
+		{"跨零点次日部分按前一天判断", night, at(2, 5, 59), true},
+		{"跨零点次日结束后", night, at(2, 6, 0), false},
+		{"跨零点前一天不生效", night, at(1, 5, 0), false},
+		{"跨零点其它星期", night, at(2, 23, 0), false},
+		{"周日为7", RouteTimeWindow{Start: "08:00", End: "09:00", Weekdays: []int{7}}, at(7, 8, 30), true},
+		{"格式错误", RouteTimeWindow{Start: "x", End: "09:00"}, at(1, 8, 30), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Contains(tt.t); got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteTimeWindowsScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    int
+		wantErr bool
+	}{
+		{"bytes", []byte(`[{"start":"12:00","end":"13:00"}]`), 1, false},
+		{"string", `[{"start":"12:00","end":"13:00"},{"start":"22:00","end":"06:00","weekdays":[1]}]`, 2, false},
+		{"nil", nil, 0, false},
+		{"不支持的类型", 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ws RouteTimeWindows
+			err := ws.Scan(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(ws) != tt.want {
+				t.Errorf("Scan() = %v, want %d个时间窗", ws, tt.want)
+			}
+			if !tt.wantErr {
+				value, err := ws.Value()
+				if err != nil {
+					t.Fatalf("Value() error = %v", err)
+				}
+				var again RouteTimeWindows
+				if err = again.Scan(value); err != nil || len(again) != tt.want {
+					t.Errorf("Value()再Scan() = %v, err %v", again, err)
+				}
+			}
+		})
+	}
+}
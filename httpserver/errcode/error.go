@@ -23,6 +23,7 @@ const (
 
 	ErrorMsgSuffixParamExists    = "%v已经存在"
 	ErrorMsgSuffixParamNotExists = "%v不存在"
+	ErrorMsgSuffixEditLocked     = "%v正在编辑该地图切片"
 
 	// ErrorCodeBusiness Business Code
 	ErrorCodeBusiness = 9999
@@ -139,6 +140,42 @@ const (
 
 	ErrorMsgNodeOvertopArea = "节点超出区域范围"
 	ErrorMsgCheckRoute      = "路径校验失败"
+
+	ErrorMsgPlanRoute        = "路径规划失败"
+	ErrorMsgRouteUnreachable = "起点与终点之间不存在可达路径"
+	ErrorMsgMapImageEmpty    = "地图图片未上传"
+	ErrorMsgMapImageDecode   = "地图图片解码失败"
+	ErrorMsgRouteBlocked     = "路径经过障碍区域"
+	ErrorMsgRouteUnknownArea = "路径经过未知区域"
+	ErrorMsgImportRosMap     = "ROS地图导入失败"
+	ErrorMsgExportRosMap     = "ROS地图导出失败"
+	ErrorMsgRosMapBundle     = "ROS地图包缺少yaml或图片文件"
+	ErrorMsgRosMapYaml       = "ROS地图yaml解析失败"
+	ErrorMsgValidateMapInfo  = "地图切片拓扑校验失败"
+	ErrorMsgRestoreTrash     = "回收站数据恢复失败"
+	ErrorMsgPurgeTrash       = "回收站数据清理失败"
+	ErrorMsgTrashParent      = "所属地图或切片已删除，请先恢复上级数据"
+	ErrorMsgDiffRevision     = "地图切片版本对比失败"
+	ErrorMsgRollbackRevision = "地图切片版本回滚失败"
+	ErrorMsgPublishMapInfo   = "地图切片发布失败"
+	ErrorMsgMapEditLocked    = "地图切片正在被他人编辑"
+	ErrorMsgEditLockRequired = "未持有地图切片编辑锁或编辑锁已过期"
+	ErrorMsgEditLock         = "地图切片编辑锁操作失败"
+	ErrorMsgNearestNode      = "起点可达范围内不存在满足条件的目标节点"
+	ErrorMsgRouteForbidden   = "路径穿越禁行区"
+	ErrorMsgUpload           = "文件上传失败"
+	ErrorMsgUploadOffset     = "分片偏移量与已上传大小不一致"
+	ErrorMsgUploadIncomplete = "文件尚未上传完成"
+	ErrorMsgUploadChecksum   = "文件校验和不一致，请重新上传"
+	ErrorMsgMapTiles         = "地图瓦片生成失败"
+	ErrorMsgMapTilesRunning  = "地图瓦片正在生成中"
+	ErrorMsgMapTile          = "地图瓦片读取失败"
+	ErrorMsgPointCloudEmpty  = "地图切片未关联点云"
+	ErrorMsgZBandInvalid     = "地图切片z轴起点须小于终点"
+	ErrorMsgOccupancy        = "占据栅格地图生成失败"
+	ErrorMsgOccupancyRunning = "占据栅格地图正在生成中"
+	ErrorMsgRosMapTooLarge   = "ROS地图文件超过大小上限"
+	ErrorMsgCompressRunning  = "地图压缩图片正在生成中"
 )
 
 var (
@@ -246,6 +283,41 @@ var (
 		ErrorMsgHttpClientError:     6005,
 		ErrorMsgUpgradeWebSocket:    6006,
 		ErrorMsgCheckRoute:          6007,
+		ErrorMsgPlanRoute:           6008,
+		ErrorMsgRouteUnreachable:    6009,
+		ErrorMsgMapImageEmpty:       6010,
+		ErrorMsgMapImageDecode:      6011,
+		ErrorMsgRouteBlocked:        6012,
+		ErrorMsgRouteUnknownArea:    6013,
+		ErrorMsgImportRosMap:        6014,
+		ErrorMsgExportRosMap:        6015,
+		ErrorMsgRosMapBundle:        6016,
+		ErrorMsgRosMapYaml:          6017,
+		ErrorMsgValidateMapInfo:     6018,
+		ErrorMsgRestoreTrash:        6020,
+		ErrorMsgPurgeTrash:          6021,
+		ErrorMsgTrashParent:         6022,
+		ErrorMsgDiffRevision:        6023,
+		ErrorMsgRollbackRevision:    6024,
+		ErrorMsgPublishMapInfo:      6025,
+		ErrorMsgMapEditLocked:       6026,
+		ErrorMsgEditLockRequired:    6027,
+		ErrorMsgEditLock:            6028,
+		ErrorMsgNearestNode:         6029,
+		ErrorMsgRouteForbidden:      6030,
+		ErrorMsgUpload:              6031,
+		ErrorMsgUploadOffset:        6032,
+		ErrorMsgUploadIncomplete:    6033,
+		ErrorMsgUploadChecksum:      6034,
+		ErrorMsgMapTiles:            6035,
+		ErrorMsgMapTilesRunning:     6036,
+		ErrorMsgMapTile:             6037,
+		ErrorMsgPointCloudEmpty:     6038,
+		ErrorMsgZBandInvalid:        6039,
+		ErrorMsgOccupancy:           6040,
+		ErrorMsgOccupancyRunning:    6041,
+		ErrorMsgRosMapTooLarge:      6042,
+		ErrorMsgCompressRunning:     6043,
 	}
 
 	// CommonErrorMsg 通用错误信息
@@ -253,6 +325,7 @@ var (
 		ErrorMsgSuffixParamExists,
 		ErrorMsgSuffixParamNotExists,
 		ErrorMsgPrefixInvalidParameter,
+		ErrorMsgSuffixEditLocked,
 	}
 
 	// PostProcessingMsg 通用的错误处理信息后置处理
@@ -260,6 +333,7 @@ var (
 		ErrorMsgSuffixParamExists:      ErrorMsgDataExists,
 		ErrorMsgSuffixParamNotExists:   ErrorMsgDataNotExists,
 		ErrorMsgPrefixInvalidParameter: ErrorMsgValidateParam,
+		ErrorMsgSuffixEditLocked:       ErrorMsgMapEditLocked,
 	}
 )
 
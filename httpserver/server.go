@@ -69,12 +69,46 @@ func RegisterRoutes(router *gin.Engine, middlewares []gin.HandlerFunc) {
 		m.POST("/map_info_nodes:info_id", restHandler.CreateOrUpdateNode) //生成路径节点
 		m.GET("/map_info_nodes", restHandler.ListMapNodes)
 		m.DELETE("/map_info_nodes/:id", restHandler.DeleteMapNodes)
+		m.POST("/map_info_node_rename/:id", restHandler.RenameMapNode)           //节点重命名
 		m.POST("/map_info_routes/:info_id", restHandler.CreateOrUpdateMapRoutes) //生成路径节点+路径
 		m.GET("/map_info_routes", restHandler.ListMapRoutes)                     //查找路径
 		m.DELETE("/map_info_routes/:id", restHandler.DeleteMapRoute)
-		m.POST("/check_route", restHandler.CheckRoute) //检验路径
-		m.GET("/map_infos/:map_id", restHandler.ListMapInfo)
-		m.POST("/map_nodes_batch/", restHandler.BatchDeleteMapNodes) //批量删除路径节点
+		m.POST("/check_route", restHandler.CheckRoute)                   //检验路径
+		m.GET("/map_infos/:info_id", restHandler.ListMapInfo)            //切片路径网络，路径参数为切片id(原参数名map_id与处理函数不一致)
+		m.POST("/map_nodes_batch/", restHandler.BatchDeleteMapNodes)     //批量删除路径节点
+		m.POST("/plan_route", restHandler.PlanRoute)                     //路径规划
+		m.POST("/map_info_import", restHandler.ImportRosMap)             //导入ROS地图(yaml+pgm)
+		m.GET("/map_info_export/:id", restHandler.ExportRosMap)          //导出ROS地图
+		m.POST("/map_connector", restHandler.CreateOrUpdateMapConnector) //楼层连接器
+		m.GET("/map_connectors", restHandler.ListMapConnectors)
+		m.DELETE("/map_connector/:id", restHandler.DeleteMapConnector)
+		m.POST("/plan_map_route", restHandler.PlanMapRoute)                             //跨楼层路径规划
+		m.POST("/map_info_validate/:info_id", restHandler.ValidateMapInfo)              //路径网络拓扑校验
+		m.GET("/map_trash", restHandler.ListTrash)                                      //回收站
+		m.POST("/map_trash_restore/:type/:id", restHandler.RestoreTrash)                //恢复回收站数据
+		m.POST("/map_trash_purge", restHandler.PurgeTrash)                              //清理超过保留天数的回收站数据
+		m.GET("/map_info_revisions/:info_id", restHandler.ListMapRevisions)             //切片修订版本
+		m.GET("/map_info_revision_diff/:info_id", restHandler.DiffMapRevisions)         //切片版本对比
+		m.POST("/map_info_revision_rollback/:info_id", restHandler.RollbackMapRevision) //切片回滚至指定版本
+		m.POST("/map_info_publish/:info_id", restHandler.PublishMapInfo)                //校验并发布切片草稿
+		m.POST("/map_info_edit_lock/:info_id", restHandler.AcquireEditLock)             //获取切片编辑锁
+		m.PUT("/map_info_edit_lock/:info_id", restHandler.RenewEditLock)                //续期切片编辑锁
+		m.DELETE("/map_info_edit_lock/:info_id", restHandler.ReleaseEditLock)           //释放切片编辑锁
+		m.GET("/map_info_edit_lock/:info_id", restHandler.GetEditLock)                  //查询切片编辑锁持有人
+		m.POST("/plan_nearest_node", restHandler.PlanNearestNode)                       //查找最近的指定类型节点并规划路径
+		m.POST("/map_zone", restHandler.CreateOrUpdateMapZone)                          //禁行区、限速区等多边形区域
+		m.GET("/map_zones", restHandler.ListMapZones)
+		m.DELETE("/map_zone/:id", restHandler.DeleteMapZone)
+		m.POST("/map_upload", restHandler.InitUpload)                       //分片上传地图图片、点云
+		m.GET("/map_upload/:token", restHandler.GetUpload)                  //查询上传进度
+		m.PUT("/map_upload/:token", restHandler.UploadChunk)                //上传分片，请求体为分片数据
+		m.POST("/map_upload/:token/complete", restHandler.CompleteUpload)   //校验并完成上传
+		m.DELETE("/map_upload/:token", restHandler.AbortUpload)             //取消上传
+		m.POST("/map_info/:id/tiles", restHandler.GenerateMapTiles)         //后台生成瓦片金字塔
+		m.GET("/map_info/:id/tiles", restHandler.GetMapTiles)               //查询瓦片信息与生成进度
+		m.GET("/map_info/:id/tiles/:z/:x/:y", restHandler.GetMapTile)       //读取瓦片，y为带.png后缀的行号
+		m.POST("/map_info/:id/occupancy", restHandler.GenerateOccupancyMap) //后台由点云z轴切片生成占据栅格地图
+		m.GET("/map_info/:id/occupancy", restHandler.GetOccupancyMap)       //查询占据栅格地图生成进度
 
 	}
 
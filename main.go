@@ -5,6 +5,7 @@ import (
 	"demo-gogo/database"
 	"demo-gogo/httpserver"
 	"demo-gogo/utils/redis"
+	"demo-gogo/utils/storage"
 	"fmt"
 	log "github.com/wonderivan/logger"
 )
@@ -25,12 +26,10 @@ func main() {
 		panic("init database with error:" + err.Error())
 	}
 
-	//if config.Conf.OSS.Type == config.CONF_OSS_MINIO {
-	//	err = storage.InitStorage()
-	//	if err != nil {
-	//		panic("init storage with error:" + err.Error())
-	//	}
-	//}
+	err = storage.InitStorage()
+	if err != nil {
+		panic("init storage with error:" + err.Error())
+	}
 	//err = env.InitPython()
 	//if err != nil {
 	//	panic("init pythonEnv with error:" + err.Error())
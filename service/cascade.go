@@ -0,0 +1,149 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database"
+	"demo-gogo/database/model"
+	log "github.com/wonderivan/logger"
+	"time"
+)
+
+// cascadeScope 级联删除的范围，mapIDs为空时仅删除切片及其下属数据
+type cascadeScope struct {
+	mapIDs  []int
+	infoIDs []int
+}
+
+// cascadeTarget 级联删除中的一类数据：table中field取值属于ids的记录
+type cascadeTarget struct {
+	table string
+	field string
+	ids   []int
+	count *int64
+	// exclude 排除已由其他目标统计的记录
+	exclude map[string]interface{}
+}
+
+func (scope cascadeScope) targets(resp *apimodel.CascadeDeleteResponse) []cascadeTarget {
+	targets := []cascadeTarget{
+		{table: model.TableNameMapRoutes, field: model.FieldInfoId, ids: scope.infoIDs, count: &resp.Routes},
+		{table: model.TableNameMapRouteNodes, field: model.FieldInfoId, ids: scope.infoIDs, count: &resp.Nodes},
+		{table: model.TableNameMapZone, field: model.FieldInfoId, ids: scope.infoIDs, count: &resp.Zones},
+	}
+	if len(scope.mapIDs) > 0 {
+		targets = append(targets,
+			cascadeTarget{table: model.TableNameMapConnector, field: model.FieldMapId, ids: scope.mapIDs, count: &resp.Connectors},
+			cascadeTarget{table: model.TableNameMapInfo, field: model.FieldMapId, ids: scope.mapIDs, count: &resp.MapInfos},
+			cascadeTarget{table: model.TableNameMap, field: model.FieldID, ids: scope.mapIDs, count: &resp.Maps},
+		)
+	} else {
+		// 连接器按起止切片分别处理，目标端排除起始端已包含的记录避免重复统计
+		targets = append(targets,
+			cascadeTarget{table: model.TableNameMapConnector, field: model.FieldFromInfoID, ids: scope.infoIDs, count: &resp.Connectors},
+			cascadeTarget{table: model.TableNameMapConnector, field: model.FieldToInfoID, ids: scope.infoIDs, count: &resp.Connectors,
+				exclude: map[string]interface{}{model.FieldFromInfoID: scope.infoIDs}},
+			cascadeTarget{table: model.TableNameMapInfo, field: model.FieldID, ids: scope.infoIDs, count: &resp.MapInfos},
+		)
+	}
+	return targets
+}
+
+// cascadeDelete 在事务内自下而上软删除地图层级数据，同一次删除的记录使用相同的删除时间。
+// 切片查询、数量统计与删除在同一事务内完成，dry_run时统计后回滚
+func (operator *ResourceOperator) cascadeDelete(scope cascadeScope, dryRun bool) (*apimodel.CascadeDeleteResponse, error) {
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("cascadeDelete TransactionBegin Error.err[%v]", err)
+		return nil, err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	err = tx.lockCascadeScope(&scope)
+	if err != nil {
+		return nil, err
+	}
+	resp := apimodel.CascadeDeleteResponse{DryRun: dryRun}
+	targets := scope.targets(&resp)
+	for _, target := range targets {
+		if len(target.ids) == 0 {
+			continue
+		}
+		var count int64
+		err = tx.Database.CountEntityByFilter(target.table, model.EmptyFilter, target.queryParams(), &count)
+		if err != nil {
+			return nil, err
+		}
+		*target.count += count
+	}
+	if dryRun {
+		return &resp, nil
+	}
+	now := time.Now()
+	for _, target := range targets {
+		if len(target.ids) == 0 {
+			continue
+		}
+		err = softDeleteEntity(tx.Database, target.table, model.EmptyFilter, target.queryParams(), now)
+		if err != nil {
+			log.Error("级联删除[%s]数据失败. err:[%v]", target.table, err)
+			return nil, err
+		}
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("cascadeDelete TransactionCommit Error.err[%v]", err)
+		return nil, err
+	}
+	log.Info("级联删除完成,地图[%v] 切片[%v] 节点[%d] 路径[%d] 连接器[%d] 区域[%d]", scope.mapIDs, scope.infoIDs, resp.Nodes, resp.Routes, resp.Connectors, resp.Zones)
+	return &resp, nil
+}
+
+func (target cascadeTarget) queryParams() model.QueryParams {
+	queryParams := model.QueryParams{}
+	queryParams.InQueries = append(queryParams.InQueries, &model.InQuery{Field: target.field, Values: target.ids})
+	if target.exclude != nil {
+		queryParams.NotInQueries = append(queryParams.NotInQueries, target.exclude)
+	}
+	return queryParams
+}
+
+// softDeleteEntity 以指定的删除时间逻辑删除数据。同一次操作删除的数据共享删除时间，回收站据此整批恢复
+func softDeleteEntity(db database.Database, table string, filter map[string]interface{}, params model.QueryParams, now time.Time) error {
+	selector := make(map[string]interface{})
+	for k, v := range filter {
+		selector[k] = v
+	}
+	selector[model.FieldDeletedTime] = nil
+	updater := map[string]interface{}{model.FieldDeletedTime: now}
+	return db.UpdateEntityByFilter(table, selector, params, &updater)
+}
+
+// lockCascadeScope 锁定待删除的地图与切片记录，并发的删除及切片修改在事务结束前等待。删除地图时在锁定地图后查询其下切片
+func (operator *ResourceOperator) lockCascadeScope(scope *cascadeScope) error {
+	for _, id := range scope.mapIDs {
+		var mapDB model.Map
+		if err := operator.Database.GetEntityForUpdate(model.TableNameMap, id, &mapDB); err != nil {
+			return err
+		}
+	}
+	if len(scope.mapIDs) > 0 {
+		//地图加锁后再查询其下切片，避免遗漏并发新建的切片
+		queryParams := model.QueryParams{}
+		queryParams.InQueries = append(queryParams.InQueries, &model.InQuery{Field: model.FieldMapId, Values: scope.mapIDs})
+		scope.infoIDs = nil
+		err := operator.Database.GetEntityPluck(model.TableNameMapInfo, model.EmptyFilter, queryParams, model.FieldID, &scope.infoIDs)
+		if err != nil {
+			log.Error("地图切片数据查询失败. err:[%v]", err)
+			return err
+		}
+	}
+	for _, id := range scope.infoIDs {
+		var mapInfo model.MapInfo
+		if err := operator.Database.GetEntityForUpdate(model.TableNameMapInfo, id, &mapInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
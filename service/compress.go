@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bytes"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils"
+	"demo-gogo/utils/storage"
+	"errors"
+	"fmt"
+	"github.com/rs/xid"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"image"
+	"image/jpeg"
+	"math"
+	"path"
+)
+
+// compressScale 按配置的最大宽高计算压缩比例，不超过1
+func compressScale(width, height int) float64 {
+	scale := 1.0
+	mapConf := config.Conf.Map
+	if mapConf.CompressMaxWidth > 0 && width > mapConf.CompressMaxWidth {
+		scale = math.Min(scale, float64(mapConf.CompressMaxWidth)/float64(width))
+	}
+	if mapConf.CompressMaxHeight > 0 && height > mapConf.CompressMaxHeight {
+		scale = math.Min(scale, float64(mapConf.CompressMaxHeight)/float64(height))
+	}
+	return scale
+}
+
+// saveCompressedMap 将地图图片缩放至配置的最大宽高内，以jpeg格式写入文件存储，返回存储key与坐标比例
+func saveCompressedMap(img image.Image) (string, float64, error) {
+	if storage.Default == nil {
+		return "", 0, errors.New("文件存储未初始化")
+	}
+	size := img.Bounds().Size()
+	scale := compressScale(size.X, size.Y)
+	width := int(math.Max(1, math.Round(float64(size.X)*scale)))
+	height := int(math.Max(1, math.Round(float64(size.Y)*scale)))
+	// 宽高取整后按宽度重算比例，保证坐标换算与图片一致
+	scale = float64(width) / float64(size.X)
+	quality := config.Conf.Map.CompressQuality
+	if quality <= 0 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, utils.ResizeArea(img, width, height), &jpeg.Options{Quality: quality}); err != nil {
+		return "", 0, err
+	}
+	key := path.Join(folderRosMap, xid.New().String()+"_compress.jpg")
+	if err := storage.Default.Put(key, &buf, int64(buf.Len())); err != nil {
+		return "", 0, err
+	}
+	log.Info("地图压缩图片生成完成,原图[%dx%d] 压缩图[%dx%d] 比例[%v]", size.X, size.Y, width, height, scale)
+	return key, scale, nil
+}
+
+// startCompressJob 地图图片变更后启动后台任务生成压缩图片，避免在请求内解码原图。
+// 同一切片的任务正在运行时不重复启动，运行中的任务结束后会按最新的地图图片复查
+func (operator *ResourceOperator) startCompressJob(infoID int) {
+	params := map[string]interface{}{"info_id": infoID}
+	job, err := startMapJob(model.GetMapCompressKey(infoID), params, errcode.ErrorMsgCompressRunning)
+	if err != nil {
+		if err.Error() != errcode.ErrorMsgCompressRunning {
+			log.Error("地图切片[%d]压缩图片任务启动失败. err:[%v]", infoID, err)
+		}
+		return
+	}
+	go operator.buildCompressedMap(job, infoID)
+}
+
+// buildCompressedMap 压缩图片生成任务。生成期间地图图片再次变更时结果作废，任务结束后按新图片重新启动
+func (operator *ResourceOperator) buildCompressedMap(job *mapJob, infoID int) {
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil {
+			log.Error("地图切片[%d]压缩图片生成失败. err:[%v]", infoID, err)
+		}
+		job.finish(err, "地图压缩图片生成完成")
+		if err == nil && operator.needsCompress(infoID) {
+			operator.startCompressJob(infoID)
+		}
+	}()
+	job.progress.Message = "正在生成地图压缩图片"
+	job.update()
+	mapInfo, err := operator.findMapInfo(infoID)
+	if err != nil || mapInfo.MapURL == "" || mapInfo.MapURLCompress != "" {
+		return
+	}
+	img, err := decodeMapImage(mapInfo.MapURL)
+	if err != nil {
+		return
+	}
+	key, scale, err := saveCompressedMap(img)
+	if err != nil {
+		return
+	}
+	err = operator.applyCompressedMap(mapInfo.ID, mapInfo.MapURL, key, scale)
+	if err != nil {
+		removeStoredFile(key)
+	}
+}
+
+// applyCompressedMap 在事务内写入压缩图片。地图图片已变更或期间已手动上传压缩图片时丢弃生成结果
+func (operator *ResourceOperator) applyCompressedMap(infoID int, mapURL, key string, scale float64) error {
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("applyCompressedMap TransactionBegin Error.err[%v]", err)
+		return err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	var current model.MapInfo
+	err = tx.Database.GetEntityForUpdate(model.TableNameMapInfo, infoID, &current)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return err
+	}
+	if current.MapURL != mapURL || current.MapURLCompress != "" {
+		log.Info("地图切片[%d]的地图图片或压缩图片已变更,丢弃生成的压缩图片", infoID)
+		removeStoredFile(key)
+		return nil
+	}
+	current.MapURLCompress = key
+	current.CompressScale = scale
+	err = tx.Database.SaveEntity(model.TableNameMapInfo, &current)
+	if err != nil {
+		log.Error("地图信息数据更新失败. err:[%v]", err)
+		return err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("applyCompressedMap TransactionCommit Error.err[%v]", err)
+		return err
+	}
+	return nil
+}
+
+// needsCompress 切片有地图图片但尚无压缩图片
+func (operator *ResourceOperator) needsCompress(infoID int) bool {
+	mapInfo, err := operator.findMapInfo(infoID)
+	return err == nil && mapInfo.MapURL != "" && mapInfo.MapURLCompress == ""
+}
+
+// removeStoredFile 删除文件存储中的对象，历史数据中的完整地址不处理
+func removeStoredFile(value string) {
+	occupancyGrids.remove(value)
+	if storage.Default == nil || !storage.IsKey(value) {
+		return
+	}
+	if err := storage.Default.Delete(value); err != nil {
+		log.Warn("文件存储对象[%s]删除失败. err:[%v]", value, err)
+	}
+}
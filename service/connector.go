@@ -0,0 +1,186 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"errors"
+	"fmt"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+)
+
+// CreateOrUpdateMapConnector 创建或更新楼层连接器，连接的两个切片须属于同一地图且节点位于对应切片上
+func (operator *ResourceOperator) CreateOrUpdateMapConnector(req *apimodel.MapConnectorRequest) error {
+	var opt model.MapConnector
+	selector := make(map[string]interface{})
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("CreateOrUpdateMapConnector TransactionBegin Error.err[%v]", err)
+		return err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	// 同一地图内名称唯一
+	selector[model.FieldName] = req.Name
+	selector[model.FieldMapId] = req.MapID
+	err = tx.Database.ListEntityByFilter(model.TableNameMapConnector, selector, model.OneQuery, &opt)
+	if err != nil {
+		return err
+	}
+	if opt.ID != 0 && opt.ID != req.ID {
+		return fmt.Errorf(errcode.ErrorMsgSuffixParamExists, "连接器")
+	}
+	if req.ID > 0 {
+		err = tx.Database.GetEntityByID(model.TableNameMapConnector, req.ID, &opt)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "待修改连接器")
+			}
+			return err
+		}
+	} else {
+		opt.Available = true
+		opt.Bidirectional = true
+	}
+	err = tx.checkConnectorEnd(req.MapID, req.FromInfoID, req.FromNodeID, "起始")
+	if err != nil {
+		return err
+	}
+	err = tx.checkConnectorEnd(req.MapID, req.ToInfoID, req.ToNodeID, "目标")
+	if err != nil {
+		return err
+	}
+	// 连接器变更会改变两端切片的路径网络，修改前后涉及的切片均需校验编辑锁
+	err = tx.lockSlicesForEdit([]int{opt.FromInfoID, opt.ToInfoID, req.FromInfoID, req.ToInfoID}, req.EditContext)
+	if err != nil {
+		return err
+	}
+
+	opt.Name = req.Name
+	opt.MapID = req.MapID
+	opt.Type = req.Type
+	opt.FromInfoID = req.FromInfoID
+	opt.FromNodeID = req.FromNodeID
+	opt.ToInfoID = req.ToInfoID
+	opt.ToNodeID = req.ToNodeID
+	opt.Cost = req.Cost
+	if req.Available != nil {
+		opt.Available = *req.Available
+	}
+	if req.Bidirectional != nil {
+		opt.Bidirectional = *req.Bidirectional
+	}
+	if req.ID > 0 {
+		err = tx.Database.SaveEntity(model.TableNameMapConnector, &opt)
+		if err != nil {
+			log.Error("连接器数据更新失败. err:[%v]", err)
+			return err
+		}
+	} else {
+		err = tx.Database.CreateEntity(model.TableNameMapConnector, &opt)
+		if err != nil {
+			log.Error("连接器数据创建失败. err:[%v]", err)
+			return err
+		}
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("CreateOrUpdateMapConnector TransactionCommit Error.err[%v]", err)
+		return err
+	}
+	return nil
+}
+
+// checkConnectorEnd 校验连接器一端的切片属于该地图且节点位于该切片上
+func (operator *ResourceOperator) checkConnectorEnd(mapID, infoID, nodeID int, side string) error {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, infoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, side+"地图切片")
+		}
+		return err
+	}
+	if mapInfo.MapID != mapID {
+		return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图内的"+side+"地图切片")
+	}
+	var node model.MapRouteNodes
+	err = operator.Database.GetEntityByID(model.TableNameMapRouteNodes, nodeID, &node)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, side+"节点")
+		}
+		return err
+	}
+	if node.InfoID != infoID {
+		return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, side+"地图切片上的"+side+"节点")
+	}
+	return nil
+}
+
+func (operator *ResourceOperator) ListMapConnectors(req *apimodel.MapConnectorRequest) (*apimodel.MapConnectorResponse, error) {
+	var resp apimodel.MapConnectorResponse
+	selector := make(map[string]interface{})
+	queryParams := model.QueryParams{}
+	if req.ID > 0 {
+		selector[model.FieldID] = req.ID
+	}
+	if req.Name != "" {
+		selector[model.FieldName] = req.Name
+	}
+	if req.MapID > 0 {
+		selector[model.FieldMapId] = req.MapID
+	}
+	if req.Type != "" {
+		selector[model.FieldType] = req.Type
+	}
+	var count int64
+	var connectors []model.MapConnector
+	err := operator.Database.CountEntityByFilter(model.TableNameMapConnector, selector, model.OneQuery, &count)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		order := model.Order{
+			Field:     req.OrderBy,
+			Direction: apimodel.OrderAsc,
+		}
+		queryParams.Orders = append(queryParams.Orders, order)
+		if req.PageSize > 0 {
+			queryParams.Limit = &req.PageSize
+			offset := (req.PageNo - 1) * req.PageSize
+			queryParams.Offset = &offset
+		}
+		err = operator.Database.ListEntityByFilter(model.TableNameMapConnector, selector, queryParams, &connectors)
+		if err != nil {
+			log.Error("连接器数据查询失败. err:[%v]", err)
+			return nil, err
+		}
+	}
+	resp.Load(count, connectors)
+	return &resp, nil
+}
+
+func (operator *ResourceOperator) DeleteMapConnector(req *apimodel.MapConnectorRequest) error {
+	var connector model.MapConnector
+	err := operator.Database.GetEntityByID(model.TableNameMapConnector, req.ID, &connector)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "待删除连接器")
+		}
+		return err
+	}
+	return operator.editSlices(req.EditContext, []int{connector.FromInfoID, connector.ToInfoID}, func(tx *ResourceOperator) error {
+		selector := make(map[string]interface{})
+		selector[model.FieldID] = req.ID
+		err := tx.Database.DeleteEntityByFilter(model.TableNameMapConnector, selector, model.QueryParams{}, &model.MapConnector{})
+		if err != nil {
+			log.Error("连接器数据删除失败. err:[%v]", err)
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils/redis"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+const editLockKeyPrefix = "map_edit_lock"
+
+// editHolder 编辑锁持有人信息，与锁同时过期
+type editHolder struct {
+	Author     string    `json:"author"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+func editLockKey(infoID int) string {
+	return fmt.Sprintf("%s:%d", editLockKeyPrefix, infoID)
+}
+
+func editHolderKey(infoID int) string {
+	return editLockKey(infoID) + ":holder"
+}
+
+func editLockTTL() time.Duration {
+	return time.Duration(config.Conf.Map.EditLockSeconds) * time.Second
+}
+
+// AcquireEditLock 获取切片编辑锁，锁被他人持有时在等待时间内重试，超时返回当前持有人
+func (operator *ResourceOperator) AcquireEditLock(req *apimodel.EditLockRequest) (*apimodel.EditLockInfo, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, req.InfoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	ttl := editLockTTL()
+	wait := time.Duration(config.Conf.Map.EditLockWaitSeconds) * time.Second
+	token, err := redis.LockWithTimeout(editLockKey(req.InfoID), wait, ttl)
+	if err != nil {
+		if errors.Is(err, redis.LockTimeOut) {
+			return nil, lockedError(req.InfoID)
+		}
+		return nil, err
+	}
+	now := time.Now()
+	data, err := json.Marshal(editHolder{Author: req.Author, AcquiredAt: now})
+	if err != nil {
+		_ = redis.UnLock(editLockKey(req.InfoID), token)
+		return nil, err
+	}
+	err = redis.RedisClient.Set(editHolderKey(req.InfoID), data, ttl).Err()
+	if err != nil {
+		log.Error("编辑锁持有人写入失败. err:[%v]", err)
+		_ = redis.UnLock(editLockKey(req.InfoID), token)
+		return nil, err
+	}
+	log.Info("[%s]获取地图切片[%d]编辑锁", req.Author, req.InfoID)
+	return &apimodel.EditLockInfo{
+		InfoID:     req.InfoID,
+		Locked:     true,
+		Holder:     req.Author,
+		Token:      token,
+		AcquiredAt: model.LocalTime(now).String(),
+		ExpiresAt:  model.LocalTime(now.Add(ttl)).String(),
+	}, nil
+}
+
+// RenewEditLock 持有人续期编辑锁
+func (operator *ResourceOperator) RenewEditLock(req *apimodel.EditLockRequest) (*apimodel.EditLockInfo, error) {
+	ttl := editLockTTL()
+	err := redis.RenewLock(editLockKey(req.InfoID), req.Token, ttl)
+	if err != nil {
+		return nil, errors.New(errcode.ErrorMsgEditLockRequired)
+	}
+	err = redis.RedisClient.Expire(editHolderKey(req.InfoID), ttl).Err()
+	if err != nil {
+		return nil, err
+	}
+	return operator.GetEditLock(req)
+}
+
+// ReleaseEditLock 持有人释放编辑锁
+func (operator *ResourceOperator) ReleaseEditLock(req *apimodel.EditLockRequest) error {
+	err := redis.UnLock(editLockKey(req.InfoID), req.Token)
+	if err != nil {
+		return errors.New(errcode.ErrorMsgEditLockRequired)
+	}
+	err = redis.RedisClient.Del(editHolderKey(req.InfoID)).Err()
+	if err != nil {
+		log.Error("编辑锁持有人删除失败. err:[%v]", err)
+	}
+	log.Info("地图切片[%d]编辑锁已释放", req.InfoID)
+	return nil
+}
+
+// GetEditLock 查询切片编辑锁的持有情况，不返回令牌
+func (operator *ResourceOperator) GetEditLock(req *apimodel.EditLockRequest) (*apimodel.EditLockInfo, error) {
+	info := apimodel.EditLockInfo{InfoID: req.InfoID}
+	ttl, err := redis.RedisClient.PTTL(editLockKey(req.InfoID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	// 键不存在时PTTL返回负值
+	if ttl <= 0 {
+		return &info, nil
+	}
+	info.Locked = true
+	info.ExpiresAt = model.LocalTime(time.Now().Add(ttl)).String()
+	data, err := redis.RedisClient.Get(editHolderKey(req.InfoID)).Result()
+	if err != nil {
+		if errors.Is(err, redis.NilError) {
+			return &info, nil
+		}
+		return nil, err
+	}
+	var holder editHolder
+	err = json.Unmarshal([]byte(data), &holder)
+	if err != nil {
+		return nil, err
+	}
+	info.Holder = holder.Author
+	info.AcquiredAt = model.LocalTime(holder.AcquiredAt).String()
+	return &info, nil
+}
+
+// assertEditLock 切片被他人锁定时拒绝修改；未加锁时任何人均可修改，加锁后仅持有令牌的请求可修改
+func assertEditLock(infoID int, ctx apimodel.EditContext) error {
+	token, err := redis.RedisClient.Get(editLockKey(infoID)).Result()
+	if err != nil && !errors.Is(err, redis.NilError) {
+		return err
+	}
+	if token != "" && token != ctx.Token {
+		return lockedError(infoID)
+	}
+	return nil
+}
+
+// lockSliceForEdit 在事务内以行锁锁定切片后校验编辑锁，同一切片的写入按事务串行执行，返回锁定的切片
+func (operator *ResourceOperator) lockSliceForEdit(infoID int, ctx apimodel.EditContext) (model.MapInfo, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityForUpdate(model.TableNameMapInfo, infoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mapInfo, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return mapInfo, err
+	}
+	return mapInfo, assertEditLock(infoID, ctx)
+}
+
+// lockSlicesForEdit 在事务内按id升序锁定并校验多个切片，避免并发写入相互死锁
+func (operator *ResourceOperator) lockSlicesForEdit(infoIDs []int, ctx apimodel.EditContext) error {
+	ids := append([]int(nil), infoIDs...)
+	sort.Ints(ids)
+	for i, id := range ids {
+		if id <= 0 || (i > 0 && id == ids[i-1]) {
+			continue
+		}
+		if _, err := operator.lockSliceForEdit(id, ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// editSlices 开启事务，锁定并校验涉及的切片后执行写入，写入成功后提交
+func (operator *ResourceOperator) editSlices(ctx apimodel.EditContext, infoIDs []int, write func(tx *ResourceOperator) error) error {
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("editSlices TransactionBegin Error.err[%v]", err)
+		return err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	if err = tx.lockSlicesForEdit(infoIDs, ctx); err != nil {
+		return err
+	}
+	if err = write(tx); err != nil {
+		return err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("editSlices TransactionCommit Error.err[%v]", err)
+		return err
+	}
+	return nil
+}
+
+// lockedError 切片已被他人锁定的错误，包含持有人
+func lockedError(infoID int) error {
+	data, err := redis.RedisClient.Get(editHolderKey(infoID)).Result()
+	if err != nil {
+		return errors.New(errcode.ErrorMsgMapEditLocked)
+	}
+	var holder editHolder
+	if json.Unmarshal([]byte(data), &holder) != nil || holder.Author == "" {
+		return errors.New(errcode.ErrorMsgMapEditLocked)
+	}
+	return fmt.Errorf(errcode.ErrorMsgSuffixEditLocked, fmt.Sprintf("[%s]", holder.Author))
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"math"
+)
+
+// mapFrame 地图切片像素坐标到世界坐标的变换。
+// 像素先按分辨率换算为以图片左下角为原点的米制坐标(y轴向上)，再经origin位姿[x,y,yaw]变换到世界坐标系
+type mapFrame struct {
+	matrix     []float64 //origin位姿的4x4齐次矩阵(行优先)
+	resolution float64
+	height     float64 //图片高度(像素)，y轴向下时用于翻转
+	yAxisUp    bool
+	compress   float64 //压缩图片与原图的坐标比例
+}
+
+func newMapFrame(mapInfo model.MapInfo) (*mapFrame, error) {
+	origin := []float64{0, 0, 0}
+	if len(mapInfo.MapOrigin) == 3 {
+		origin = mapInfo.MapOrigin
+	}
+	matrix, err := utils.PoseToMatrix([]float64{origin[0], origin[1], 0, 0, 0, origin[2]})
+	if err != nil {
+		return nil, err
+	}
+	frame := mapFrame{
+		matrix:     matrix,
+		resolution: mapInfo.Resolution,
+		yAxisUp:    mapInfo.YAxis == model.YAxisUp,
+		compress:   mapInfo.CompressScale,
+	}
+	if frame.compress <= 0 {
+		frame.compress = 1
+	}
+	if frame.resolution <= 0 {
+		frame.resolution = config.Conf.Map.Resolution
+	}
+	if !frame.yAxisUp {
+		_, height, err := mapImageSize(mapInfo.MapURL)
+		if err != nil {
+			return nil, err
+		}
+		frame.height = float64(height)
+	}
+	return &frame, nil
+}
+
+// toWorld 像素坐标[x,y]转世界坐标[x,y](米)
+func (f *mapFrame) toWorld(p pq.Float64Array) pq.Float64Array {
+	if len(p) < 2 {
+		return p
+	}
+	x := p[0] * f.resolution
+	y := p[1] * f.resolution
+	if !f.yAxisUp {
+		y = (f.height - p[1]) * f.resolution
+	}
+	m := f.matrix
+	return pq.Float64Array{m[0]*x + m[1]*y + m[3], m[4]*x + m[5]*y + m[7]}
+}
+
+// toPixel 世界坐标[x,y](米)转像素坐标，toWorld的逆变换
+func (f *mapFrame) toPixel(p pq.Float64Array) pq.Float64Array {
+	if len(p) < 2 {
+		return p
+	}
+	//旋转矩阵正交，逆变换取转置
+	m := f.matrix
+	dx, dy := p[0]-m[3], p[1]-m[7]
+	x := (m[0]*dx + m[4]*dy) / f.resolution
+	y := (m[1]*dx + m[5]*dy) / f.resolution
+	if !f.yAxisUp {
+		y = f.height - y
+	}
+	return pq.Float64Array{x, y}
+}
+
+// headingToWorld 像素坐标系下的朝向(弧度)转世界坐标系朝向
+func (f *mapFrame) headingToWorld(heading float64) float64 {
+	x, y := math.Cos(heading), math.Sin(heading)
+	if !f.yAxisUp {
+		y = -y
+	}
+	m := f.matrix
+	return math.Atan2(m[4]*x+m[5]*y, m[0]*x+m[1]*y)
+}
+
+// headingToPixel 世界坐标系下的朝向(弧度)转像素坐标系朝向
+func (f *mapFrame) headingToPixel(heading float64) float64 {
+	dx, dy := math.Cos(heading), math.Sin(heading)
+	m := f.matrix
+	x, y := m[0]*dx+m[4]*dy, m[1]*dx+m[5]*dy
+	if !f.yAxisUp {
+		y = -y
+	}
+	return math.Atan2(y, x)
+}
+
+// convert 原图像素坐标转为frameType坐标系
+func (f *mapFrame) convert(p pq.Float64Array, frameType string) pq.Float64Array {
+	switch frameType {
+	case apimodel.FrameWorld:
+		return f.toWorld(p)
+	case apimodel.FrameCompressed:
+		if len(p) < 2 {
+			return p
+		}
+		return pq.Float64Array{p[0] * f.compress, p[1] * f.compress}
+	}
+	return p
+}
+
+// revert frameType坐标系坐标转为原图像素坐标，convert的逆变换
+func (f *mapFrame) revert(p pq.Float64Array, frameType string) pq.Float64Array {
+	switch frameType {
+	case apimodel.FrameWorld:
+		return f.toPixel(p)
+	case apimodel.FrameCompressed:
+		if len(p) < 2 {
+			return p
+		}
+		return pq.Float64Array{p[0] / f.compress, p[1] / f.compress}
+	}
+	return p
+}
+
+// convertHeading 原图像素坐标系朝向转为frameType坐标系朝向，压缩图片等比缩放朝向不变
+func (f *mapFrame) convertHeading(heading float64, frameType string) float64 {
+	if frameType == apimodel.FrameWorld {
+		return f.headingToWorld(heading)
+	}
+	return heading
+}
+
+// revertHeading frameType坐标系朝向转为原图像素坐标系朝向
+func (f *mapFrame) revertHeading(heading float64, frameType string) float64 {
+	if frameType == apimodel.FrameWorld {
+		return f.headingToPixel(heading)
+	}
+	return heading
+}
+
+// lengthScale 原图像素长度到frameType坐标系长度的比例，各坐标系间均为等比变换
+func (f *mapFrame) lengthScale(frameType string) float64 {
+	switch frameType {
+	case apimodel.FrameWorld:
+		return f.resolution
+	case apimodel.FrameCompressed:
+		return f.compress
+	}
+	return 1
+}
+
+// frameConverter 按地图切片缓存坐标变换，用于跨切片列表批量转换到frameType坐标系
+type frameConverter struct {
+	operator  *ResourceOperator
+	frameType string
+	frames    map[int]*mapFrame
+}
+
+func (operator *ResourceOperator) newFrameConverter(frameType string) *frameConverter {
+	return &frameConverter{operator: operator, frameType: frameType, frames: make(map[int]*mapFrame)}
+}
+
+func (c *frameConverter) frame(infoID int) (*mapFrame, error) {
+	if frame, ok := c.frames[infoID]; ok {
+		return frame, nil
+	}
+	var mapInfo model.MapInfo
+	err := c.operator.Database.GetEntityByID(model.TableNameMapInfo, infoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	frame, err := newMapFrame(mapInfo)
+	if err != nil {
+		return nil, err
+	}
+	c.frames[infoID] = frame
+	return frame, nil
+}
+
+func (c *frameConverter) nodes(nodes []apimodel.RouteNodesInfo) error {
+	for i := range nodes {
+		frame, err := c.frame(nodes[i].InfoID)
+		if err != nil {
+			return err
+		}
+		nodes[i].Roi = frame.convert(nodes[i].Roi, c.frameType)
+	}
+	return nil
+}
+
+func (c *frameConverter) zones(zones []apimodel.MapZoneInfo) error {
+	for i := range zones {
+		frame, err := c.frame(zones[i].InfoID)
+		if err != nil {
+			return err
+		}
+		points := toPoints(zones[i].Polygon)
+		for j, p := range points {
+			target := frame.convert(pq.Float64Array{p[0], p[1]}, c.frameType)
+			points[j] = [2]float64{target[0], target[1]}
+		}
+		zones[i].Polygon = flattenPoints(points)
+		if zones[i].Heading != nil {
+			heading := frame.convertHeading(*zones[i].Heading, c.frameType)
+			zones[i].Heading = &heading
+		}
+	}
+	return nil
+}
+
+func (c *frameConverter) routes(routes []apimodel.MapRoutesInfo) error {
+	for i := range routes {
+		frame, err := c.frame(routes[i].InfoID)
+		if err != nil {
+			return err
+		}
+		routes[i].StartRoi = frame.convert(routes[i].StartRoi, c.frameType)
+		routes[i].EndRoi = frame.convert(routes[i].EndRoi, c.frameType)
+		control := toPoints(routes[i].ControlPoints)
+		for j, p := range control {
+			target := frame.convert(pq.Float64Array{p[0], p[1]}, c.frameType)
+			control[j] = [2]float64{target[0], target[1]}
+		}
+		if len(control) > 0 {
+			routes[i].ControlPoints = flattenPoints(control)
+		}
+		//几何按目标坐标系重新计算长度
+		if len(routes[i].Geometry) > 0 {
+			points := make([][2]float64, 0, len(routes[i].Geometry))
+			for j := range routes[i].Geometry {
+				routes[i].Geometry[j] = frame.convert(routes[i].Geometry[j], c.frameType)
+				points = append(points, [2]float64{routes[i].Geometry[j][0], routes[i].Geometry[j][1]})
+			}
+			routes[i].Length = utils.PolylineLength(points)
+		}
+	}
+	return nil
+}
+
+// reproject 原图像素坐标经世界坐标换算为to图片的像素坐标
+func (f *mapFrame) reproject(p pq.Float64Array, to *mapFrame) pq.Float64Array {
+	if len(p) < 2 {
+		return p
+	}
+	return to.toPixel(f.toWorld(p))
+}
+
+func (f *mapFrame) reprojectPoints(values pq.Float64Array, to *mapFrame) pq.Float64Array {
+	points := toPoints(values)
+	if len(points) == 0 {
+		return values
+	}
+	for i, p := range points {
+		target := f.reproject(pq.Float64Array{p[0], p[1]}, to)
+		points[i] = [2]float64{target[0], target[1]}
+	}
+	return flattenPoints(points)
+}
+
+// reprojectSlice 切片地图图片更换坐标系后，将节点坐标、路径控制点与区域多边形经世界坐标换算到新图片的像素坐标，
+// 返回换算的数据条数。切片为空时不读取from
+func (operator *ResourceOperator) reprojectSlice(infoID int, from func() (*mapFrame, error), to *mapFrame) (int, error) {
+	var nodes []model.MapRouteNodes
+	var routes []model.MapRoutes
+	var zones []model.MapZone
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	err := operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.QueryParams{}, &nodes)
+	if err != nil {
+		return 0, err
+	}
+	err = operator.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, model.QueryParams{}, &routes)
+	if err != nil {
+		return 0, err
+	}
+	err = operator.Database.ListEntityByFilter(model.TableNameMapZone, selector, model.QueryParams{}, &zones)
+	if err != nil {
+		return 0, err
+	}
+	total := len(nodes) + len(routes) + len(zones)
+	if total == 0 {
+		return 0, nil
+	}
+	frame, err := from()
+	if err != nil {
+		return 0, fmt.Errorf("原地图坐标系读取失败,无法换算切片内的节点、路径与区域: %v", err)
+	}
+	for i := range nodes {
+		nodes[i].Roi = frame.reproject(nodes[i].Roi, to)
+		if err = operator.Database.SaveEntity(model.TableNameMapRouteNodes, &nodes[i]); err != nil {
+			log.Error("地图路径节点更新失败,err:[%v]", err)
+			return 0, err
+		}
+	}
+	for i := range routes {
+		routes[i].ControlPoints = frame.reprojectPoints(routes[i].ControlPoints, to)
+		routes[i].StartRoi = frame.reproject(routes[i].StartRoi, to)
+		routes[i].EndRoi = frame.reproject(routes[i].EndRoi, to)
+		if err = operator.Database.SaveEntity(model.TableNameMapRoutes, &routes[i]); err != nil {
+			log.Error("地图路径更新失败,err:[%v]", err)
+			return 0, err
+		}
+	}
+	for i := range zones {
+		zones[i].Polygon = frame.reprojectPoints(zones[i].Polygon, to)
+		if zones[i].Heading != nil {
+			heading := to.headingToPixel(frame.headingToWorld(*zones[i].Heading))
+			zones[i].Heading = &heading
+		}
+		if err = operator.Database.SaveEntity(model.TableNameMapZone, &zones[i]); err != nil {
+			log.Error("区域数据更新失败. err:[%v]", err)
+			return 0, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"container/heap"
+	"demo-gogo/api/apimodel"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"math"
+	"time"
+)
+
+// navEdge 导航图中的有向边，对应路径的一个通行方向或楼层连接器的一个通行方向
+type navEdge struct {
+	RouteID     int
+	ConnectorID int //楼层连接器id，路径边为0
+	From        int
+	To          int
+	Drive       string  //该方向的行驶方式
+	Length      float64 //路径长度(像素)
+	Cost        float64 //通行代价
+	MaxSpeed    float64 //最大速度(米/秒)，0表示不限速
+	SoundOff    bool    //途经静音区
+}
+
+// navOptions 构建导航图时对路径的过滤条件
+type navOptions struct {
+	at         time.Time //出发时间，处于不可通行时间窗内的路径不加入导航图
+	robotWidth float64   //机器人宽度(米)，通道宽度不足的路径不加入导航图，0不限制
+}
+
+func newNavOptions(opt apimodel.PlanOptions) navOptions {
+	options := navOptions{at: time.Now(), robotWidth: opt.RobotWidth}
+	if opt.DepartAt != nil {
+		options.at = time.Time(*opt.DepartAt)
+	}
+	return options
+}
+
+// navGraph 由地图切片节点、路径构建的带权有向图，节点以ID为键。单向路径只生成允许方向的边。
+// 多个切片可通过楼层连接器合并为一张图，此时names仅在单切片图中有意义
+type navGraph struct {
+	nodes      map[int]apimodel.RouteNodesInfo
+	names      map[string]int
+	edges      map[int][]navEdge
+	multiFloor bool    //含楼层连接器时不同楼层像素坐标不可比，退化为Dijkstra
+	minFactor  float64 //路径代价系数的最小值，用于保证A*启发函数不高估
+	options    navOptions
+}
+
+func newNavGraph(nodes []apimodel.RouteNodesInfo, routes []apimodel.MapRoutesInfo, zones []model.MapZone, options navOptions) *navGraph {
+	g := &navGraph{
+		nodes:     make(map[int]apimodel.RouteNodesInfo),
+		names:     make(map[string]int),
+		edges:     make(map[int][]navEdge),
+		minFactor: 1,
+		options:   options,
+	}
+	g.addSlice(nodes, routes, zones)
+	return g
+}
+
+// addSlice 加入一个地图切片的节点与路径，路径按节点id引用起终点，仅连接本切片内的节点。
+// 穿越禁行区的路径不加入导航图，逆向穿越单向区的方向不生成边，途经限速区按限速折算通行代价
+func (g *navGraph) addSlice(nodes []apimodel.RouteNodesInfo, routes []apimodel.MapRoutesInfo, zones []model.MapZone) {
+	ids := make(map[int]struct{}, len(nodes))
+	for _, v := range nodes {
+		g.nodes[v.ID] = v
+		g.names[v.NodeName] = v.ID
+		ids[v.ID] = struct{}{}
+	}
+	for _, v := range routes {
+		//通道宽度不足或处于不可通行时间窗的路径不参与规划
+		if g.options.robotWidth > 0 && v.Width > 0 && v.Width < g.options.robotWidth {
+			continue
+		}
+		if v.ClosedWindows.Contains(g.options.at) {
+			continue
+		}
+		startID, endID := v.StartNodeID, v.EndNodeID
+		if _, ok := ids[startID]; !ok {
+			continue
+		}
+		if _, ok := ids[endID]; !ok || startID == endID {
+			continue
+		}
+		length, ok := g.distance(startID, endID)
+		if !ok {
+			continue
+		}
+		//折线、曲线路径使用实际几何长度
+		if v.Length > 0 {
+			length = v.Length
+		}
+		effect := zoneEffect{forward: true, backward: true}
+		if len(zones) > 0 {
+			points := make([][2]float64, 0, len(v.Geometry))
+			for _, p := range v.Geometry {
+				points = append(points, [2]float64{p[0], p[1]})
+			}
+			effect = routeZoneEffect(points, zones)
+		}
+		if effect.blocked {
+			continue
+		}
+		//通行代价按人工代价系数与优先级加权，限速区的额外代价以路径限速(未限速时为机器人默认速度)为参考
+		factor := model.RouteCostFactor(v.CostFactor, v.Priority)
+		if factor < g.minFactor {
+			g.minFactor = factor
+		}
+		speed := v.MaxSpeed
+		if speed <= 0 {
+			speed = config.Conf.Map.RobotSpeed
+		}
+		cost := (length + effect.slowCost(speed)) * factor
+		maxSpeed := minPositive(v.MaxSpeed, effect.maxSpeed)
+		//按运行规则生成各方向的有向边
+		if model.PathRoleAllowStartToEnd(v.PathRole) && effect.forward {
+			g.addEdge(navEdge{RouteID: v.ID, From: startID, To: endID, Drive: model.DriveOrDefault(v.StartToEnd), Length: length, Cost: cost, MaxSpeed: maxSpeed, SoundOff: effect.soundOff})
+		}
+		if model.PathRoleAllowEndToStart(v.PathRole) && effect.backward {
+			g.addEdge(navEdge{RouteID: v.ID, From: endID, To: startID, Drive: model.DriveOrDefault(v.EndToStart), Length: length, Cost: cost, MaxSpeed: maxSpeed, SoundOff: effect.soundOff})
+		}
+	}
+}
+
+// addConnector 加入楼层连接器，连接的节点不在图中时忽略
+func (g *navGraph) addConnector(connector model.MapConnector) {
+	if _, ok := g.nodes[connector.FromNodeID]; !ok {
+		return
+	}
+	if _, ok := g.nodes[connector.ToNodeID]; !ok {
+		return
+	}
+	g.multiFloor = true
+	g.addEdge(navEdge{ConnectorID: connector.ID, From: connector.FromNodeID, To: connector.ToNodeID, Cost: connector.Cost})
+	if connector.Bidirectional {
+		g.addEdge(navEdge{ConnectorID: connector.ID, From: connector.ToNodeID, To: connector.FromNodeID, Cost: connector.Cost})
+	}
+}
+
+func (g *navGraph) addEdge(edge navEdge) {
+	g.edges[edge.From] = append(g.edges[edge.From], edge)
+}
+
+// distance 两节点坐标间的欧氏距离，坐标缺失时返回false
+func (g *navGraph) distance(from, to int) (float64, bool) {
+	a, b := g.nodes[from].Roi, g.nodes[to].Roi
+	if len(a) < 2 || len(b) < 2 {
+		return 0, false
+	}
+	return math.Hypot(a[0]-b[0], a[1]-b[1]), true
+}
+
+// shortestPath A*搜索最短路径，启发函数为到终点的欧氏距离(多楼层图为0)。返回途经节点与路径
+func (g *navGraph) shortestPath(start, end int) ([]int, []navEdge, bool) {
+	if _, ok := g.nodes[end]; !ok {
+		return nil, nil, false
+	}
+	heuristic := func(id int) float64 {
+		if g.multiFloor {
+			return 0
+		}
+		h, _ := g.distance(id, end)
+		return h * g.minFactor
+	}
+	_, nodes, edges, ok := g.search(start, func(id int) bool { return id == end }, heuristic)
+	return nodes, edges, ok
+}
+
+// nearest Dijkstra搜索通行代价最小的满足isGoal的节点(含起点本身)，返回该节点、途经节点与路径
+func (g *navGraph) nearest(start int, isGoal func(id int) bool) (int, []int, []navEdge, bool) {
+	return g.search(start, isGoal, func(int) float64 { return 0 })
+}
+
+// search 自start出发的最佳优先搜索，首个出队的目标节点即为代价最小的目标
+func (g *navGraph) search(start int, isGoal func(id int) bool, heuristic func(id int) float64) (int, []int, []navEdge, bool) {
+	if _, ok := g.nodes[start]; !ok {
+		return 0, nil, nil, false
+	}
+	cost := map[int]float64{start: 0}
+	prev := make(map[int]navEdge)
+	closed := make(map[int]struct{})
+	queue := &navQueue{}
+	heap.Push(queue, navItem{node: start, priority: heuristic(start)})
+	end, found := 0, false
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(navItem)
+		if _, ok := closed[item.node]; ok {
+			continue
+		}
+		if isGoal(item.node) {
+			end, found = item.node, true
+			break
+		}
+		closed[item.node] = struct{}{}
+		for _, edge := range g.edges[item.node] {
+			if _, ok := closed[edge.To]; ok {
+				continue
+			}
+			next := cost[item.node] + edge.Cost
+			if old, ok := cost[edge.To]; ok && old <= next {
+				continue
+			}
+			cost[edge.To] = next
+			prev[edge.To] = edge
+			heap.Push(queue, navItem{node: edge.To, priority: next + heuristic(edge.To)})
+		}
+	}
+	if !found {
+		return 0, nil, nil, false
+	}
+	//回溯路径
+	var edges []navEdge
+	nodes := []int{end}
+	for current := end; current != start; {
+		edge := prev[current]
+		edges = append([]navEdge{edge}, edges...)
+		nodes = append([]int{edge.From}, nodes...)
+		current = edge.From
+	}
+	return end, nodes, edges, true
+}
+
+type navItem struct {
+	node     int
+	priority float64
+}
+
+// navQueue 按priority排序的最小堆
+type navQueue []navItem
+
+func (q navQueue) Len() int            { return len(q) }
+func (q navQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q navQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *navQueue) Push(x interface{}) { *q = append(*q, x.(navItem)) }
+func (q *navQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
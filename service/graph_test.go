@@ -0,0 +1,280 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"github.com/lib/pq"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testSquare 边长10的正方形，节点1-4按逆时针排列，1-2-3为最短路线，1-4-3的代价系数为2
+func testSquare() ([]apimodel.RouteNodesInfo, []apimodel.MapRoutesInfo) {
+	nodes := []apimodel.RouteNodesInfo{
+		{ID: 1, NodeName: "A", Roi: pq.Float64Array{0, 0}},
+		{ID: 2, NodeName: "B", Roi: pq.Float64Array{10, 0}},
+		{ID: 3, NodeName: "C", Roi: pq.Float64Array{10, 10}},
+		{ID: 4, NodeName: "D", Roi: pq.Float64Array{0, 10}},
+	}
+	routes := []apimodel.MapRoutesInfo{
+		{ID: 12, StartNodeID: 1, EndNodeID: 2},
+		{ID: 23, StartNodeID: 2, EndNodeID: 3},
+		{ID: 14, StartNodeID: 1, EndNodeID: 4, CostFactor: 2},
+		{ID: 43, StartNodeID: 4, EndNodeID: 3},
+	}
+	for i := range routes {
+		routes[i].StartRoi = nodes[routes[i].StartNodeID-1].Roi
+		routes[i].EndRoi = nodes[routes[i].EndNodeID-1].Roi
+		fillRouteGeometry(&routes[i])
+	}
+	return nodes, routes
+}
+
+func TestNavGraphShortestPath(t *testing.T) {
+	if config.Conf == nil {
+		config.Conf = &config.DefaultConfig
+	}
+	at := time.Date(2024, 1, 1, 12, 30, 0, 0, time.Local)
+	direct, detour := []int{1, 2, 3}, []int{1, 4, 3}
+	tests := []struct {
+		name       string
+		edit       func(routes []apimodel.MapRoutesInfo)
+		zones      []model.MapZone
+		robotWidth float64
+		want       []int
+		wantOK     bool
+	}{
+		{name: "最短路径", want: direct, wantOK: true},
+		{
+			name: "主干优先",
+			edit: func(routes []apimodel.MapRoutesInfo) {
+				routes[2].CostFactor, routes[2].Priority = 1, model.RoutePriorityMain
+			},
+			want:   detour,
+			wantOK: true,
+		},
+		{
+			name:   "单向路径不可逆行",
+			edit:   func(routes []apimodel.MapRoutesInfo) { routes[1].PathRole = model.PathRoleEndToStart },
+			want:   detour,
+			wantOK: true,
+		},
+		{
+			name:   "单向路径正向通行",
+			edit:   func(routes []apimodel.MapRoutesInfo) { routes[1].PathRole = model.PathRoleStartToEnd },
+			want:   direct,
+			wantOK: true,
+		},
+		{
+			name:       "通道宽度不足",
+			edit:       func(routes []apimodel.MapRoutesInfo) { routes[0].Width = 0.5 },
+			robotWidth: 0.8,
+			want:       detour,
+			wantOK:     true,
+		},
+		{
+			name: "处于不可通行时间窗",
+			edit: func(routes []apimodel.MapRoutesInfo) {
+				routes[0].ClosedWindows = model.RouteTimeWindows{{Start: "12:00", End: "13:00"}}
+			},
+			want:   detour,
+			wantOK: true,
+		},
+		{
+			name: "不在不可通行时间窗",
+			edit: func(routes []apimodel.MapRoutesInfo) {
+				routes[0].ClosedWindows = model.RouteTimeWindows{{Start: "13:00", End: "14:00"}}
+			},
+			want:   direct,
+			wantOK: true,
+		},
+		{
+			name:   "禁行区",
+			zones:  []model.MapZone{{Type: model.ZoneForbidden, Polygon: pq.Float64Array{8, 4, 12, 4, 12, 6, 8, 6}}},
+			want:   detour,
+			wantOK: true,
+		},
+		{
+			name: "不可达",
+			edit: func(routes []apimodel.MapRoutesInfo) {
+				routes[1].PathRole = model.PathRoleEndToStart
+				routes[3].PathRole = model.PathRoleEndToStart
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, routes := testSquare()
+			if tt.edit != nil {
+				tt.edit(routes)
+			}
+			g := newNavGraph(nodes, routes, tt.zones, navOptions{at: at, robotWidth: tt.robotWidth})
+			got, edges, ok := g.shortestPath(1, 3)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("shortestPath() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+			for i, edge := range edges {
+				if edge.From != got[i] || edge.To != got[i+1] {
+					t.Errorf("第%d条边 = %d->%d, want %d->%d", i, edge.From, edge.To, got[i], got[i+1])
+				}
+			}
+		})
+	}
+}
+
+func TestNavGraphEdges(t *testing.T) {
+	if config.Conf == nil {
+		config.Conf = &config.DefaultConfig
+	}
+	tests := []struct {
+		name      string
+		route     apimodel.MapRoutesInfo
+		zones     []model.MapZone
+		wantDrive map[int]string //按起点索引各方向边的行驶方式
+		wantCost  float64
+		wantSpeed float64
+		wantQuiet bool
+	}{
+		{
+			name:      "双向默认正向行走",
+			route:     apimodel.MapRoutesInfo{ID: 12, StartNodeID: 1, EndNodeID: 2},
+			wantDrive: map[int]string{1: model.DriveForward, 2: model.DriveForward},
+			wantCost:  10,
+		},
+		{
+			name:      "单向倒车",
+			route:     apimodel.MapRoutesInfo{ID: 12, StartNodeID: 1, EndNodeID: 2, PathRole: model.PathRoleStartToEnd, StartToEnd: model.DriveBackward},
+			wantDrive: map[int]string{1: model.DriveBackward},
+			wantCost:  10,
+		},
+		{
+			name:      "限速区按限速折算代价",
+			route:     apimodel.MapRoutesInfo{ID: 12, StartNodeID: 1, EndNodeID: 2, MaxSpeed: 2},
+			zones:     []model.MapZone{{Type: model.ZoneSlow, MaxSpeed: 1, Polygon: pq.Float64Array{0, -1, 5, -1, 5, 1, 0, 1}}},
+			wantDrive: map[int]string{1: model.DriveForward, 2: model.DriveForward},
+			wantCost:  15,
+			wantSpeed: 1,
+		},
+		{
+			name:      "静音区",
+			route:     apimodel.MapRoutesInfo{ID: 12, StartNodeID: 1, EndNodeID: 2},
+			zones:     []model.MapZone{{Type: model.ZoneSoundOff, Polygon: pq.Float64Array{4, -1, 6, -1, 6, 1, 4, 1}}},
+			wantDrive: map[int]string{1: model.DriveForward, 2: model.DriveForward},
+			wantCost:  10,
+			wantQuiet: true,
+		},
+		{
+			name:      "单向区只保留顺向",
+			route:     apimodel.MapRoutesInfo{ID: 12, StartNodeID: 1, EndNodeID: 2},
+			zones:     []model.MapZone{{Type: model.ZoneOneWay, Heading: new(float64), Polygon: pq.Float64Array{4, -1, 6, -1, 6, 1, 4, 1}}},
+			wantDrive: map[int]string{1: model.DriveForward},
+			wantCost:  10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, _ := testSquare()
+			route := tt.route
+			route.StartRoi, route.EndRoi = nodes[0].Roi, nodes[1].Roi
+			fillRouteGeometry(&route)
+			g := newNavGraph(nodes[:2], []apimodel.MapRoutesInfo{route}, tt.zones, navOptions{at: time.Now()})
+			drives := make(map[int]string)
+			for from, edges := range g.edges {
+				for _, edge := range edges {
+					drives[from] = edge.Drive
+					if edge.Cost != tt.wantCost || edge.MaxSpeed != tt.wantSpeed || edge.SoundOff != tt.wantQuiet {
+						t.Errorf("边%d->%d = %+v, want cost %v maxSpeed %v soundOff %v", edge.From, edge.To, edge, tt.wantCost, tt.wantSpeed, tt.wantQuiet)
+					}
+				}
+			}
+			if !reflect.DeepEqual(drives, tt.wantDrive) {
+				t.Errorf("各方向行驶方式 = %v, want %v", drives, tt.wantDrive)
+			}
+		})
+	}
+}
+
+func TestNavGraphNearest(t *testing.T) {
+	if config.Conf == nil {
+		config.Conf = &config.DefaultConfig
+	}
+	tests := []struct {
+		name     string
+		start    int
+		goals    []int
+		wantNode int
+		wantPath []int
+		wantOK   bool
+	}{
+		{"起点即目标", 1, []int{1, 3}, 1, []int{1}, true},
+		{"代价最小的目标", 1, []int{2, 4}, 2, []int{1, 2}, true},
+		{"无目标", 1, nil, 0, nil, false},
+		{"起点不存在", 9, []int{1}, 0, nil, false},
+	}
+	nodes, routes := testSquare()
+	g := newNavGraph(nodes, routes, nil, navOptions{at: time.Now()})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isGoal := func(id int) bool {
+				for _, v := range tt.goals {
+					if v == id {
+						return true
+					}
+				}
+				return false
+			}
+			node, path, _, ok := g.nearest(tt.start, isGoal)
+			if node != tt.wantNode || !reflect.DeepEqual(path, tt.wantPath) || ok != tt.wantOK {
+				t.Errorf("nearest() = %v, %v, %v, want %v, %v, %v", node, path, ok, tt.wantNode, tt.wantPath, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNavGraphConnector(t *testing.T) {
+	if config.Conf == nil {
+		config.Conf = &config.DefaultConfig
+	}
+	upper := []apimodel.RouteNodesInfo{
+		{ID: 5, NodeName: "E", Roi: pq.Float64Array{10, 10}},
+		{ID: 6, NodeName: "F", Roi: pq.Float64Array{0, 0}},
+	}
+	upperRoutes := []apimodel.MapRoutesInfo{{ID: 56, StartNodeID: 5, EndNodeID: 6, StartRoi: upper[0].Roi, EndRoi: upper[1].Roi}}
+	tests := []struct {
+		name          string
+		bidirectional bool
+		start, end    int
+		want          []int
+		wantOK        bool
+	}{
+		{"跨楼层", false, 1, 6, []int{1, 2, 3, 5, 6}, true},
+		{"单向连接器不可反向", false, 6, 1, nil, false},
+		{"双向连接器", true, 6, 1, []int{6, 5, 3, 2, 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, routes := testSquare()
+			g := newNavGraph(nodes, routes, nil, navOptions{at: time.Now()})
+			g.addSlice(upper, upperRoutes, nil)
+			connector := model.MapConnector{FromNodeID: 3, ToNodeID: 5, Cost: 5, Bidirectional: tt.bidirectional}
+			connector.ID = 1
+			g.addConnector(connector)
+			//连接器端点不在图中时忽略
+			g.addConnector(model.MapConnector{FromNodeID: 3, ToNodeID: 99, Cost: 1})
+			got, edges, ok := g.shortestPath(tt.start, tt.end)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("shortestPath() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+			if !g.multiFloor {
+				t.Error("加入连接器后应按多楼层图搜索")
+			}
+			for _, edge := range edges {
+				if (edge.From == 3 || edge.From == 5) && (edge.To == 3 || edge.To == 5) && edge.ConnectorID != 1 {
+					t.Errorf("楼层间的边 = %+v, want 连接器1", edge)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/utils/redis"
+	"errors"
+	log "github.com/wonderivan/logger"
+	"time"
+)
+
+const mapJobLockTime = 2 * time.Hour //后台任务锁有效期，任务结束时释放
+
+// mapJob 地图切片的后台任务(瓦片、占据栅格等)，同一切片同类任务同时只允许一个，进度记录在Redis
+type mapJob struct {
+	key      string //进度Redis Key
+	uuid     string //任务锁uuid
+	progress redis.ProgressStruct
+}
+
+// startMapJob 获取任务锁并重置进度，任务已在运行时返回running错误
+func startMapJob(key string, params interface{}, running string) (*mapJob, error) {
+	uuid, err := redis.LockWithTimeout(key+":lock", 0, mapJobLockTime)
+	if err != nil {
+		if errors.Is(err, redis.LockTimeOut) {
+			return nil, errors.New(running)
+		}
+		return nil, err
+	}
+	_ = redis.RedisClient.Del(key).Err()
+	if err = redis.InitRedisProgress(0, key, model.MapJobProgress, params); err != nil {
+		_ = redis.UnLock(key+":lock", uuid)
+		return nil, err
+	}
+	return &mapJob{key: key, uuid: uuid, progress: redis.ProgressStruct{Status: redis.Processing}}, nil
+}
+
+// update 写入当前进度，ratio由total与processed计算
+func (job *mapJob) update() {
+	if job.progress.Total > 0 {
+		job.progress.Ratio = float64(job.progress.Processed) / float64(job.progress.Total)
+	}
+	if err := redis.UpdateRedisProgress(job.key, model.MapJobProgress, &job.progress); err != nil {
+		log.Warn("后台任务[%s]进度更新失败. err:[%v]", job.key, err)
+	}
+}
+
+// finish 记录任务结果并释放任务锁
+func (job *mapJob) finish(err error, message string) {
+	job.progress.Status = redis.ProcessEnd
+	job.progress.Result = redis.ResultSuccess
+	job.progress.Message = message
+	if err != nil {
+		job.progress.Result = redis.ResultFailed
+		job.progress.Message = err.Error()
+	}
+	job.update()
+	_ = redis.UnLock(job.key+":lock", job.uuid)
+}
+
+// readMapJobProgress 最近一次后台任务的进度，未运行过时返回nil
+func readMapJobProgress(key string) *apimodel.MapJobProgress {
+	progress, err := redis.ReadProgressFromRedis(key, model.MapJobProgress)
+	if err != nil {
+		log.Warn("后台任务[%s]进度读取失败. err:[%v]", key, err)
+		return nil
+	}
+	if progress == nil {
+		return nil
+	}
+	return &apimodel.MapJobProgress{
+		Status:    progress.Status,
+		Message:   progress.Message,
+		Total:     progress.Total,
+		Processed: progress.Processed,
+		Ratio:     progress.Ratio,
+		Result:    progress.Result,
+	}
+}
@@ -10,13 +10,8 @@ import (
 	"github.com/lib/pq"
 	log "github.com/wonderivan/logger"
 	"gorm.io/gorm"
-	"image/color"
-	"image/jpeg"
-	"image/png"
-	"os"
-	"path"
-	"strconv"
-	"strings"
+	"math"
+	"time"
 )
 
 func (operator *ResourceOperator) CreateOrUpdateMap(req *apimodel.MapRequest) error {
@@ -97,25 +92,36 @@ func (operator *ResourceOperator) ListMap(req *apimodel.MapRequest) (*apimodel.M
 	return &resp, nil
 }
 
-func (operator *ResourceOperator) DeleteMap(req *apimodel.MapRequest) error {
-	selector := make(map[string]interface{})
-	queryParams := model.QueryParams{}
-	selector[model.FieldID] = req.ID
-	err := operator.Database.DeleteEntityByFilter(model.TableNameMap, selector, queryParams, &model.Map{})
+// DeleteMap 级联删除地图及其切片、节点、路径与连接器，dry_run时仅统计
+func (operator *ResourceOperator) DeleteMap(req *apimodel.MapRequest) (*apimodel.CascadeDeleteResponse, error) {
+	var mapDB model.Map
+	err := operator.Database.GetEntityByID(model.TableNameMap, req.ID, &mapDB)
 	if err != nil {
-		log.Error("地图数据删除失败. err:[%v]", err)
-		return err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图")
+		}
+		return nil, err
 	}
-	return nil
+	//切片在级联删除的事务内锁定地图后查询
+	return operator.cascadeDelete(cascadeScope{mapIDs: []int{req.ID}}, req.DryRun)
 }
 
 func (operator *ResourceOperator) CreateOrUpdateMapInfo(req *apimodel.MapInfoRequest) error {
 	var opt model.MapInfo
 	var mapDB model.Map
 	selector := make(map[string]interface{})
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("CreateOrUpdateMapInfo TransactionBegin Error.err[%v]", err)
+		return err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
 	// 名称唯一性
 	selector[model.FieldName] = req.Name
-	err := operator.Database.ListEntityByFilter(model.TableNameMapInfo, selector, model.OneQuery, &opt)
+	err = tx.Database.ListEntityByFilter(model.TableNameMapInfo, selector, model.OneQuery, &opt)
 	if err != nil {
 		return err
 	}
@@ -124,38 +130,70 @@ func (operator *ResourceOperator) CreateOrUpdateMapInfo(req *apimodel.MapInfoReq
 	}
 
 	if req.ID > 0 {
-		err = operator.Database.GetEntityByID(model.TableNameMapInfo, req.ID, &opt)
+		err = tx.Database.GetEntityForUpdate(model.TableNameMapInfo, req.ID, &opt)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "待修改地图信息")
 			}
 			return err
 		}
+		err = assertEditLock(req.ID, req.EditContext)
+		if err != nil {
+			return err
+		}
 	}
-	err = operator.Database.GetEntityByID(model.TableNameMap, req.MapID, &mapDB)
+	err = tx.Database.GetEntityByID(model.TableNameMap, req.MapID, &mapDB)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "关联地图")
 		}
 		return err
 	}
+	oldMapURL, oldPointCloud, oldCompress, oldScale := opt.MapURL, opt.PointCloud, opt.MapURLCompress, opt.CompressScale
 	err = copier.Copy(&opt, req)
 	if err != nil {
 		return err
 	}
+	// 客户端回传的是文件存储生成的访问地址，保存前还原为存储key，不属于文件存储的地址不予保存
+	if opt.MapURL, err = storedKey(opt.MapURL, oldMapURL, "map_url"); err != nil {
+		return err
+	}
+	if opt.PointCloud, err = storedKey(opt.PointCloud, oldPointCloud, "point_cloud"); err != nil {
+		return err
+	}
+	// 压缩图片由服务端维护：地图图片变更时清空并在后台重新生成，否则保持不变
+	opt.MapURLCompress, opt.CompressScale = oldCompress, oldScale
+	var replaced string
+	var staleTiles tilePyramid
+	mapChanged := opt.MapURL != oldMapURL
+	if mapChanged {
+		opt.MapURLCompress, opt.CompressScale = "", 0
+		replaced = oldCompress
+		staleTiles = resetMapTiles(&opt)
+	}
 	if req.ID > 0 {
-		err = operator.Database.SaveEntity(model.TableNameMapInfo, &opt)
+		err = tx.Database.SaveEntity(model.TableNameMapInfo, &opt)
 		if err != nil {
 			log.Error("地图信息数据更新失败. err:[%v]", err)
 			return err
 		}
 	} else {
-		err = operator.Database.CreateEntity(model.TableNameMapInfo, &opt)
+		err = tx.Database.CreateEntity(model.TableNameMapInfo, &opt)
 		if err != nil {
 			log.Error("地图信息数据创建失败. err:[%v]", err)
 			return err
 		}
 	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("CreateOrUpdateMapInfo TransactionCommit Error.err[%v]", err)
+		return err
+	}
+	removeStoredFile(replaced)
+	go staleTiles.remove()
+	if mapChanged && opt.MapURL != "" {
+		operator.startCompressJob(opt.ID)
+	}
 	return nil
 }
 
@@ -199,22 +237,22 @@ func (operator *ResourceOperator) ListMapInfoPageResponse(req *apimodel.MapInfoR
 	return &resp, nil
 }
 
-func (operator *ResourceOperator) DeleteMapInfo(req *apimodel.MapInfoRequest) error {
-	selector := make(map[string]interface{})
-	queryParams := model.QueryParams{}
-	selector[model.FieldID] = req.ID
-	err := operator.Database.DeleteEntityByFilter(model.TableNameMapInfo, selector, queryParams, &model.MapInfo{})
+// DeleteMapInfo 级联删除地图切片及其节点、路径与连接器，dry_run时仅统计
+func (operator *ResourceOperator) DeleteMapInfo(req *apimodel.MapInfoRequest) (*apimodel.CascadeDeleteResponse, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, req.ID, &mapInfo)
 	if err != nil {
-		log.Error("地图数据删除失败. err:[%v]", err)
-		return err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
 	}
-	return nil
+	return operator.cascadeDelete(cascadeScope{infoIDs: []int{req.ID}}, req.DryRun)
 }
 
 func (operator *ResourceOperator) CreateOrUpdateNode(req *apimodel.RouteNodesRequest) error {
 	var opt model.MapRouteNodes
 	var mapList model.MapInfo
-	var startNode model.MapRouteNodes
 	var routeCreate []model.MapRoutes
 	selector := make(map[string]interface{})
 	// 开启事务
@@ -228,50 +266,35 @@ func (operator *ResourceOperator) CreateOrUpdateNode(req *apimodel.RouteNodesReq
 	}()
 
 	//验证map_id是否存在
-	err = operator.Database.GetEntityByID(model.TableNameMapInfo, req.InfoID, &mapList)
+	err = tx.Database.GetEntityByID(model.TableNameMapInfo, req.InfoID, &mapList)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图关联路径节点")
 		}
 		return err
 	}
+	_, err = tx.lockSliceForEdit(req.InfoID, req.EditContext)
+	if err != nil {
+		return err
+	}
 	//同一map_id中名称的唯一性
 	selector[model.FieldInfoId] = req.InfoID
 	selector[model.FieldName] = req.NodeName
-	err = operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.OneQuery, &opt)
+	err = tx.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.OneQuery, &opt)
 	if err != nil {
 		return err
 	}
 	if opt.ID != 0 && opt.ID != req.ID {
 		return fmt.Errorf(errcode.ErrorMsgSuffixParamExists, "地图路径节点")
 	}
-
-	selector = make(map[string]interface{})
-	selector[model.FieldInfoId] = req.InfoID
-	//维护nodeName字段
-	params := model.OneQuery
-	order := model.Order{
-		Field:     model.FieldName,
-		Direction: apimodel.OrderDesc,
-	}
-	params.Orders = append(params.Orders, order)
-	err = tx.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, params, &startNode)
+	before, err := tx.loadSnapshot(req.InfoID)
 	if err != nil {
 		return err
 	}
-	nameNumber := 0
-	if startNode.ID > 0 {
-		nameNumber, _ = strconv.Atoi(strings.Split(startNode.NodeName, "Site")[1])
-	}
-	if nameNumber+1 < 10 {
-		req.NodeName = "Site" + "000" + strconv.Itoa(nameNumber+1)
-	} else if nameNumber+1 < 100 && nameNumber+1 >= 10 {
-		req.NodeName = "Site" + "00" + strconv.Itoa(nameNumber+1)
-	} else if nameNumber+1 < 1000 && nameNumber+1 >= 100 {
-		req.NodeName = "Site" + "0" + strconv.Itoa(nameNumber+1)
-	}
+
+	//nodeName不允许编辑，新增节点按地图命名规则生成，重命名使用RenameMapNode
 	if req.ID > 0 {
-		err = operator.Database.GetEntityByID(model.TableNameMapRouteNodes, req.ID, &opt)
+		err = tx.Database.GetEntityByID(model.TableNameMapRouteNodes, req.ID, &opt)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "待修改地图路径节点")
@@ -279,97 +302,71 @@ func (operator *ResourceOperator) CreateOrUpdateNode(req *apimodel.RouteNodesReq
 			return err
 		}
 		req.NodeName = opt.NodeName
+	} else {
+		namer, err := tx.newNodeNamer(req.InfoID)
+		if err != nil {
+			return err
+		}
+		req.NodeName = namer.next()
+		err = namer.save(tx)
+		if err != nil {
+			return err
+		}
 	}
-	//nodeName不允许编辑
 
 	err = copier.Copy(&opt, req)
 	if err != nil {
 		return err
 	}
 
-	//判断新增节点坐标是否处在任意一条线上
+	//先保存节点以获得节点id，拆分路径按id引用
+	if req.ID > 0 {
+		err = tx.Database.SaveEntity(model.TableNameMapRouteNodes, &opt)
+		if err != nil {
+			log.Error("地图路径节点更新失败,err:[%v]", err)
+			return err
+		}
+	} else {
+		err = tx.Database.CreateEntity(model.TableNameMapRouteNodes, &opt)
+		if err != nil {
+			log.Error("地图路径节点创建失败,err:[%v]", err)
+			return err
+		}
+	}
+
+	//判断新增节点坐标是否处在任意一条线上，是则吸附到路径并拆分路径
 	var routes []model.MapRoutes
-	var nodes, nodeRois []model.MapRouteNodes
-	nameMap := make(map[string]struct{})
+	var nodes []model.MapRouteNodes
 	selector = make(map[string]interface{})
 	selector[model.FieldInfoId] = req.InfoID
-	if err = operator.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, model.QueryParams{}, &routes); err != nil {
+	if err = tx.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, model.QueryParams{}, &routes); err != nil {
 		return err
 	}
-	err = operator.Database.GetEntityPluck(model.TableNameMapRouteNodes, selector, model.QueryParams{}, "name", &nodes)
-	if err != nil {
+	if err = tx.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.QueryParams{}, &nodes); err != nil {
 		return err
 	}
-	err = operator.Database.GetEntityPluck(model.TableNameMapRouteNodes, selector, model.QueryParams{}, "roi", &nodeRois)
+	splitter := newRouteSplitter(mapList, routes, nodes)
+	opt = splitter.snap(opt)
+	err = splitter.save(tx, time.Now())
 	if err != nil {
 		return err
 	}
-
-	for i := range nodes {
-		nodes[i].Roi = nodeRois[i].Roi
-	}
-	for _, v := range routes {
-		nameMap[v.RoutesName] = struct{}{}
-	}
-	for _, v := range routes {
-		//拿到路径的起始点、末尾点坐标
-		var roiH, roiE pq.Float64Array
-		nodeHeadName := v.Start
-		nodeEndName := v.End
-		for _, k := range nodes {
-			if k.NodeName == nodeHeadName {
-				roiH = k.Roi
-			}
-			if k.NodeName == nodeEndName {
-				roiE = k.Roi
-			}
-		}
-
-		if roiH != nil && roiE != nil {
-			flag, point := apimodel.PointToLine(req.Roi, roiH, roiE)
-			if flag {
-				req.Roi = point
-				//同一线段上
-				routeA := model.MapRoutes{RoutesName: nodeHeadName + "-" + req.NodeName, InfoID: req.InfoID, PathRole: "双向", Start: nodeHeadName, End: req.NodeName, StartToEnd: "正向行走", EndToStart: "正向行走"}
-				routeB := model.MapRoutes{RoutesName: req.NodeName + "-" + nodeEndName, InfoID: req.InfoID, PathRole: "双向", Start: req.NodeName, End: nodeEndName, StartToEnd: "正向行走", EndToStart: "正向行走"}
-				//略过首位相同点
-				if (routeA.Start == routeA.End) || (routeB.Start == routeB.End) {
-					continue
-				}
-				if _, ok := nameMap[routeA.RoutesName]; ok {
-					continue
-				}
-				nameMap[routeA.RoutesName] = struct{}{}
-				routeCreate = append(routeCreate, routeA)
-				if _, ok := nameMap[routeB.RoutesName]; ok {
-					continue
-				}
-				nameMap[routeB.RoutesName] = struct{}{}
-				routeCreate = append(routeCreate, routeB)
-			}
-		}
-	}
-
+	routeCreate = splitter.created
 	if routeCreate != nil {
-		err = operator.BatchCreateEntity(model.TableNameMapRoutes, routeCreate)
+		err = tx.Database.BatchCreateEntity(model.TableNameMapRoutes, routeCreate)
 		if err != nil {
 			log.Error("地图路径节点创建失败,err:[%v]", err)
 			return err
 		}
 	}
-
-	if req.ID > 0 {
-		err = operator.Database.SaveEntity(model.TableNameMapRouteNodes, &opt)
-		if err != nil {
-			log.Error("地图路径节点更新失败,err:[%v]", err)
-			return err
-		}
-	} else {
-		err = operator.Database.CreateEntity(model.TableNameMapRouteNodes, &opt)
-		if err != nil {
-			log.Error("地图路径节点创建失败,err:[%v]", err)
-			return err
-		}
+	err = tx.recordRevision(req.InfoID, before, req.EditContext, RevisionOpSaveNode)
+	if err != nil {
+		return err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("CreateOrUpdateNode TransactionCommit Error.err[%v]", err)
+		return err
 	}
 	return nil
 }
@@ -387,13 +384,22 @@ func (operator *ResourceOperator) ListMapNodes(req *apimodel.RouteNodesRequest)
 	if req.NodeName != "" {
 		selector[model.FieldName] = req.NodeName
 	}
+	if req.Type != "" {
+		selector[model.FieldType] = req.Type
+	}
 	var count int64
 	var nodes []model.MapRouteNodes
-	err := operator.Database.CountAllEntityByFilter(model.TableNameMapRouteNodes, selector, model.QueryParams{}, &count)
+	var err error
+	published := apimodel.ReadsPublished(req.Stage, req.InfoID)
+	if published {
+		nodes, count, err = operator.listPublishedNodes(req)
+	} else {
+		err = operator.Database.CountAllEntityByFilter(model.TableNameMapRouteNodes, selector, model.QueryParams{}, &count)
+	}
 	if err != nil {
 		return nil, err
 	}
-	if count > 0 {
+	if count > 0 && !published {
 		order := model.Order{
 			Field:     req.OrderBy,
 			Direction: apimodel.OrderAsc,
@@ -411,6 +417,14 @@ func (operator *ResourceOperator) ListMapNodes(req *apimodel.RouteNodesRequest)
 		}
 	}
 	resp.Load(count, nodes)
+	resp.Frame = apimodel.FramePixel
+	if !apimodel.IsPixelFrame(req.Frame) {
+		err = operator.newFrameConverter(req.Frame).nodes(resp.List)
+		if err != nil {
+			return nil, err
+		}
+		resp.Frame = req.Frame
+	}
 	return &resp, nil
 }
 
@@ -430,6 +444,7 @@ func (operator *ResourceOperator) DeleteMapNodes(req *apimodel.RouteNodesRequest
 	defer func() {
 		_ = tx.TransactionRollback()
 	}()
+	now := time.Now()
 	selector[model.FieldID] = req.ID
 	err = tx.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.OneQuery, &node)
 	if err != nil {
@@ -439,7 +454,19 @@ func (operator *ResourceOperator) DeleteMapNodes(req *apimodel.RouteNodesRequest
 	if node.ID <= 0 {
 		return fmt.Errorf("待删除节点不存在")
 	}
-	err = tx.Database.DeleteEntityByFilter(model.TableNameMapRouteNodes, selector, queryParams, &model.MapRouteNodes{})
+	_, err = tx.lockSliceForEdit(node.InfoID, req.EditContext)
+	if err != nil {
+		return err
+	}
+	before, err := tx.loadSnapshot(node.InfoID)
+	if err != nil {
+		return err
+	}
+	rois, err := tx.nodeRois(node.InfoID)
+	if err != nil {
+		return err
+	}
+	err = softDeleteEntity(tx.Database, model.TableNameMapRouteNodes, selector, queryParams, now)
 	if err != nil {
 		log.Error("删除地图关联节点失败,err:[%v]", err)
 		return err
@@ -454,9 +481,9 @@ func (operator *ResourceOperator) DeleteMapNodes(req *apimodel.RouteNodesRequest
 		return err
 	}
 	for i := range routes {
-		if routes[i].Start == node.NodeName || routes[i].End == node.NodeName {
+		if routes[i].StartNodeID == node.ID || routes[i].EndNodeID == node.ID {
 			selector[model.FieldID] = routes[i].ID
-			err = tx.Database.DeleteEntityByFilter(model.TableNameMapRoutes, selector, queryParams, &model.MapRoutes{})
+			err = softDeleteEntity(tx.Database, model.TableNameMapRoutes, selector, queryParams, now)
 			if err != nil {
 				return err
 			}
@@ -465,10 +492,13 @@ func (operator *ResourceOperator) DeleteMapNodes(req *apimodel.RouteNodesRequest
 
 	//生成新路径
 	for _, v := range routes {
-		if v.End == node.NodeName {
+		if v.EndNodeID == node.ID {
 			for _, k := range routes {
-				if k.Start == node.NodeName {
-					route := model.MapRoutes{RoutesName: v.Start + "-" + k.End, InfoID: v.InfoID, PathRole: "双向", Start: v.Start, End: k.End, StartToEnd: "正向行走", EndToStart: "正向行走"}
+				if k.StartNodeID == node.ID {
+					route, ok := mergeRoute(v, k, rois)
+					if !ok {
+						continue
+					}
 					routeCreate = append(routeCreate, route)
 				}
 			}
@@ -477,6 +507,8 @@ func (operator *ResourceOperator) DeleteMapNodes(req *apimodel.RouteNodesRequest
 	//生成新路径
 	if len(routeCreate) > 0 {
 		for _, v := range routeCreate {
+			// 合并生成的路径与删除共享时间，恢复删除时据此移除
+			v.CreatedAt = model.LocalTime(now)
 			err = tx.Database.CreateEntity(model.TableNameMapRoutes, &v)
 			if err != nil {
 				log.Error("DeleteMapRoute TransactionCommit Error.err[%v]", err)
@@ -484,6 +516,10 @@ func (operator *ResourceOperator) DeleteMapNodes(req *apimodel.RouteNodesRequest
 			}
 		}
 	}
+	err = tx.recordRevision(node.InfoID, before, req.EditContext, RevisionOpDeleteNode)
+	if err != nil {
+		return err
+	}
 	err = tx.TransactionCommit()
 	if err != nil {
 		log.Error("CreateOrUpdateTrainType TransactionCommit Error.err[%v]", err)
@@ -499,9 +535,9 @@ func (operator *ResourceOperator) CreateOrUpdateMapRoute(req *apimodel.MapRoutes
 	var createNodes []model.MapRouteNodes
 	var updateNodes []model.MapRouteNodes
 	var nodes []model.MapRouteNodes
+	var createIndex []int
 	var createRoutes []model.MapRoutes
 	var updateRoutes []model.MapRoutes
-	var nameStart model.MapRouteNodes
 
 	//对相邻且相同坐标节点过滤
 	for i := 0; i+1 < len(req.Nodes); i++ {
@@ -522,26 +558,22 @@ func (operator *ResourceOperator) CreateOrUpdateMapRoute(req *apimodel.MapRoutes
 	defer func() {
 		_ = tx.TransactionRollback()
 	}()
-
-	//自动生成name字段
-
-	params := model.OneQuery
-	order := model.Order{
-		Field:     model.FieldName,
-		Direction: apimodel.OrderDesc,
+	_, err = tx.lockSliceForEdit(req.InfoID, req.EditContext)
+	if err != nil {
+		return err
 	}
-	params.Orders = append(params.Orders, order)
-	err = tx.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, params, &nameStart)
+	before, err := tx.loadSnapshot(req.InfoID)
 	if err != nil {
 		return err
 	}
-	nameNumber := 0
-	if nameStart.ID > 0 {
-		nameNumber, _ = strconv.Atoi(strings.Split(nameStart.NodeName, "Site")[1])
+
+	//新增节点按地图命名规则自动生成name字段
+	namer, err := tx.newNodeNamer(req.InfoID)
+	if err != nil {
+		return err
 	}
 
 	if req.Nodes != nil {
-		index := 1
 		for _, v := range req.Nodes {
 			var node model.MapRouteNodes
 			//验证路径节点正确性
@@ -574,15 +606,9 @@ func (operator *ResourceOperator) CreateOrUpdateMapRoute(req *apimodel.MapRoutes
 				if err != nil {
 					return err
 				}
-				if nameNumber+index < 10 {
-					node.NodeName = "Site" + "000" + strconv.Itoa(nameNumber+index)
-				} else if nameNumber+index < 100 && nameNumber+index >= 10 {
-					node.NodeName = "Site" + "00" + strconv.Itoa(nameNumber+index)
-				} else if nameNumber+index < 1000 && nameNumber+index >= 100 {
-					node.NodeName = "Site" + "0" + strconv.Itoa(nameNumber+index)
-				}
+				node.NodeName = namer.next()
 				createNodes = append(createNodes, node)
-				index++
+				createIndex = append(createIndex, len(nodes))
 			}
 			nodes = append(nodes, node)
 		}
@@ -601,24 +627,24 @@ func (operator *ResourceOperator) CreateOrUpdateMapRoute(req *apimodel.MapRoutes
 			log.Error("地图路径节点创建失败. err:[%v]", err)
 			return err
 		}
+		//回填新建节点id，路径按id引用节点
+		for i, index := range createIndex {
+			nodes[index].ID = createNodes[i].ID
+		}
+		err = namer.save(tx)
+		if err != nil {
+			return err
+		}
 	}
 
 	//不传routes，自动生成对应路径
 	for i := 0; i < len(nodes)-1; i++ {
-		route := model.MapRoutes{
-			RoutesName: nodes[i].NodeName + "-" + nodes[i+1].NodeName,
-			InfoID:     req.Nodes[0].InfoID,
-			//Path:       utils.String(nodes[i].ID) + "-" + utils.String(nodes[i+1].ID),
-			Start:      nodes[i].NodeName,
-			End:        nodes[i+1].NodeName,
-			PathRole:   "双向",
-			StartToEnd: "正向行走",
-			EndToStart: "正向行走",
-		}
+		route := newRoute(req.Nodes[0].InfoID, nodes[i], nodes[i+1], defaultRouteRule)
 
 		var routeIndex model.MapRoutes
 		selector = make(map[string]interface{})
-		selector[model.FieldName] = route.RoutesName
+		selector[model.FieldStartNodeID] = route.StartNodeID
+		selector[model.FieldEndNodeID] = route.EndNodeID
 		selector[model.FieldInfoId] = route.InfoID
 		err = tx.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, model.OneQuery, &routeIndex)
 		if err != nil {
@@ -628,7 +654,7 @@ func (operator *ResourceOperator) CreateOrUpdateMapRoute(req *apimodel.MapRoutes
 			continue
 		}
 		//略过首位相同点
-		if route.Start == route.End {
+		if route.StartNodeID == route.EndNodeID {
 			continue
 		}
 		createRoutes = append(createRoutes, route)
@@ -659,18 +685,36 @@ func (operator *ResourceOperator) CreateOrUpdateMapRoute(req *apimodel.MapRoutes
 					}
 					return err
 				}
+				startNode, endNode := routeStart(route), routeEnd(route)
 				err = copier.Copy(&route, v)
 				if err != nil {
 					return err
 				}
+				//未指定起终点时沿用原节点
+				if route.StartNodeID == 0 && route.Start == "" {
+					route.StartNodeID, route.Start = startNode.ID, startNode.NodeName
+				}
+				if route.EndNodeID == 0 && route.End == "" {
+					route.EndNodeID, route.End = endNode.ID, endNode.NodeName
+				}
+				err = tx.resolveRouteNodes(&route)
+				if err != nil {
+					return err
+				}
+				fillRouteRule(&route)
 				updateRoutes = append(updateRoutes, route)
 			} else {
 				err = copier.Copy(&route, v)
 				if err != nil {
 					return err
 				}
+				err = tx.resolveRouteNodes(&route)
+				if err != nil {
+					return err
+				}
+				fillRouteRule(&route)
 				//略过首位相同点
-				if route.Start == route.End {
+				if route.StartNodeID == route.EndNodeID {
 					continue
 				}
 				createRoutes = append(createRoutes, route)
@@ -679,76 +723,54 @@ func (operator *ResourceOperator) CreateOrUpdateMapRoute(req *apimodel.MapRoutes
 	}
 
 	if req.Nodes != nil {
-		//校正是否有节点交叉左右联通
-		nameMap := make(map[string]struct{})
-		roiMap := make(map[string]model.MapRouteNodes)
+		//校正是否有节点交叉左右联通：节点落在路径上时吸附并拆分路径，待保存的路径以本次修改后的为准
+		var mapInfo model.MapInfo
 		var routes []model.MapRoutes
-		var nodeNames, nodeRois []model.MapRouteNodes
+		var sliceNodes []model.MapRouteNodes
 		selector = make(map[string]interface{})
-		selector[model.FieldInfoId] = req.Nodes[0].InfoID
+		selector[model.FieldInfoId] = req.InfoID
 		if err = tx.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, model.QueryParams{}, &routes); err != nil {
 			return err
 		}
-		err = tx.Database.GetEntityPluck(model.TableNameMapRouteNodes, selector, model.QueryParams{}, "name", &nodeNames)
-		if err != nil {
+		if err = tx.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.QueryParams{}, &sliceNodes); err != nil {
 			return err
 		}
-		err = tx.Database.GetEntityPluck(model.TableNameMapRouteNodes, selector, model.QueryParams{}, "roi", &nodeRois)
-		if err != nil {
+		if err = tx.Database.GetEntityByID(model.TableNameMapInfo, req.InfoID, &mapInfo); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+			}
 			return err
 		}
-		//
-		for i := range nodeNames {
-			nodeNames[i].Roi = nodeRois[i].Roi
-		}
-		routes = append(routes, createRoutes...)
-		routes = append(routes, updateRoutes...)
-		for _, route := range routes {
-			nameMap[route.RoutesName] = struct{}{}
+		updated := make(map[int]struct{}, len(updateRoutes))
+		for _, v := range updateRoutes {
+			updated[v.ID] = struct{}{}
 		}
-		for _, node := range nodeNames {
-			roiMap[node.NodeName] = node
+		candidates := make([]model.MapRoutes, 0, len(routes)+len(createRoutes)+len(updateRoutes))
+		for _, v := range routes {
+			if _, ok := updated[v.ID]; !ok {
+				candidates = append(candidates, v)
+			}
 		}
+		candidates = append(candidates, createRoutes...)
+		candidates = append(candidates, updateRoutes...)
+		splitter := newRouteSplitter(mapInfo, candidates, sliceNodes)
 		for i := range nodes {
-			for _, v := range routes {
-				//拿到路径的起始点、末尾点坐标
-				var roiH, roiE pq.Float64Array
-				nodeHeadName := v.Start
-				//strings.Split(v.RoutesName, "-")[0]
-				nodeEndName := v.End
-				//strings.Split(v.RoutesName, "-")[1]
-				roiH = roiMap[nodeHeadName].Roi
-				roiE = roiMap[nodeEndName].Roi
-				if roiH != nil && roiE != nil {
-					flag, point := apimodel.PointToLine(nodes[i].Roi, roiH, roiE)
-					if flag {
-						nodes[i].Roi = point
-						//同一线段上
-						routeA := model.MapRoutes{RoutesName: nodeHeadName + "-" + nodes[i].NodeName, InfoID: nodes[i].InfoID, PathRole: "双向", Start: nodeHeadName, End: nodes[i].NodeName, StartToEnd: "正向行走", EndToStart: "正向行走"}
-						routeB := model.MapRoutes{RoutesName: nodes[i].NodeName + "-" + nodeEndName, InfoID: nodes[i].InfoID, PathRole: "双向", Start: nodes[i].NodeName, End: nodeEndName, StartToEnd: "正向行走", EndToStart: "正向行走"}
-						if _, ok := nameMap[routeA.RoutesName]; ok {
-							continue
-						}
-						//略过首位相同点
-						if routeA.Start == routeA.End {
-							continue
-						}
-						nameMap[routeA.RoutesName] = struct{}{}
-						createRoutes = append(createRoutes, routeA)
-						if _, ok := nameMap[routeB.RoutesName]; ok {
-							continue
-						}
-						//略过首位相同点
-						if routeB.Start == routeB.End {
-							continue
-						}
-						nameMap[routeB.RoutesName] = struct{}{}
-						createRoutes = append(createRoutes, routeB)
-					}
-				}
-			}
+			nodes[i] = splitter.snap(nodes[i])
+		}
+		//被拆分的路径不再创建或更新，由拆分出的两段替代
+		createRoutes = removeSplitRoutes(createRoutes, splitter)
+		updateRoutes = removeSplitRoutes(updateRoutes, splitter)
+		createRoutes = append(createRoutes, splitter.created...)
+		err = splitter.save(tx, time.Now())
+		if err != nil {
+			return err
 		}
 	}
+	//路径几何不得穿越禁行区
+	err = tx.checkForbiddenZones(req.InfoID, append(createRoutes, updateRoutes...))
+	if err != nil {
+		return err
+	}
 	for _, v := range updateRoutes {
 		err = tx.Database.SaveEntity(model.TableNameMapRoutes, &v)
 		if err != nil {
@@ -764,6 +786,10 @@ func (operator *ResourceOperator) CreateOrUpdateMapRoute(req *apimodel.MapRoutes
 		return err
 	}
 
+	err = tx.recordRevision(req.InfoID, before, req.EditContext, RevisionOpSaveRoutes)
+	if err != nil {
+		return err
+	}
 	err = tx.TransactionCommit()
 	if err != nil {
 		log.Error("CreateOrUpdateTrainType TransactionCommit Error.err[%v]", err)
@@ -787,11 +813,17 @@ func (operator *ResourceOperator) ListMapRoutes(req *apimodel.MapRoutesRequest)
 	}
 	var count int64
 	var maps []model.MapRoutes
-	err := operator.Database.CountEntityByFilter(model.TableNameMapRoutes, selector, model.OneQuery, &count)
+	var err error
+	published := apimodel.ReadsPublished(req.Stage, req.InfoID)
+	if published {
+		maps, count, err = operator.listPublishedRoutes(req)
+	} else {
+		err = operator.Database.CountEntityByFilter(model.TableNameMapRoutes, selector, model.OneQuery, &count)
+	}
 	if err != nil {
 		return nil, err
 	}
-	if count > 0 {
+	if count > 0 && !published {
 		order := model.Order{
 			Field:     req.OrderBy,
 			Direction: apimodel.OrderAsc,
@@ -809,6 +841,14 @@ func (operator *ResourceOperator) ListMapRoutes(req *apimodel.MapRoutesRequest)
 		}
 	}
 	resp.Load(count, maps)
+	resp.Frame = apimodel.FramePixel
+	if !apimodel.IsPixelFrame(req.Frame) {
+		err = operator.newFrameConverter(req.Frame).routes(resp.List)
+		if err != nil {
+			return nil, err
+		}
+		resp.Frame = req.Frame
+	}
 	return &resp, nil
 }
 
@@ -817,7 +857,7 @@ func (operator *ResourceOperator) DeleteMapRoute(req *apimodel.MapRoutesRequest)
 	var routes []model.MapRoutes
 	var route model.MapRoutes
 	selector := make(map[string]interface{})
-	exitMap := make(map[string]struct{})
+	exitMap := make(map[int]struct{})
 	var routeCreate []model.MapRoutes
 	queryParams := model.QueryParams{}
 	// 开启事务
@@ -829,6 +869,7 @@ func (operator *ResourceOperator) DeleteMapRoute(req *apimodel.MapRoutesRequest)
 	defer func() {
 		_ = tx.TransactionRollback()
 	}()
+	now := time.Now()
 	selector[model.FieldID] = req.ID
 	err = tx.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, model.OneQuery, &route)
 	if err != nil {
@@ -838,7 +879,19 @@ func (operator *ResourceOperator) DeleteMapRoute(req *apimodel.MapRoutesRequest)
 	if route.ID <= 0 {
 		return fmt.Errorf("待删除路径不存在")
 	}
-	err = tx.Database.DeleteEntityByFilter(model.TableNameMapRoutes, selector, queryParams, &model.MapRoutes{})
+	_, err = tx.lockSliceForEdit(route.InfoID, req.EditContext)
+	if err != nil {
+		return err
+	}
+	before, err := tx.loadSnapshot(route.InfoID)
+	if err != nil {
+		return err
+	}
+	rois, err := tx.nodeRois(route.InfoID)
+	if err != nil {
+		return err
+	}
+	err = softDeleteEntity(tx.Database, model.TableNameMapRoutes, selector, queryParams, now)
 	if err != nil {
 		log.Error("地图数据删除失败. err:[%v]", err)
 		return err
@@ -851,38 +904,50 @@ func (operator *ResourceOperator) DeleteMapRoute(req *apimodel.MapRoutesRequest)
 		return err
 	}
 	for _, v := range routes {
-		exitMap[v.Start] = struct{}{}
-		exitMap[v.End] = struct{}{}
+		exitMap[v.StartNodeID] = struct{}{}
+		exitMap[v.EndNodeID] = struct{}{}
 	}
-	if _, ok := exitMap[route.Start]; !ok {
-		selector[model.FieldName] = route.Start
-		err = tx.Database.DeleteEntityByFilter(model.TableNameMapRouteNodes, selector, queryParams, &model.MapRouteNodes{})
+	//以route.end为起点的路径随后会被合并删除，不计入终点节点的引用
+	endRefMap := make(map[int]struct{})
+	for _, v := range routes {
+		if v.StartNodeID == route.EndNodeID {
+			continue
+		}
+		endRefMap[v.StartNodeID] = struct{}{}
+		endRefMap[v.EndNodeID] = struct{}{}
+	}
+	if _, ok := exitMap[route.StartNodeID]; !ok {
+		selector[model.FieldID] = route.StartNodeID
+		err = softDeleteEntity(tx.Database, model.TableNameMapRouteNodes, selector, queryParams, now)
 		if err != nil {
 			log.Error("删除路径数据失败,err:[%v]", err)
 			return err
 		}
 	}
-	//if _, ok := exitMap[route.End]; !ok {
-	selector[model.FieldName] = route.End
-	err = tx.Database.DeleteEntityByFilter(model.TableNameMapRouteNodes, selector, queryParams, &model.MapRouteNodes{})
-	if err != nil {
-		log.Error("删除路径节点数据失败,err:[%v]", err)
-		return err
+	//终点节点仍被其它路径引用时保留，避免产生悬空路径
+	if _, ok := endRefMap[route.EndNodeID]; !ok {
+		selector[model.FieldID] = route.EndNodeID
+		err = softDeleteEntity(tx.Database, model.TableNameMapRouteNodes, selector, queryParams, now)
+		if err != nil {
+			log.Error("删除路径节点数据失败,err:[%v]", err)
+			return err
+		}
 	}
-	//}
 	//删除以route.end为起点的route
 	for _, v := range routes {
-		if v.Start == route.End {
-			tempRoute := model.MapRoutes{RoutesName: route.Start + "-" + v.End, InfoID: route.InfoID, PathRole: "双向", Start: route.Start, End: v.End, StartToEnd: "正向行走", EndToStart: "正向行走"} //略过首位相同点
-			if tempRoute.Start == tempRoute.End {
+		if v.StartNodeID == route.EndNodeID {
+			tempRoute, ok := mergeRoute(route, v, rois)
+			//略过不可通行及首位相同点
+			if !ok || tempRoute.StartNodeID == tempRoute.EndNodeID {
 				continue
 			}
 			routeCreate = append(routeCreate, tempRoute)
 		}
 	}
 	selector = make(map[string]interface{})
-	selector["start"] = route.End
-	err = tx.Database.DeleteEntityByFilter(model.TableNameMapRoutes, selector, queryParams, &model.MapRouteNodes{})
+	selector[model.FieldInfoId] = route.InfoID
+	selector[model.FieldStartNodeID] = route.EndNodeID
+	err = softDeleteEntity(tx.Database, model.TableNameMapRoutes, selector, queryParams, now)
 	if err != nil {
 		log.Error("删除路径数据失败,err:[%v]", err)
 		return err
@@ -890,6 +955,8 @@ func (operator *ResourceOperator) DeleteMapRoute(req *apimodel.MapRoutesRequest)
 	//生成新路径
 	if len(routeCreate) > 0 {
 		for _, v := range routeCreate {
+			// 合并生成的路径与删除共享时间，恢复删除时据此移除
+			v.CreatedAt = model.LocalTime(now)
 			err = tx.Database.CreateEntity(model.TableNameMapRoutes, &v)
 			if err != nil {
 				log.Error("DeleteMapRoute TransactionCommit Error.err[%v]", err)
@@ -897,6 +964,10 @@ func (operator *ResourceOperator) DeleteMapRoute(req *apimodel.MapRoutesRequest)
 			}
 		}
 	}
+	err = tx.recordRevision(route.InfoID, before, req.EditContext, RevisionOpDeleteRoute)
+	if err != nil {
+		return err
+	}
 	err = tx.TransactionCommit()
 	if err != nil {
 		log.Error("DeleteMapRoute TransactionCommit Error.err[%v]", err)
@@ -905,117 +976,141 @@ func (operator *ResourceOperator) DeleteMapRoute(req *apimodel.MapRoutesRequest)
 	return nil
 }
 
-func (operator *ResourceOperator) CheckRoute(req *apimodel.MapRoutesArrRequest) error {
-	selector := make(map[string]interface{})
-	var nodeHead, nodeEnd model.MapRouteNodes
-	var mapInfo model.MapInfo
-	selector[model.FieldID] = req.Routes[0].InfoID
-	err := operator.Database.ListEntityByFilter(model.TableNameMapInfo, selector, model.OneQuery, &mapInfo)
-	if err != nil {
-		log.Error("查询地图信息失败,err:[%v]", err)
-		return err
-	}
-	mapInfo.MapURL = "/Users/dg2023/Desktop/test_photo.png"
-	file, err := os.Open(mapInfo.MapURL)
-	if err != nil {
-		log.Error("读取文件失败", err)
-		return err
-	}
-	defer file.Close()
-
+// CheckRoute 基于地图切片占用栅格逐条校验路径是否可通行(考虑机器人足迹)及是否穿越禁行区，同一切片的图片只解码一次
+func (operator *ResourceOperator) CheckRoute(req *apimodel.CheckRouteRequest) (*apimodel.CheckRouteResponse, error) {
+	resp := apimodel.CheckRouteResponse{
+		Pass:   true,
+		Routes: make([]apimodel.RouteCheckResult, 0, len(req.Routes)),
+	}
+	grids := make(map[int]*occupancyGrid)
+	infoNodes := make(map[int]map[int]model.MapRouteNodes)
+	infoZones := make(map[int][]model.MapZone)
 	for _, route := range req.Routes {
+		grid, ok := grids[route.InfoID]
+		if !ok {
+			var mapInfo model.MapInfo
+			var nodes []model.MapRouteNodes
+			err := operator.Database.GetEntityByID(model.TableNameMapInfo, route.InfoID, &mapInfo)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+				}
+				log.Error("查询地图信息失败,err:[%v]", err)
+				return nil, err
+			}
+			grid, err = loadOccupancyGrid(mapInfo)
+			if err != nil {
+				return nil, err
+			}
+			grids[route.InfoID] = grid
+
+			selector := make(map[string]interface{})
+			selector[model.FieldInfoId] = route.InfoID
+			err = operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.QueryParams{}, &nodes)
+			if err != nil {
+				log.Error("节点数据查询失败,err:[%v]", err)
+				return nil, err
+			}
+			nodeMap := make(map[int]model.MapRouteNodes)
+			for _, v := range nodes {
+				nodeMap[v.ID] = v
+			}
+			infoNodes[route.InfoID] = nodeMap
+			infoZones[route.InfoID], err = operator.listZones(route.InfoID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		var mapRoute model.MapRoutes
-		selector = make(map[string]interface{})
+		result := apimodel.RouteCheckResult{Name: route.RoutesName, Pass: true}
+		selector := make(map[string]interface{})
 		selector[model.FieldInfoId] = route.InfoID
-		selector[model.FieldName] = route.RoutesName
-		err = operator.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, model.OneQuery, &mapRoute)
+		if route.ID > 0 {
+			selector[model.FieldID] = route.ID
+		} else {
+			selector[model.FieldName] = route.RoutesName
+		}
+		err := operator.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, model.OneQuery, &mapRoute)
 		if err != nil {
 			log.Error("路径数据查找失败,err:[%v]", err)
-			return err
+			return nil, err
 		}
+		nodeHead, okHead := infoNodes[route.InfoID][mapRoute.StartNodeID]
+		nodeEnd, okEnd := infoNodes[route.InfoID][mapRoute.EndNodeID]
 		if mapRoute.ID <= 0 {
-			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "待校验路径")
-		}
-		nodeHeadName := strings.Split(route.RoutesName, "-")[0]
-		nodeEndName := strings.Split(route.RoutesName, "-")[1]
-		selector[model.FieldName] = nodeHeadName
-		err = operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.OneQuery, &nodeHead)
-		if err != nil {
-			log.Error("节点数据查询失败,err:[%v]", err)
-			return err
-		}
-		selector[model.FieldName] = nodeEndName
-		err = operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.OneQuery, &nodeEnd)
-		if err != nil {
-			log.Error("节点数据查询失败,err:[%v]", err)
-			return err
-		}
-		points := apimodel.PointsAbove(nodeHead.Roi, nodeEnd.Roi)
-
-		if strings.HasSuffix(path.Base(mapInfo.MapURL), ".png") {
-			img, err := png.Decode(file)
-			if err != nil {
-				log.Error("读取png文件失败,err:[%v]", err)
-				return err
-			}
-			for _, v := range points {
-				grayColor := color.GrayModel.Convert(img.At(int(v[0]), int(v[1]))).(color.Gray)
-				if grayColor.Y > 150 {
-					log.Error("路径：[%v] 校验未通过", route.RoutesName)
-					return fmt.Errorf("路径：[%v] 校验未通过", route.RoutesName)
-				}
-			}
-		} else if strings.HasSuffix(path.Base(mapInfo.MapURL), ".jpg") {
-			img, err := jpeg.Decode(file)
-			if err != nil {
-				log.Error("读取png文件失败,err:[%v]", err)
-				return fmt.Errorf("读取png文件失败")
+			result.Pass = false
+			result.Message = fmt.Sprintf(errcode.ErrorMsgSuffixParamNotExists, "待校验路径")
+		} else if !okHead || !okEnd || len(nodeHead.Roi) < 2 || len(nodeEnd.Roi) < 2 {
+			result.RouteID = mapRoute.ID
+			result.Pass = false
+			result.Message = fmt.Sprintf(errcode.ErrorMsgSuffixParamNotExists, "路径节点")
+		} else {
+			result.RouteID = mapRoute.ID
+			radius := grid.robotRadius
+			if req.RobotRadius > 0 {
+				radius = req.RobotRadius
 			}
-			for _, v := range points {
-				grayColor := color.GrayModel.Convert(img.At(int(v[0]), int(v[1]))).(color.Gray)
-				if grayColor.Y > 150 {
-					log.Error("路径：[%v] 校验未通过", route.RoutesName)
-					return fmt.Errorf("路径：[%v] 校验未通过", route.RoutesName)
+			points, _ := routeGeometry(mapRoute.Shape, mapRoute.ControlPoints, nodeHead.Roi, nodeEnd.Roi)
+			result.Pass, result.BlockedPoint, result.Message = grid.checkPath(points, radius)
+			if result.Pass {
+				if zone, entry, ok := forbiddenZone(points, infoZones[route.InfoID]); ok {
+					result.Pass = false
+					result.BlockedPoint = []int{int(math.Round(entry[0])), int(math.Round(entry[1]))}
+					result.Message = fmt.Sprintf("%s[%s]", errcode.ErrorMsgRouteForbidden, zone.Name)
 				}
 			}
 		}
+		if !result.Pass {
+			log.Warn("路径：[%v] 校验未通过,%s", route.RoutesName, result.Message)
+			resp.Pass = false
+		}
+		resp.Routes = append(resp.Routes, result)
 	}
-	return nil
+	return &resp, nil
 }
 
 func (operator *ResourceOperator) ListMapInfo(req *apimodel.RouteNodesRequest) (*apimodel.MapInfosResponse, error) {
+	resp, _, err := operator.listStageMapInfo(req)
+	return resp, err
+}
+
+// listStageMapInfo 同ListMapInfo，并返回同一阶段的区域供路径规划使用
+func (operator *ResourceOperator) listStageMapInfo(req *apimodel.RouteNodesRequest) (*apimodel.MapInfosResponse, []model.MapZone, error) {
 	var resp apimodel.MapInfosResponse
-	selector := make(map[string]interface{})
-	var routes []model.MapRoutes
-	var nodes []model.MapRouteNodes
-	queryParams := model.QueryParams{}
-	order := model.Order{
-		Field:     model.FieldID,
-		Direction: apimodel.OrderAsc,
-	}
-	queryParams.Orders = append(queryParams.Orders, order)
-	selector[model.FieldInfoId] = req.InfoID
-	err := operator.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, queryParams, &routes)
+	data, err := operator.loadStage(req.InfoID, req.Stage)
 	if err != nil {
-		log.Error("路径数据查询失败. err:[%v]", err)
-		return nil, err
+		return nil, nil, err
 	}
-
-	err = operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, queryParams, &nodes)
-	if err != nil {
-		log.Error("节点数据查询失败. err:[%v]", err)
-		return nil, err
-	}
-	roiMap := make(map[string]pq.Float64Array)
+	routes, nodes := data.routes, data.nodes
+	roiMap := make(map[int]pq.Float64Array)
 	for _, v := range nodes {
-		roiMap[v.NodeName] = v.Roi
+		roiMap[v.ID] = v.Roi
 	}
 	for i := range routes {
-		routes[i].StartRoi = roiMap[routes[i].Start]
-		routes[i].EndRoi = roiMap[routes[i].End]
+		routes[i].StartRoi = roiMap[routes[i].StartNodeID]
+		routes[i].EndRoi = roiMap[routes[i].EndNodeID]
 	}
 	resp.Load(routes, nodes)
-	return &resp, nil
+	for i := range resp.Routes {
+		fillRouteGeometry(&resp.Routes[i])
+	}
+	resp.Stage = data.stage
+	resp.Version = data.version
+	resp.Frame = apimodel.FramePixel
+	if !apimodel.IsPixelFrame(req.Frame) {
+		converter := operator.newFrameConverter(req.Frame)
+		err = converter.nodes(resp.Nodes)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = converter.routes(resp.Routes)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp.Frame = req.Frame
+	}
+	return &resp, data.zones, nil
 }
 
 func (operator *ResourceOperator) BatchDeleteMapNodes(req *apimodel.BatchDeleteNodes) error {
@@ -1028,10 +1123,11 @@ func (operator *ResourceOperator) BatchDeleteMapNodes(req *apimodel.BatchDeleteN
 	defer func() {
 		_ = tx.TransactionRollback()
 	}()
+	now := time.Now()
 	var nodes []model.MapRouteNodes
 	var routes []model.MapRoutes
 	var ids []int
-	nameMap := make(map[string]struct{})
+	idMap := make(map[int]struct{})
 	filter := make(map[string]interface{})
 	queryParams := model.QueryParams{}
 	inQuery := model.InQuery{
@@ -1046,8 +1142,20 @@ func (operator *ResourceOperator) BatchDeleteMapNodes(req *apimodel.BatchDeleteN
 	if len(nodes) <= 0 {
 		return fmt.Errorf("查找节点数据失败")
 	}
+	infoIDs := make([]int, 0, len(nodes))
+	for _, v := range nodes {
+		infoIDs = append(infoIDs, v.InfoID)
+	}
+	err = tx.lockSlicesForEdit(infoIDs, req.EditContext)
+	if err != nil {
+		return err
+	}
+	before, err := tx.loadSnapshot(nodes[0].InfoID)
+	if err != nil {
+		return err
+	}
 	//删除节点
-	err = tx.Database.DeleteEntityByFilter(model.TableNameMapRouteNodes, filter, queryParams, &model.MapRouteNodes{})
+	err = softDeleteEntity(tx.Database, model.TableNameMapRouteNodes, filter, queryParams, now)
 	if err != nil {
 		return err
 	}
@@ -1058,13 +1166,12 @@ func (operator *ResourceOperator) BatchDeleteMapNodes(req *apimodel.BatchDeleteN
 		return err
 	}
 	for _, v := range nodes {
-		nameMap[v.NodeName] = struct{}{}
+		idMap[v.ID] = struct{}{}
 	}
 	for _, v := range routes {
-		if _, ok := nameMap[v.Start]; ok {
-			ids = append(ids, v.ID)
-		}
-		if _, ok := nameMap[v.End]; ok {
+		_, okStart := idMap[v.StartNodeID]
+		_, okEnd := idMap[v.EndNodeID]
+		if okStart || okEnd {
 			ids = append(ids, v.ID)
 		}
 	}
@@ -1074,7 +1181,11 @@ func (operator *ResourceOperator) BatchDeleteMapNodes(req *apimodel.BatchDeleteN
 		Values: ids,
 	}
 	queryParams.InQueries = append(queryParams.InQueries, &inQuery)
-	err = tx.Database.DeleteEntityByFilter(model.TableNameMapRoutes, filter, queryParams, &model.MapRoutes{})
+	err = softDeleteEntity(tx.Database, model.TableNameMapRoutes, filter, queryParams, now)
+	if err != nil {
+		return err
+	}
+	err = tx.recordRevision(nodes[0].InfoID, before, req.EditContext, RevisionOpDeleteNodes)
 	if err != nil {
 		return err
 	}
@@ -1085,3 +1196,132 @@ func (operator *ResourceOperator) BatchDeleteMapNodes(req *apimodel.BatchDeleteN
 	}
 	return nil
 }
+
+// defaultRouteRule 自动生成路径的默认运行规则
+var defaultRouteRule = model.MapRoutes{
+	PathRole:   model.PathRoleBidirectional,
+	StartToEnd: model.DriveForward,
+	EndToStart: model.DriveForward,
+}
+
+// newRoute 生成start到end的路径，运行规则继承自rule
+func newRoute(infoID int, start, end model.MapRouteNodes, rule model.MapRoutes) model.MapRoutes {
+	return model.MapRoutes{
+		RoutesName:    start.NodeName + "-" + end.NodeName,
+		InfoID:        infoID,
+		PathRole:      rule.PathRole,
+		Start:         start.NodeName,
+		End:           end.NodeName,
+		StartNodeID:   start.ID,
+		EndNodeID:     end.ID,
+		StartToEnd:    rule.StartToEnd,
+		EndToStart:    rule.EndToStart,
+		MaxSpeed:      rule.MaxSpeed,
+		Width:         rule.Width,
+		CostFactor:    rule.CostFactor,
+		Priority:      rule.Priority,
+		ClosedWindows: rule.ClosedWindows,
+	}
+}
+
+// resolveRouteNodes 解析路径起终点节点，优先使用节点id，未指定时按切片内名称查找，名称以节点为准
+func (operator *ResourceOperator) resolveRouteNodes(route *model.MapRoutes) error {
+	start, err := operator.resolveRouteNode(route.InfoID, route.StartNodeID, route.Start)
+	if err != nil {
+		return err
+	}
+	end, err := operator.resolveRouteNode(route.InfoID, route.EndNodeID, route.End)
+	if err != nil {
+		return err
+	}
+	route.StartNodeID, route.Start = start.ID, start.NodeName
+	route.EndNodeID, route.End = end.ID, end.NodeName
+	return nil
+}
+
+func (operator *ResourceOperator) resolveRouteNode(infoID, id int, name string) (model.MapRouteNodes, error) {
+	var node model.MapRouteNodes
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	if id > 0 {
+		selector[model.FieldID] = id
+	} else if name != "" {
+		selector[model.FieldName] = name
+	} else {
+		return node, fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "start_node_id/end_node_id")
+	}
+	err := operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.OneQuery, &node)
+	if err != nil {
+		return node, err
+	}
+	if node.ID == 0 {
+		return node, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "路径节点")
+	}
+	return node, nil
+}
+
+// routeStart 路径起点的节点引用
+func routeStart(route model.MapRoutes) model.MapRouteNodes {
+	node := model.MapRouteNodes{NodeName: route.Start}
+	node.ID = route.StartNodeID
+	return node
+}
+
+// routeEnd 路径终点的节点引用
+func routeEnd(route model.MapRoutes) model.MapRouteNodes {
+	node := model.MapRouteNodes{NodeName: route.End}
+	node.ID = route.EndNodeID
+	return node
+}
+
+// mergeRoute 合并首尾相接的两段路径a(start->mid)、b(mid->end)，几何经过mid节点，合并后不可通行时返回false
+func mergeRoute(a, b model.MapRoutes, rois map[int]pq.Float64Array) (model.MapRoutes, bool) {
+	role, ok := model.MergePathRole(a.PathRole, b.PathRole)
+	if !ok {
+		return model.MapRoutes{}, false
+	}
+	//合并后取两段中更严格的通行限制；合并后允许的方向两段均允许，行驶方式同unionRoute取先出现的a
+	rule := model.MapRoutes{
+		PathRole:      role,
+		StartToEnd:    a.StartToEnd,
+		EndToStart:    a.EndToStart,
+		MaxSpeed:      minPositive(a.MaxSpeed, b.MaxSpeed),
+		Width:         minPositive(a.Width, b.Width),
+		CostFactor:    math.Max(a.CostFactor, b.CostFactor),
+		ClosedWindows: append(append(model.RouteTimeWindows{}, a.ClosedWindows...), b.ClosedWindows...),
+	}
+	if a.Priority == b.Priority {
+		rule.Priority = a.Priority
+	}
+	if len(rule.ClosedWindows) == 0 {
+		rule.ClosedWindows = nil
+	}
+	route := newRoute(a.InfoID, routeStart(a), routeEnd(b), rule)
+	shape := mergeShape(a, b, rois)
+	route.Shape, route.ControlPoints = shape.shape, shape.control
+	return route, true
+}
+
+// minPositive 两个限制值中较小的一个，0表示不限制
+func minPositive(a, b float64) float64 {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 {
+		return a
+	}
+	return math.Min(a, b)
+}
+
+// fillRouteRule 未指定的运行规则使用默认值
+func fillRouteRule(route *model.MapRoutes) {
+	if route.PathRole == "" {
+		route.PathRole = defaultRouteRule.PathRole
+	}
+	if route.StartToEnd == "" {
+		route.StartToEnd = defaultRouteRule.StartToEnd
+	}
+	if route.EndToStart == "" {
+		route.EndToStart = defaultRouteRule.EndToStart
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils/storage"
+	"errors"
+	"fmt"
+	log "github.com/wonderivan/logger"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+// openMapImage 按地图地址打开地图图片
+func openMapImage(mapURL string) (io.ReadCloser, error) {
+	if mapURL == "" {
+		return nil, errors.New(errcode.ErrorMsgMapImageEmpty)
+	}
+	return openStoredFile(mapURL)
+}
+
+// openStoredFile 按地址打开地图图片、点云等文件。
+// 只读取存储key及文件存储生成的地址，外部地址与本地路径一律拒绝
+func openStoredFile(fileURL string) (io.ReadCloser, error) {
+	if storage.Default == nil {
+		return nil, errors.New("文件存储未初始化")
+	}
+	key, ok := storage.Resolve(fileURL)
+	if !ok {
+		return nil, fmt.Errorf("文件地址[%s]不属于文件存储", fileURL)
+	}
+	return storage.Default.Open(key)
+}
+
+// storedKey 将客户端回传的文件地址还原为存储key，为空表示不设置；
+// 与已保存取值相同的历史地址原样保留，其余无法还原为key的地址按参数错误拒绝
+func storedKey(fileURL, current, param string) (string, error) {
+	if fileURL == "" || fileURL == current {
+		return fileURL, nil
+	}
+	key, ok := storage.Resolve(fileURL)
+	if !ok {
+		return "", fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, param)
+	}
+	return key, nil
+}
+
+// decodeMapImage 读取并解码地图图片，支持png/jpeg/pgm(pgm解码器在utils中注册)
+func decodeMapImage(mapURL string) (image.Image, error) {
+	reader, err := openMapImage(mapURL)
+	if err != nil {
+		log.Error("读取地图图片失败,url:[%s] err:[%v]", mapURL, err)
+		return nil, err
+	}
+	defer reader.Close()
+	img, format, err := image.Decode(reader)
+	if err != nil {
+		log.Error("解码地图图片失败,url:[%s] err:[%v]", mapURL, err)
+		return nil, errors.New(errcode.ErrorMsgMapImageDecode)
+	}
+	log.Debug("地图图片解码完成,url:[%s] format:[%s] size:[%v]", mapURL, format, img.Bounds().Size())
+	return img, nil
+}
+
+// mapImageSize 仅读取图片头获取地图图片宽高
+func mapImageSize(mapURL string) (int, int, error) {
+	reader, err := openMapImage(mapURL)
+	if err != nil {
+		log.Error("读取地图图片失败,url:[%s] err:[%v]", mapURL, err)
+		return 0, 0, err
+	}
+	defer reader.Close()
+	cfg, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		log.Error("解析地图图片尺寸失败,url:[%s] err:[%v]", mapURL, err)
+		return 0, 0, errors.New(errcode.ErrorMsgMapImageDecode)
+	}
+	return cfg.Width, cfg.Height, nil
+}
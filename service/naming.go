@@ -0,0 +1,214 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"errors"
+	"fmt"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+)
+
+// nodeNamer 按所属地图的命名规则为切片分配节点名称，序号只增不减
+type nodeNamer struct {
+	infoID int
+	prefix string
+	width  int
+	seq    int                 //最近一次分配的序号
+	used   map[string]struct{} //切片内已占用的名称(含回收站中的节点)
+}
+
+// newNodeNamer 锁定切片行并读取命名规则，须在事务内调用，保证并发分配不重号
+func (operator *ResourceOperator) newNodeNamer(infoID int) (*nodeNamer, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityForUpdate(model.TableNameMapInfo, infoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	var mapData model.Map
+	err = operator.Database.GetEntityByID(model.TableNameMap, mapInfo.MapID, &mapData)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	namer := nodeNamer{
+		infoID: infoID,
+		prefix: mapInfo.NodePrefix + mapData.NodePrefix,
+		width:  mapData.NodeWidth,
+		seq:    mapInfo.NodeSeq,
+		used:   make(map[string]struct{}),
+	}
+	if mapData.NodePrefix == "" {
+		namer.prefix = mapInfo.NodePrefix + model.DefaultNodePrefix
+	}
+	if namer.width <= 0 {
+		namer.width = model.DefaultNodeWidth
+	}
+	start := mapData.NodeStart
+	if start <= 0 {
+		start = model.DefaultNodeStart
+	}
+
+	var nodes []model.MapRouteNodes
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	err = operator.Database.ListUnscopedEntityByFilter(model.TableNameMapRouteNodes, selector, model.QueryParams{}, &nodes)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range nodes {
+		namer.used[v.NodeName] = struct{}{}
+		// 尚未记录序号的历史切片，以已有名称中的最大序号为起点
+		if mapInfo.NodeSeq == 0 && strings.HasPrefix(v.NodeName, namer.prefix) {
+			number, err := strconv.Atoi(strings.TrimPrefix(v.NodeName, namer.prefix))
+			if err == nil && number > namer.seq {
+				namer.seq = number
+			}
+		}
+	}
+	if namer.seq < start-1 {
+		namer.seq = start - 1
+	}
+	return &namer, nil
+}
+
+// next 分配下一个未被占用的节点名称
+func (n *nodeNamer) next() string {
+	for {
+		n.seq++
+		name := fmt.Sprintf("%s%0*d", n.prefix, n.width, n.seq)
+		if _, ok := n.used[name]; !ok {
+			n.used[name] = struct{}{}
+			return name
+		}
+	}
+}
+
+// save 持久化已分配的最大序号
+func (n *nodeNamer) save(operator *ResourceOperator) error {
+	selector := make(map[string]interface{})
+	selector[model.FieldID] = n.infoID
+	updater := map[string]interface{}{model.FieldNodeSeq: n.seq}
+	err := operator.Database.UpdateEntityByFilter(model.TableNameMapInfo, selector, model.QueryParams{}, &updater)
+	if err != nil {
+		log.Error("地图切片节点序号更新失败. err:[%v]", err)
+		return err
+	}
+	return nil
+}
+
+// RenameMapNode 重命名节点，并同步修改切片内引用该节点的路径
+func (operator *ResourceOperator) RenameMapNode(req *apimodel.RenameNodeRequest) error {
+	var node model.MapRouteNodes
+	err := operator.Database.GetEntityByID(model.TableNameMapRouteNodes, req.ID, &node)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "待重命名节点")
+		}
+		return err
+	}
+	if node.NodeName == req.NodeName {
+		return nil
+	}
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("RenameMapNode TransactionBegin Error.err[%v]", err)
+		return err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	_, err = tx.lockSliceForEdit(node.InfoID, req.EditContext)
+	if err != nil {
+		return err
+	}
+	// 加锁后重新读取节点，避免覆盖加锁前其他请求的修改
+	node = model.MapRouteNodes{}
+	err = tx.Database.GetEntityByID(model.TableNameMapRouteNodes, req.ID, &node)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "待重命名节点")
+		}
+		return err
+	}
+	if node.NodeName == req.NodeName {
+		return nil
+	}
+	var exist model.MapRouteNodes
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = node.InfoID
+	selector[model.FieldName] = req.NodeName
+	err = tx.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, model.OneQuery, &exist)
+	if err != nil {
+		return err
+	}
+	if exist.ID != 0 {
+		return fmt.Errorf(errcode.ErrorMsgSuffixParamExists, "地图路径节点")
+	}
+	before, err := tx.loadSnapshot(node.InfoID)
+	if err != nil {
+		return err
+	}
+	oldName := node.NodeName
+	node.NodeName = req.NodeName
+	err = tx.Database.SaveEntity(model.TableNameMapRouteNodes, &node)
+	if err != nil {
+		log.Error("地图路径节点重命名失败. err:[%v]", err)
+		return err
+	}
+
+	var routes []model.MapRoutes
+	selector = make(map[string]interface{})
+	selector[model.FieldInfoId] = node.InfoID
+	err = tx.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, model.QueryParams{}, &routes)
+	if err != nil {
+		return err
+	}
+	routeNames := make(map[string]struct{}, len(routes))
+	for _, v := range routes {
+		routeNames[v.RoutesName] = struct{}{}
+	}
+	for _, v := range routes {
+		if v.StartNodeID != node.ID && v.EndNodeID != node.ID {
+			continue
+		}
+		// 自动生成的路径名称(起点-终点)随节点名称一起更新
+		autoName := v.RoutesName == v.Start+"-"+v.End
+		if v.StartNodeID == node.ID {
+			v.Start = req.NodeName
+		}
+		if v.EndNodeID == node.ID {
+			v.End = req.NodeName
+		}
+		if autoName {
+			name := v.Start + "-" + v.End
+			if _, ok := routeNames[name]; !ok {
+				delete(routeNames, v.RoutesName)
+				routeNames[name] = struct{}{}
+				v.RoutesName = name
+			}
+		}
+		err = tx.Database.SaveEntity(model.TableNameMapRoutes, &v)
+		if err != nil {
+			log.Error("路径[%d]引用节点更新失败. err:[%v]", v.ID, err)
+			return err
+		}
+	}
+	err = tx.recordRevision(node.InfoID, before, req.EditContext, RevisionOpRenameNode)
+	if err != nil {
+		return err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("RenameMapNode TransactionCommit Error.err[%v]", err)
+		return err
+	}
+	log.Info("地图切片[%d]节点[%s]重命名为[%s]", node.InfoID, oldName, req.NodeName)
+	return nil
+}
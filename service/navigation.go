@@ -0,0 +1,250 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"errors"
+	"fmt"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+)
+
+// PlanRoute 在地图切片的路径网络上规划起点到终点的最短路径
+func (operator *ResourceOperator) PlanRoute(req *apimodel.PlanRouteRequest) (*apimodel.PlanRouteResponse, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, req.InfoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	data, zones, err := operator.listStageMapInfo(&apimodel.RouteNodesRequest{InfoID: req.InfoID, Stage: req.Stage})
+	if err != nil {
+		return nil, err
+	}
+	graph := newNavGraph(data.Nodes, data.Routes, zones, newNavOptions(req.PlanOptions))
+	startID, ok := graph.names[req.Start]
+	if !ok {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "起点节点")
+	}
+	endID, ok := graph.names[req.End]
+	if !ok {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "终点节点")
+	}
+	nodeIDs, edges, ok := graph.shortestPath(startID, endID)
+	if !ok {
+		log.Warn("路径规划失败,起点[%v]终点[%v]不连通", req.Start, req.End)
+		return nil, errors.New(errcode.ErrorMsgRouteUnreachable)
+	}
+	return newPlanRouteResponse(graph, nodeIDs, edges, mapInfo, req.Frame)
+}
+
+// PlanNearestNode 从起点出发按通行代价查找最近的指定类型节点并规划路径，free为true时跳过按occupied_ids已占满的节点
+func (operator *ResourceOperator) PlanNearestNode(req *apimodel.PlanNearestRequest) (*apimodel.PlanNearestResponse, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, req.InfoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	data, zones, err := operator.listStageMapInfo(&apimodel.RouteNodesRequest{InfoID: req.InfoID, Stage: req.Stage})
+	if err != nil {
+		return nil, err
+	}
+	graph := newNavGraph(data.Nodes, data.Routes, zones, newNavOptions(req.PlanOptions))
+	if _, ok := graph.nodes[req.StartNodeID]; !ok {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "起点节点")
+	}
+	occupied := make(map[int]int, len(req.OccupiedIDs))
+	for _, id := range req.OccupiedIDs {
+		occupied[id]++
+	}
+	targetID, nodeIDs, edges, ok := graph.nearest(req.StartNodeID, func(id int) bool {
+		node := graph.nodes[id]
+		if node.Type != req.Type {
+			return false
+		}
+		return !req.Free || occupied[id] < model.NodeCapacity(node.Type, node.Capacity)
+	})
+	if !ok {
+		log.Warn("最近节点查找失败,起点[%v]可达范围内不存在类型为[%v]的节点", req.StartNodeID, req.Type)
+		return nil, errors.New(errcode.ErrorMsgNearestNode)
+	}
+	route, err := newPlanRouteResponse(graph, nodeIDs, edges, mapInfo, req.Frame)
+	if err != nil {
+		return nil, err
+	}
+	resp := apimodel.PlanNearestResponse{
+		Target:            route.Nodes[len(route.Nodes)-1],
+		Occupied:          occupied[targetID],
+		PlanRouteResponse: *route,
+	}
+	return &resp, nil
+}
+
+// newPlanRouteResponse 由切片内的搜索结果生成规划响应，按需转换为世界坐标
+func newPlanRouteResponse(graph *navGraph, nodeIDs []int, edges []navEdge, mapInfo model.MapInfo, frameType string) (*apimodel.PlanRouteResponse, error) {
+	resp := apimodel.PlanRouteResponse{
+		Frame:    apimodel.FramePixel,
+		Nodes:    make([]apimodel.RouteNodesInfo, 0, len(nodeIDs)),
+		RouteIDs: make([]int, 0, len(edges)),
+		Steps:    make([]apimodel.PlanStep, 0, len(edges)),
+	}
+	for _, id := range nodeIDs {
+		resp.Nodes = append(resp.Nodes, graph.nodes[id])
+	}
+	for _, edge := range edges {
+		resp.RouteIDs = append(resp.RouteIDs, edge.RouteID)
+		resp.Steps = append(resp.Steps, apimodel.PlanStep{
+			RouteID:  edge.RouteID,
+			From:     graph.nodes[edge.From].NodeName,
+			To:       graph.nodes[edge.To].NodeName,
+			Drive:    edge.Drive,
+			Length:   edge.Length,
+			MaxSpeed: edge.MaxSpeed,
+			SoundOff: edge.SoundOff,
+		})
+		resp.Length += edge.Length
+		resp.Cost += edge.Cost
+	}
+	if !apimodel.IsPixelFrame(frameType) {
+		frame, err := newMapFrame(mapInfo)
+		if err != nil {
+			return nil, err
+		}
+		for i := range resp.Nodes {
+			resp.Nodes[i].Roi = frame.convert(resp.Nodes[i].Roi, frameType)
+		}
+		// 各坐标系间为刚体变换加等比缩放，长度直接按比例换算
+		scale := frame.lengthScale(frameType)
+		for i := range resp.Steps {
+			resp.Steps[i].Length *= scale
+		}
+		resp.Length *= scale
+		resp.Frame = frameType
+	}
+	return &resp, nil
+}
+
+// PlanMapRoute 在整张地图范围内规划路径，各切片路径网络经可用的楼层连接器合并为一张图，结果按楼层拆分
+func (operator *ResourceOperator) PlanMapRoute(req *apimodel.PlanMapRouteRequest) (*apimodel.PlanMapRouteResponse, error) {
+	var mapDB model.Map
+	err := operator.Database.GetEntityByID(model.TableNameMap, req.MapID, &mapDB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图")
+		}
+		return nil, err
+	}
+	var mapInfos []model.MapInfo
+	selector := make(map[string]interface{})
+	selector[model.FieldMapId] = req.MapID
+	err = operator.Database.ListEntityByFilter(model.TableNameMapInfo, selector, model.QueryParams{}, &mapInfos)
+	if err != nil {
+		log.Error("地图切片数据查询失败. err:[%v]", err)
+		return nil, err
+	}
+	var connectors []model.MapConnector
+	selector[model.FieldAvailable] = true
+	err = operator.Database.ListEntityByFilter(model.TableNameMapConnector, selector, model.QueryParams{}, &connectors)
+	if err != nil {
+		log.Error("连接器数据查询失败. err:[%v]", err)
+		return nil, err
+	}
+
+	infos := make(map[int]model.MapInfo, len(mapInfos))
+	graph := newNavGraph(nil, nil, nil, newNavOptions(req.PlanOptions))
+	for _, mapInfo := range mapInfos {
+		infos[mapInfo.ID] = mapInfo
+		data, zones, err := operator.listStageMapInfo(&apimodel.RouteNodesRequest{InfoID: mapInfo.ID, Stage: req.Stage})
+		if err != nil {
+			return nil, err
+		}
+		graph.addSlice(data.Nodes, data.Routes, zones)
+	}
+	connectorMap := make(map[int]model.MapConnector, len(connectors))
+	for _, connector := range connectors {
+		connectorMap[connector.ID] = connector
+		graph.addConnector(connector)
+	}
+	if _, ok := graph.nodes[req.StartNodeID]; !ok {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "起点节点")
+	}
+	if _, ok := graph.nodes[req.EndNodeID]; !ok {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "终点节点")
+	}
+	_, edges, ok := graph.shortestPath(req.StartNodeID, req.EndNodeID)
+	if !ok {
+		log.Warn("跨楼层路径规划失败,起点[%v]终点[%v]不连通", req.StartNodeID, req.EndNodeID)
+		return nil, errors.New(errcode.ErrorMsgRouteUnreachable)
+	}
+
+	resp := apimodel.PlanMapRouteResponse{Frame: apimodel.FramePixel}
+	newLeg := func(nodeID int) apimodel.PlanLeg {
+		node := graph.nodes[nodeID]
+		return apimodel.PlanLeg{
+			InfoID:   node.InfoID,
+			InfoName: infos[node.InfoID].Name,
+			Nodes:    []apimodel.RouteNodesInfo{node},
+			RouteIDs: make([]int, 0),
+			Steps:    make([]apimodel.PlanStep, 0),
+		}
+	}
+	leg := newLeg(req.StartNodeID)
+	for _, edge := range edges {
+		resp.Cost += edge.Cost
+		if edge.ConnectorID > 0 {
+			connector := connectorMap[edge.ConnectorID]
+			leg.Connector = &apimodel.PlanTransfer{
+				ConnectorID: connector.ID,
+				Name:        connector.Name,
+				Type:        connector.Type,
+				ToInfoID:    graph.nodes[edge.To].InfoID,
+				ToNode:      graph.nodes[edge.To].NodeName,
+				Cost:        edge.Cost,
+			}
+			resp.Legs = append(resp.Legs, leg)
+			leg = newLeg(edge.To)
+			continue
+		}
+		leg.Nodes = append(leg.Nodes, graph.nodes[edge.To])
+		leg.RouteIDs = append(leg.RouteIDs, edge.RouteID)
+		leg.Steps = append(leg.Steps, apimodel.PlanStep{
+			RouteID:  edge.RouteID,
+			From:     graph.nodes[edge.From].NodeName,
+			To:       graph.nodes[edge.To].NodeName,
+			Drive:    edge.Drive,
+			Length:   edge.Length,
+			MaxSpeed: edge.MaxSpeed,
+			SoundOff: edge.SoundOff,
+		})
+		leg.Length += edge.Length
+	}
+	resp.Legs = append(resp.Legs, leg)
+
+	if !apimodel.IsPixelFrame(req.Frame) {
+		resp.Frame = req.Frame
+	}
+	for i := range resp.Legs {
+		if !apimodel.IsPixelFrame(req.Frame) {
+			frame, err := newMapFrame(infos[resp.Legs[i].InfoID])
+			if err != nil {
+				return nil, err
+			}
+			for j := range resp.Legs[i].Nodes {
+				resp.Legs[i].Nodes[j].Roi = frame.convert(resp.Legs[i].Nodes[j].Roi, req.Frame)
+			}
+			scale := frame.lengthScale(req.Frame)
+			for j := range resp.Legs[i].Steps {
+				resp.Legs[i].Steps[j].Length *= scale
+			}
+			resp.Legs[i].Length *= scale
+		}
+		resp.Length += resp.Legs[i].Length
+	}
+	return &resp, nil
+}
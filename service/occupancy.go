@@ -0,0 +1,272 @@
+package service
+
+import (
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils"
+	"image"
+	"image/draw"
+	"math"
+	"sync"
+)
+
+// 栅格状态
+const (
+	cellFree = iota
+	cellUnknown
+	cellOccupied
+)
+
+// occupancyGrid 占用栅格地图，灰度到占用概率的换算同ROS map_server:
+// negate为false时 p=(255-灰度)/255，p>occupiedThresh为占用，p<freeThresh为空闲，其余为未知
+type occupancyGrid struct {
+	gray           *image.Gray
+	negate         bool
+	occupiedThresh float64
+	freeThresh     float64
+	allowUnknown   bool
+	robotRadius    float64
+
+	mu       sync.Mutex
+	radii    []float64
+	inflated map[float64][]uint8 //按机器人半径膨胀后的栅格状态，最多保留maxInflatedRadii个半径
+}
+
+// maxInflatedRadii 单个栅格缓存的膨胀结果数量，校验请求可指定任意机器人半径
+const maxInflatedRadii = 4
+
+// gridKey 占用栅格的缓存键，图片地址与换算参数均相同的切片共用同一栅格
+type gridKey struct {
+	mapURL         string
+	negate         bool
+	occupiedThresh float64
+	freeThresh     float64
+	allowUnknown   bool
+	robotRadius    float64
+}
+
+// gridCache 已解码的占用栅格缓存，避免路径校验每次请求都解码整张地图图片。
+// 地图图片更换时存储地址随之变化，旧地址的栅格不再命中，按缓存顺序淘汰
+type gridCache struct {
+	mu    sync.Mutex
+	keys  []gridKey
+	grids map[gridKey]*occupancyGrid
+}
+
+var occupancyGrids = gridCache{grids: make(map[gridKey]*occupancyGrid)}
+
+// loadOccupancyGrid 获取切片的占用栅格，缓存未命中时解码地图图片
+func loadOccupancyGrid(mapInfo model.MapInfo) (*occupancyGrid, error) {
+	grid := newOccupancyGrid(nil, mapInfo)
+	key := grid.key(mapInfo.MapURL)
+	if cached := occupancyGrids.get(key); cached != nil {
+		return cached, nil
+	}
+	img, err := decodeMapImage(mapInfo.MapURL)
+	if err != nil {
+		return nil, err
+	}
+	grid.gray = toGray(img)
+	occupancyGrids.put(key, grid)
+	return grid, nil
+}
+
+func (c *gridCache) get(key gridKey) *occupancyGrid {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.grids[key]
+}
+
+func (c *gridCache) put(key gridKey, grid *occupancyGrid) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.grids[key]; ok {
+		return
+	}
+	for len(c.keys) > 0 && len(c.keys) >= config.Conf.Map.GridCacheSize {
+		delete(c.grids, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	if config.Conf.Map.GridCacheSize <= 0 {
+		return
+	}
+	c.keys = append(c.keys, key)
+	c.grids[key] = grid
+}
+
+// remove 删除地图图片地址对应的全部栅格
+func (c *gridCache) remove(mapURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.keys[:0]
+	for _, key := range c.keys {
+		if key.mapURL == mapURL {
+			delete(c.grids, key)
+			continue
+		}
+		kept = append(kept, key)
+	}
+	c.keys = kept
+}
+
+func (g *occupancyGrid) key(mapURL string) gridKey {
+	return gridKey{
+		mapURL:         mapURL,
+		negate:         g.negate,
+		occupiedThresh: g.occupiedThresh,
+		freeThresh:     g.freeThresh,
+		allowUnknown:   g.allowUnknown,
+		robotRadius:    g.robotRadius,
+	}
+}
+
+// newOccupancyGrid 按切片参数建立占用栅格，img为空时仅填充换算参数
+func newOccupancyGrid(img image.Image, mapInfo model.MapInfo) *occupancyGrid {
+	grid := occupancyGrid{
+		negate:         mapInfo.Negate,
+		occupiedThresh: config.Conf.Map.OccupiedThresh,
+		freeThresh:     config.Conf.Map.FreeThresh,
+		allowUnknown:   mapInfo.AllowUnknown,
+		robotRadius:    mapInfo.RobotRadius,
+	}
+	if mapInfo.OccupiedThresh != nil {
+		grid.occupiedThresh = *mapInfo.OccupiedThresh
+	}
+	if mapInfo.FreeThresh != nil {
+		grid.freeThresh = *mapInfo.FreeThresh
+	}
+	if grid.robotRadius <= 0 {
+		grid.robotRadius = config.Conf.Map.RobotRadius
+	}
+	if img != nil {
+		grid.gray = toGray(img)
+	}
+	return &grid
+}
+
+// toGray 统一转为灰度图，便于按像素直接读取
+func toGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray
+}
+
+// cellState 像素对应的栅格状态，超出地图范围视为占用
+func (g *occupancyGrid) cellState(x, y int) int {
+	if !image.Pt(x, y).In(g.gray.Rect) {
+		return cellOccupied
+	}
+	value := float64(g.gray.GrayAt(x, y).Y)
+	p := (255 - value) / 255
+	if g.negate {
+		p = value / 255
+	}
+	if p > g.occupiedThresh {
+		return cellOccupied
+	}
+	if p < g.freeThresh {
+		return cellFree
+	}
+	return cellUnknown
+}
+
+// checkPath 依次校验折线的每一段，返回首个未通过线段的结果
+func (g *occupancyGrid) checkPath(points [][2]float64, radius float64) (bool, []int, string) {
+	for i := 0; i+1 < len(points); i++ {
+		pass, blocked, message := g.checkSegment(points[i][:], points[i+1][:], radius)
+		if !pass {
+			return pass, blocked, message
+		}
+	}
+	return true, nil, ""
+}
+
+// inflate 返回按半径radius膨胀后的栅格状态：圆形机器人足迹内有占用像素(含地图外)为占用，
+// 否则有未知像素为未知。每个半径只计算一次，此后路径上每个像素只需查询一次
+func (g *occupancyGrid) inflate(radius float64) []uint8 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cells, ok := g.inflated[radius]; ok {
+		return cells
+	}
+	rect := g.gray.Rect
+	width, height := rect.Dx(), rect.Dy()
+	occupied := make([]bool, width*height)
+	unknown := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			switch g.cellState(rect.Min.X+x, rect.Min.Y+y) {
+			case cellOccupied:
+				occupied[y*width+x] = true
+			case cellUnknown:
+				unknown[y*width+x] = true
+			}
+		}
+	}
+	utils.DilateDisc(occupied, width, height, radius)
+	utils.DilateDisc(unknown, width, height, radius)
+	cells := make([]uint8, width*height)
+	for y := 0; y < height; y++ {
+		//到地图外最近像素的距离
+		edgeY := y + 1
+		if height-y < edgeY {
+			edgeY = height - y
+		}
+		for x := 0; x < width; x++ {
+			edge := x + 1
+			if width-x < edge {
+				edge = width - x
+			}
+			if edgeY < edge {
+				edge = edgeY
+			}
+			i := y*width + x
+			switch {
+			case occupied[i] || float64(edge*edge) <= radius*radius:
+				cells[i] = cellOccupied
+			case unknown[i]:
+				cells[i] = cellUnknown
+			}
+		}
+	}
+	if g.inflated == nil {
+		g.inflated = make(map[float64][]uint8)
+	}
+	if len(g.radii) >= maxInflatedRadii {
+		delete(g.inflated, g.radii[0])
+		g.radii = g.radii[1:]
+	}
+	g.radii = append(g.radii, radius)
+	g.inflated[radius] = cells
+	return cells
+}
+
+// checkSegment 将线段完整光栅化，并沿线段扫掠半径为radius的圆形机器人足迹。
+// 返回是否通过、首个足迹受阻的路径像素坐标及原因
+func (g *occupancyGrid) checkSegment(p1, p2 []float64, radius float64) (bool, []int, string) {
+	x0, y0 := int(math.Round(p1[0])), int(math.Round(p1[1]))
+	x1, y1 := int(math.Round(p2[0])), int(math.Round(p2[1]))
+	if !image.Pt(x0, y0).In(g.gray.Rect) {
+		return false, []int{x0, y0}, errcode.ErrorMsgNodeOvertopArea
+	}
+	if !image.Pt(x1, y1).In(g.gray.Rect) {
+		return false, []int{x1, y1}, errcode.ErrorMsgNodeOvertopArea
+	}
+	cells := g.inflate(radius)
+	rect := g.gray.Rect
+	for _, p := range utils.BresenhamLine(x0, y0, x1, y1) {
+		switch cells[(p.Y-rect.Min.Y)*rect.Dx()+p.X-rect.Min.X] {
+		case cellOccupied:
+			return false, []int{p.X, p.Y}, errcode.ErrorMsgRouteBlocked
+		case cellUnknown:
+			if !g.allowUnknown {
+				return false, []int{p.X, p.Y}, errcode.ErrorMsgRouteUnknownArea
+			}
+		}
+	}
+	return true, nil, ""
+}
@@ -14,19 +14,53 @@ type ResourceOperator struct {
 type Operator interface {
 	CreateOrUpdateMap(req *apimodel.MapRequest) error
 	ListMap(req *apimodel.MapRequest) (*apimodel.MapPageResponse, error)
-	DeleteMap(req *apimodel.MapRequest) error
+	DeleteMap(req *apimodel.MapRequest) (*apimodel.CascadeDeleteResponse, error)
 	CreateOrUpdateMapInfo(req *apimodel.MapInfoRequest) error
 	ListMapInfoPageResponse(req *apimodel.MapInfoRequest) (*apimodel.MapInfoPageResponse, error)
-	DeleteMapInfo(req *apimodel.MapInfoRequest) error
+	DeleteMapInfo(req *apimodel.MapInfoRequest) (*apimodel.CascadeDeleteResponse, error)
 	CreateOrUpdateNode(req *apimodel.RouteNodesRequest) error
 	ListMapNodes(req *apimodel.RouteNodesRequest) (*apimodel.RouteNodesResponse, error)
 	DeleteMapNodes(req *apimodel.RouteNodesRequest) error
+	RenameMapNode(req *apimodel.RenameNodeRequest) error
 	CreateOrUpdateMapRoute(req *apimodel.MapRoutesArrRequest) error
 	ListMapRoutes(req *apimodel.MapRoutesRequest) (*apimodel.MapRoutesResponse, error)
 	DeleteMapRoute(req *apimodel.MapRoutesRequest) error
-	CheckRoute(req *apimodel.MapRoutesArrRequest) error
+	CheckRoute(req *apimodel.CheckRouteRequest) (*apimodel.CheckRouteResponse, error)
 	ListMapInfo(req *apimodel.RouteNodesRequest) (*apimodel.MapInfosResponse, error)
 	BatchDeleteMapNodes(req *apimodel.BatchDeleteNodes) error
+	PlanRoute(req *apimodel.PlanRouteRequest) (*apimodel.PlanRouteResponse, error)
+	ImportRosMap(req *apimodel.RosMapImportRequest) (*apimodel.MapInfoInfo, error)
+	ExportRosMap(req *apimodel.MapInfoRequest) (*apimodel.RosMapBundle, error)
+	CreateOrUpdateMapConnector(req *apimodel.MapConnectorRequest) error
+	ListMapConnectors(req *apimodel.MapConnectorRequest) (*apimodel.MapConnectorResponse, error)
+	DeleteMapConnector(req *apimodel.MapConnectorRequest) error
+	CreateOrUpdateMapZone(req *apimodel.MapZoneRequest) error
+	ListMapZones(req *apimodel.MapZoneRequest) (*apimodel.MapZoneResponse, error)
+	DeleteMapZone(req *apimodel.MapZoneRequest) error
+	InitUpload(req *apimodel.UploadInitRequest) (*apimodel.UploadInfo, error)
+	GetUpload(req *apimodel.UploadRequest) (*apimodel.UploadInfo, error)
+	UploadChunk(req *apimodel.UploadChunkRequest) (*apimodel.UploadInfo, error)
+	CompleteUpload(req *apimodel.UploadRequest) (*apimodel.UploadInfo, error)
+	AbortUpload(req *apimodel.UploadRequest) error
+	PlanMapRoute(req *apimodel.PlanMapRouteRequest) (*apimodel.PlanMapRouteResponse, error)
+	PlanNearestNode(req *apimodel.PlanNearestRequest) (*apimodel.PlanNearestResponse, error)
+	ValidateMapInfo(req *apimodel.ValidateMapInfoRequest) (*apimodel.ValidateMapInfoResponse, error)
+	ListTrash(req *apimodel.TrashRequest) (*apimodel.TrashResponse, error)
+	RestoreTrash(req *apimodel.TrashRequest) (*apimodel.CascadeCount, error)
+	PurgeTrash(req *apimodel.PurgeTrashRequest) (*apimodel.PurgeTrashResponse, error)
+	ListMapRevisions(req *apimodel.MapRevisionRequest) (*apimodel.MapRevisionResponse, error)
+	DiffMapRevisions(req *apimodel.MapRevisionRequest) (*apimodel.MapRevisionDiffResponse, error)
+	RollbackMapRevision(req *apimodel.MapRevisionRequest) error
+	PublishMapInfo(req *apimodel.PublishMapInfoRequest) (*apimodel.PublishMapInfoResponse, error)
+	AcquireEditLock(req *apimodel.EditLockRequest) (*apimodel.EditLockInfo, error)
+	RenewEditLock(req *apimodel.EditLockRequest) (*apimodel.EditLockInfo, error)
+	ReleaseEditLock(req *apimodel.EditLockRequest) error
+	GetEditLock(req *apimodel.EditLockRequest) (*apimodel.EditLockInfo, error)
+	GenerateMapTiles(req *apimodel.MapTilesRequest) (*apimodel.MapTilesInfo, error)
+	GetMapTiles(req *apimodel.MapTilesRequest) (*apimodel.MapTilesInfo, error)
+	GetMapTile(req *apimodel.MapTileRequest) (*apimodel.MapTileData, error)
+	GenerateOccupancyMap(req *apimodel.OccupancyRequest) (*apimodel.OccupancyInfo, error)
+	GetOccupancyMap(req *apimodel.OccupancyRequest) (*apimodel.OccupancyInfo, error)
 }
 
 func GetOperator() Operator {
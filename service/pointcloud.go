@@ -0,0 +1,355 @@
+package service
+
+import (
+	"bytes"
+	"demo-gogo/api/apimodel"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils"
+	"demo-gogo/utils/storage"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/rs/xid"
+	log "github.com/wonderivan/logger"
+	"image"
+	"image/png"
+	"math"
+	"path"
+)
+
+const (
+	occupancyProgressStep = 1 << 16 //每读取若干个点更新一次进度
+	// 与ROS map_saver一致的灰度：占用0、空闲254、未知205
+	occupancyOccupied = 0
+	occupancyFree     = 254
+	occupancyUnknown  = 205
+)
+
+type occupancyOptions struct {
+	resolution float64
+	minPoints  int
+	format     string
+	edit       apimodel.EditContext //写入结果时校验切片编辑锁
+}
+
+// pointGrid 点云在xy平面上的投影栅格，行号自上而下(y轴向下)，左下角为(minX,minY)
+type pointGrid struct {
+	minX, minY float64
+	resolution float64
+	width      int
+	height     int
+	// cells 每个栅格2字节：0为没有点云覆盖(未观测)，否则为z轴范围内的点数+1，达到uint16上限后不再累加
+	cells []uint16
+}
+
+func newPointGrid(minX, minY, maxX, maxY, resolution float64) (*pointGrid, error) {
+	width := math.Floor((maxX-minX)/resolution) + 1
+	height := math.Floor((maxY-minY)/resolution) + 1
+	mapConf := config.Conf.Map
+	if maxSize := float64(mapConf.OccupancyMaxSize); maxSize > 0 && (width > maxSize || height > maxSize) {
+		return nil, fmt.Errorf("栅格尺寸[%.0fx%.0f]超过边长上限%d,请增大分辨率", width, height, mapConf.OccupancyMaxSize)
+	}
+	// 按栅格总数限制内存：栅格计数与生成的灰度图共占用3字节/栅格
+	if maxCells := float64(mapConf.OccupancyMaxCells); maxCells > 0 && width*height > maxCells {
+		return nil, fmt.Errorf("栅格尺寸[%.0fx%.0f]超过栅格总数上限%d,请增大分辨率", width, height, mapConf.OccupancyMaxCells)
+	}
+	return &pointGrid{
+		minX:       minX,
+		minY:       minY,
+		resolution: resolution,
+		width:      int(width),
+		height:     int(height),
+		cells:      make([]uint16, int(width)*int(height)),
+	}, nil
+}
+
+// add 记录投影到栅格的一个点，inBand为点位于z轴范围内
+func (g *pointGrid) add(i int, inBand bool) {
+	if g.cells[i] == 0 {
+		g.cells[i] = 1
+	}
+	if inBand && g.cells[i] < math.MaxUint16 {
+		g.cells[i]++
+	}
+}
+
+// cell 坐标所在栅格的下标
+func (g *pointGrid) cell(x, y float64) int {
+	col := int((x - g.minX) / g.resolution)
+	row := int((y - g.minY) / g.resolution)
+	col = int(math.Min(math.Max(float64(col), 0), float64(g.width-1)))
+	row = int(math.Min(math.Max(float64(row), 0), float64(g.height-1)))
+	return (g.height-1-row)*g.width + col
+}
+
+// image 按点数阈值生成占据栅格灰度图：z轴范围内点数达到阈值为占用，其余有点云覆盖的为空闲，没有点云的为未知
+func (g *pointGrid) image(minPoints int) *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, g.width, g.height))
+	for i, cell := range g.cells {
+		switch {
+		case cell == 0:
+			gray.Pix[i] = occupancyUnknown
+		case int(cell)-1 >= minPoints:
+			gray.Pix[i] = occupancyOccupied
+		default:
+			gray.Pix[i] = occupancyFree
+		}
+	}
+	return gray
+}
+
+// GenerateOccupancyMap 启动后台任务，由切片点云在z轴范围[origin,destination]内的点生成占据栅格地图并替换切片的地图图片
+func (operator *ResourceOperator) GenerateOccupancyMap(req *apimodel.OccupancyRequest) (*apimodel.OccupancyInfo, error) {
+	mapInfo, err := operator.findMapInfo(req.InfoID)
+	if err != nil {
+		return nil, err
+	}
+	if mapInfo.PointCloud == "" {
+		return nil, errors.New(errcode.ErrorMsgPointCloudEmpty)
+	}
+	if mapInfo.Destination <= mapInfo.Origin {
+		return nil, errors.New(errcode.ErrorMsgZBandInvalid)
+	}
+	if storage.Default == nil {
+		return nil, errors.New("文件存储未初始化")
+	}
+	// 切片被他人锁定时不启动任务，写入结果时在事务内再次校验
+	err = assertEditLock(req.InfoID, req.EditContext)
+	if err != nil {
+		return nil, err
+	}
+	opt := occupancyOptions{resolution: req.Resolution, minPoints: req.MinPoints, format: req.Format, edit: req.EditContext}
+	if opt.resolution <= 0 {
+		opt.resolution = mapInfo.Resolution
+	}
+	if opt.resolution <= 0 {
+		opt.resolution = config.Conf.Map.Resolution
+	}
+	if opt.minPoints <= 0 {
+		opt.minPoints = 1
+	}
+	if opt.format == "" {
+		opt.format = apimodel.OccupancyFormatPNG
+	}
+	params := map[string]interface{}{
+		"point_cloud": mapInfo.PointCloud,
+		"origin":      mapInfo.Origin,
+		"destination": mapInfo.Destination,
+		"resolution":  opt.resolution,
+		"min_points":  opt.minPoints,
+		"format":      opt.format,
+	}
+	job, err := startMapJob(model.GetMapOccupancyKey(req.InfoID), params, errcode.ErrorMsgOccupancyRunning)
+	if err != nil {
+		return nil, err
+	}
+	go operator.buildOccupancyMap(job, mapInfo, opt)
+	log.Info("地图切片[%d]点云转占据栅格任务启动,z轴范围[%v,%v] 分辨率[%v]", req.InfoID, mapInfo.Origin, mapInfo.Destination, opt.resolution)
+	return operator.GetOccupancyMap(req)
+}
+
+// GetOccupancyMap 查询切片的点云、z轴范围与最近一次占据栅格生成任务的进度
+func (operator *ResourceOperator) GetOccupancyMap(req *apimodel.OccupancyRequest) (*apimodel.OccupancyInfo, error) {
+	mapInfo, err := operator.findMapInfo(req.InfoID)
+	if err != nil {
+		return nil, err
+	}
+	return &apimodel.OccupancyInfo{
+		InfoID:      mapInfo.ID,
+		PointCloud:  storage.URL(mapInfo.PointCloud),
+		Origin:      mapInfo.Origin,
+		Destination: mapInfo.Destination,
+		MapURL:      storage.URL(mapInfo.MapURL),
+		Progress:    readMapJobProgress(model.GetMapOccupancyKey(req.InfoID)),
+	}, nil
+}
+
+// buildOccupancyMap 点云转占据栅格任务。点云读取两遍：第一遍统计z轴终点以下点的xy范围，第二遍投影到栅格
+func (operator *ResourceOperator) buildOccupancyMap(job *mapJob, mapInfo model.MapInfo, opt occupancyOptions) {
+	var err error
+	var key string
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil {
+			log.Error("地图切片[%d]点云转占据栅格失败. err:[%v]", mapInfo.ID, err)
+			removeStoredFile(key)
+		}
+		job.finish(err, "占据栅格地图生成完成")
+	}()
+
+	job.progress.Message = "正在统计点云范围"
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	bandPoints := 0
+	err = readPointCloud(job, mapInfo.PointCloud, func(x, y, z float64) {
+		if z > mapInfo.Destination {
+			return
+		}
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		if z >= mapInfo.Origin {
+			bandPoints++
+		}
+	})
+	if err != nil {
+		return
+	}
+	if bandPoints == 0 {
+		err = fmt.Errorf("z轴范围[%v,%v]内没有点云数据", mapInfo.Origin, mapInfo.Destination)
+		return
+	}
+	grid, err := newPointGrid(minX, minY, maxX, maxY, opt.resolution)
+	if err != nil {
+		return
+	}
+
+	job.progress.Message = "正在投影点云"
+	job.update()
+	err = readPointCloud(job, mapInfo.PointCloud, func(x, y, z float64) {
+		if z > mapInfo.Destination {
+			return
+		}
+		grid.add(grid.cell(x, y), z >= mapInfo.Origin)
+	})
+	if err != nil {
+		return
+	}
+	img := grid.image(opt.minPoints)
+	key, err = saveOccupancyImage(img, opt.format)
+	if err != nil {
+		return
+	}
+	err = operator.applyOccupancyMap(mapInfo, key, grid, img, opt.edit)
+	if err != nil {
+		return
+	}
+	log.Info("地图切片[%d]点云转占据栅格完成,栅格[%dx%d] 分辨率[%v] 地图[%s]", mapInfo.ID, grid.width, grid.height, grid.resolution, key)
+}
+
+// readPointCloud 读取一遍点云，按点数累计任务进度，总数为两遍读取的点数之和
+func readPointCloud(job *mapJob, pointCloud string, fn func(x, y, z float64)) error {
+	file, err := openStoredFile(pointCloud)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader, err := utils.NewPointCloudReader(file)
+	if err != nil {
+		return err
+	}
+	if job.progress.Total == 0 {
+		job.progress.Total = 2 * reader.Points
+		job.update()
+	}
+	var read int64
+	err = reader.Read(func(x, y, z float64) error {
+		fn(x, y, z)
+		read++
+		if read%occupancyProgressStep == 0 {
+			job.progress.Processed += occupancyProgressStep
+			job.update()
+		}
+		return nil
+	})
+	job.progress.Processed += read % occupancyProgressStep
+	return err
+}
+
+// saveOccupancyImage 将占据栅格地图以png或pgm格式写入文件存储，返回存储key
+func saveOccupancyImage(img *image.Gray, format string) (string, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == apimodel.OccupancyFormatPGM {
+		err = utils.EncodePGM(&buf, img)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return "", err
+	}
+	key := path.Join(folderRosMap, xid.New().String()+"."+format)
+	if err = storage.Default.Put(key, &buf, int64(buf.Len())); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// applyOccupancyMap 将生成的占据栅格地图写入切片：替换地图图片，按栅格更新分辨率与原点，并重新生成压缩图片、作废旧瓦片。
+// 切片内已有的节点、路径与区域在同一事务内经世界坐标换算到新图片并记录修订版本。
+// 生成期间切片的点云或z轴范围已变更、或切片已被他人锁定时结果作废
+func (operator *ResourceOperator) applyOccupancyMap(mapInfo model.MapInfo, key string, grid *pointGrid, img *image.Gray, ctx apimodel.EditContext) error {
+	// 压缩图片在锁定切片前生成，缩短行锁持有时间
+	compressKey, scale, err := saveCompressedMap(img)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			removeStoredFile(compressKey)
+		}
+	}()
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("applyOccupancyMap TransactionBegin Error.err[%v]", err)
+		return err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	current, err := tx.lockSliceForEdit(mapInfo.ID, ctx)
+	if err != nil {
+		return err
+	}
+	if current.PointCloud != mapInfo.PointCloud || current.Origin != mapInfo.Origin || current.Destination != mapInfo.Destination {
+		err = errors.New("生成期间点云或z轴范围已变更,结果作废")
+		return err
+	}
+	before, err := tx.loadSnapshot(current.ID)
+	if err != nil {
+		return err
+	}
+	previous := current
+	oldMapURL := current.MapURL
+	current.MapURL = key
+	current.Resolution = grid.resolution
+	current.MapOrigin = pq.Float64Array{grid.minX, grid.minY, 0}
+	current.YAxis = model.YAxisDown
+	current.Negate = false
+	target, err := newMapFrame(current)
+	if err != nil {
+		return err
+	}
+	moved, err := tx.reprojectSlice(current.ID, func() (*mapFrame, error) { return newMapFrame(previous) }, target)
+	if err != nil {
+		return err
+	}
+	if moved > 0 {
+		err = tx.recordRevision(current.ID, before, ctx, RevisionOpReproject)
+		if err != nil {
+			return err
+		}
+		log.Info("地图切片[%d]更换占据栅格地图,换算节点、路径与区域共[%d]条", current.ID, moved)
+	}
+	staleTiles := resetMapTiles(&current)
+	replaced := current.MapURLCompress
+	current.MapURLCompress, current.CompressScale = compressKey, scale
+	err = tx.Database.SaveEntity(model.TableNameMapInfo, &current)
+	if err == nil {
+		err = tx.TransactionCommit()
+	}
+	if err != nil {
+		log.Error("地图信息数据更新失败. err:[%v]", err)
+		return err
+	}
+	removeStoredFile(replaced)
+	if oldMapURL != key {
+		removeStoredFile(oldMapURL)
+	}
+	staleTiles.remove()
+	return nil
+}
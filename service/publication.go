@@ -0,0 +1,280 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"errors"
+	"fmt"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"sort"
+)
+
+// stageData 切片某一阶段的节点、路径与区域
+type stageData struct {
+	stage   string //实际使用的阶段
+	version int    //切片已发布版本号
+	nodes   []model.MapRouteNodes
+	routes  []model.MapRoutes
+	zones   []model.MapZone
+}
+
+// PublishMapInfo 校验切片草稿，通过后在事务内将草稿快照发布为新版本；
+// 草稿存在error级别的拓扑问题时不发布，仅返回问题列表，warning级别的问题不影响发布
+func (operator *ResourceOperator) PublishMapInfo(req *apimodel.PublishMapInfoRequest) (*apimodel.PublishMapInfoResponse, error) {
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("PublishMapInfo TransactionBegin Error.err[%v]", err)
+		return nil, err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	mapInfo, err := tx.lockSliceForEdit(req.InfoID, req.EditContext)
+	if err != nil {
+		return nil, err
+	}
+	resp := apimodel.PublishMapInfoResponse{InfoID: req.InfoID, Version: mapInfo.PublishedVersion}
+	graph, err := tx.loadSliceGraph(req.InfoID)
+	if err != nil {
+		return nil, err
+	}
+	resp.Issues, _ = graph.check()
+	blocking := 0
+	for _, v := range resp.Issues {
+		if v.Level == apimodel.IssueLevelError {
+			blocking++
+		}
+	}
+	if blocking > 0 {
+		log.Warn("地图切片[%d]草稿存在[%d]个error级别拓扑问题,取消发布", req.InfoID, blocking)
+		return &resp, nil
+	}
+	snapshot, err := tx.loadSnapshot(req.InfoID)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := tx.latestRevision(req.InfoID)
+	if err != nil {
+		return nil, err
+	}
+	// (info_id, version)唯一索引保证并发发布时只有一个成功
+	publication := model.MapPublication{
+		InfoID:          req.InfoID,
+		Version:         mapInfo.PublishedVersion + 1,
+		RevisionVersion: latest.Version,
+		Author:          req.Author,
+		Snapshot:        snapshot,
+	}
+	err = tx.Database.CreateEntity(model.TableNameMapPublication, &publication)
+	if err != nil {
+		log.Error("地图切片发布记录创建失败. err:[%v]", err)
+		return nil, err
+	}
+	selector := make(map[string]interface{})
+	selector[model.FieldID] = req.InfoID
+	updater := map[string]interface{}{model.FieldPublishedVersion: publication.Version}
+	err = tx.Database.UpdateEntityByFilter(model.TableNameMapInfo, selector, model.QueryParams{}, &updater)
+	if err != nil {
+		log.Error("地图切片发布版本更新失败. err:[%v]", err)
+		return nil, err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("PublishMapInfo TransactionCommit Error.err[%v]", err)
+		return nil, err
+	}
+	resp.Published = true
+	resp.Version = publication.Version
+	resp.RevisionVersion = publication.RevisionVersion
+	log.Info("地图切片[%d]已发布版本[%d],节点[%d]个 路径[%d]条 区域[%d]个", req.InfoID, publication.Version, len(snapshot.Nodes), len(snapshot.Routes), len(snapshot.Zones))
+	return &resp, nil
+}
+
+// loadStage 读取切片指定阶段的节点、路径与区域，均按id升序。draft为编辑中的当前数据；
+// published(默认，见apimodel.StagePublished)为最近一次发布的快照，切片从未发布时回退为当前数据，
+// 早期发布快照不含区域时使用当前区域
+func (operator *ResourceOperator) loadStage(infoID int, stage string) (*stageData, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, infoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	data := stageData{
+		stage:   apimodel.StagePublished,
+		version: mapInfo.PublishedVersion,
+		nodes:   make([]model.MapRouteNodes, 0),
+		routes:  make([]model.MapRoutes, 0),
+		zones:   make([]model.MapZone, 0),
+	}
+	if stage == apimodel.StageDraft || mapInfo.PublishedVersion == 0 {
+		data.stage = apimodel.StageDraft
+		selector := make(map[string]interface{})
+		selector[model.FieldInfoId] = infoID
+		queryParams := model.QueryParams{
+			Orders: []model.Order{{Field: model.FieldID, Direction: apimodel.OrderAsc}},
+		}
+		err = operator.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, queryParams, &data.routes)
+		if err != nil {
+			log.Error("路径数据查询失败. err:[%v]", err)
+			return nil, err
+		}
+		err = operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, queryParams, &data.nodes)
+		if err != nil {
+			log.Error("节点数据查询失败. err:[%v]", err)
+			return nil, err
+		}
+		err = operator.Database.ListEntityByFilter(model.TableNameMapZone, selector, queryParams, &data.zones)
+		if err != nil {
+			log.Error("区域数据查询失败. err:[%v]", err)
+			return nil, err
+		}
+		return &data, nil
+	}
+
+	var publication model.MapPublication
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	selector[model.FieldVersion] = mapInfo.PublishedVersion
+	err = operator.Database.ListEntityByFilter(model.TableNameMapPublication, selector, model.OneQuery, &publication)
+	if err != nil {
+		log.Error("地图切片发布记录查询失败. err:[%v]", err)
+		return nil, err
+	}
+	if publication.ID == 0 {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, fmt.Sprintf("发布版本[%d]", mapInfo.PublishedVersion))
+	}
+	for _, v := range publication.Snapshot.Nodes {
+		node := model.MapRouteNodes{InfoID: infoID}
+		v.Apply(&node)
+		data.nodes = append(data.nodes, node)
+	}
+	for _, v := range publication.Snapshot.Routes {
+		route := model.MapRoutes{InfoID: infoID}
+		v.Apply(&route)
+		data.routes = append(data.routes, route)
+	}
+	if publication.Snapshot.Zones == nil {
+		data.zones, err = operator.listZones(infoID)
+		if err != nil {
+			return nil, err
+		}
+		return &data, nil
+	}
+	for _, v := range publication.Snapshot.Zones {
+		zone := model.MapZone{InfoID: infoID}
+		v.Apply(&zone)
+		data.zones = append(data.zones, zone)
+	}
+	return &data, nil
+}
+
+// listPublishedNodes 从已发布快照中筛选节点。快照不含创建时间，按name或id升序排列后分页
+func (operator *ResourceOperator) listPublishedNodes(req *apimodel.RouteNodesRequest) ([]model.MapRouteNodes, int64, error) {
+	data, err := operator.loadStage(req.InfoID, apimodel.StagePublished)
+	if err != nil {
+		return nil, 0, err
+	}
+	nodes := make([]model.MapRouteNodes, 0, len(data.nodes))
+	for _, v := range data.nodes {
+		if req.ID > 0 && v.ID != req.ID {
+			continue
+		}
+		if req.NodeName != "" && v.NodeName != req.NodeName {
+			continue
+		}
+		if req.Type != "" && nodeType(v.Type) != req.Type {
+			continue
+		}
+		nodes = append(nodes, v)
+	}
+	if req.OrderBy == model.FieldName {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].NodeName < nodes[j].NodeName
+		})
+	}
+	start, end := pageRange(len(nodes), req.PaginationRequest)
+	return nodes[start:end], int64(len(nodes)), nil
+}
+
+// nodeType 早期快照中的节点没有类型，按普通节点处理
+func nodeType(t string) string {
+	if t == "" {
+		return model.NodeTypeNormal
+	}
+	return t
+}
+
+// listPublishedRoutes 从已发布快照中筛选路径，排序与分页同listPublishedNodes
+func (operator *ResourceOperator) listPublishedRoutes(req *apimodel.MapRoutesRequest) ([]model.MapRoutes, int64, error) {
+	data, err := operator.loadStage(req.InfoID, apimodel.StagePublished)
+	if err != nil {
+		return nil, 0, err
+	}
+	routes := make([]model.MapRoutes, 0, len(data.routes))
+	for _, v := range data.routes {
+		if req.ID > 0 && v.ID != req.ID {
+			continue
+		}
+		if req.RoutesName != "" && v.RoutesName != req.RoutesName {
+			continue
+		}
+		routes = append(routes, v)
+	}
+	if req.OrderBy == model.FieldName {
+		sort.SliceStable(routes, func(i, j int) bool {
+			return routes[i].RoutesName < routes[j].RoutesName
+		})
+	}
+	start, end := pageRange(len(routes), req.PaginationRequest)
+	return routes[start:end], int64(len(routes)), nil
+}
+
+// listPublishedZones 从已发布快照中筛选区域，排序与分页同listPublishedNodes
+func (operator *ResourceOperator) listPublishedZones(req *apimodel.MapZoneRequest) ([]model.MapZone, int64, error) {
+	data, err := operator.loadStage(req.InfoID, apimodel.StagePublished)
+	if err != nil {
+		return nil, 0, err
+	}
+	zones := make([]model.MapZone, 0, len(data.zones))
+	for _, v := range data.zones {
+		if req.ID > 0 && v.ID != req.ID {
+			continue
+		}
+		if req.Name != "" && v.Name != req.Name {
+			continue
+		}
+		if req.Type != "" && v.Type != req.Type {
+			continue
+		}
+		zones = append(zones, v)
+	}
+	if req.OrderBy == model.FieldName {
+		sort.SliceStable(zones, func(i, j int) bool {
+			return zones[i].Name < zones[j].Name
+		})
+	}
+	start, end := pageRange(len(zones), req.PaginationRequest)
+	return zones[start:end], int64(len(zones)), nil
+}
+
+// pageRange 内存分页的下标范围，pageSize为0代表不分页
+func pageRange(total int, page apimodel.PaginationRequest) (int, int) {
+	if page.PageSize <= 0 {
+		return 0, total
+	}
+	start := (page.PageNo - 1) * page.PageSize
+	if start > total {
+		start = total
+	}
+	end := start + page.PageSize
+	if end > total {
+		end = total
+	}
+	return start, end
+}
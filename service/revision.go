@@ -0,0 +1,499 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils"
+	"errors"
+	"fmt"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"reflect"
+	"time"
+)
+
+const (
+	RevisionOpBaseline    = "初始版本"
+	RevisionOpSaveNode    = "保存节点"
+	RevisionOpSaveRoutes  = "保存路径"
+	RevisionOpDeleteNode  = "删除节点"
+	RevisionOpDeleteNodes = "批量删除节点"
+	RevisionOpDeleteRoute = "删除路径"
+	RevisionOpFix         = "拓扑自动修复"
+	RevisionOpRestore     = "回收站恢复"
+	RevisionOpRenameNode  = "重命名节点"
+	RevisionOpRollback    = "回滚至版本%d"
+	RevisionOpReproject   = "更换地图坐标换算"
+	RevisionOpSaveZone    = "保存区域"
+	RevisionOpDeleteZone  = "删除区域"
+)
+
+// loadSnapshot 读取地图切片当前全部节点、路径与区域
+func (operator *ResourceOperator) loadSnapshot(infoID int) (model.RevisionSnapshot, error) {
+	snapshot := model.RevisionSnapshot{
+		Nodes:  make([]model.RevisionNode, 0),
+		Routes: make([]model.RevisionRoute, 0),
+		Zones:  make([]model.RevisionZone, 0),
+	}
+	var nodes []model.MapRouteNodes
+	var routes []model.MapRoutes
+	var zones []model.MapZone
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	queryParams := model.QueryParams{
+		Orders: []model.Order{{Field: model.FieldID, Direction: apimodel.OrderAsc}},
+	}
+	err := operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, queryParams, &nodes)
+	if err != nil {
+		return snapshot, err
+	}
+	err = operator.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, queryParams, &routes)
+	if err != nil {
+		return snapshot, err
+	}
+	err = operator.Database.ListEntityByFilter(model.TableNameMapZone, selector, queryParams, &zones)
+	if err != nil {
+		return snapshot, err
+	}
+	for _, v := range nodes {
+		snapshot.Nodes = append(snapshot.Nodes, model.NewRevisionNode(v))
+	}
+	for _, v := range routes {
+		snapshot.Routes = append(snapshot.Routes, model.NewRevisionRoute(v))
+	}
+	for _, v := range zones {
+		snapshot.Zones = append(snapshot.Zones, model.NewRevisionZone(v))
+	}
+	return snapshot, nil
+}
+
+// recordRevision 记录一次修改后的切片快照，before为修改前的快照。
+// 切片尚无修订记录时先以before生成初始版本，保证首次修改也可回滚
+func (operator *ResourceOperator) recordRevision(infoID int, before model.RevisionSnapshot, ctx apimodel.EditContext, operation string) error {
+	after, err := operator.loadSnapshot(infoID)
+	if err != nil {
+		return err
+	}
+	latest, err := operator.latestRevision(infoID)
+	if err != nil {
+		return err
+	}
+	version := latest.Version
+	if latest.ID == 0 && len(before.Nodes)+len(before.Routes)+len(before.Zones) > 0 {
+		version++
+		baseline := model.MapRevision{
+			InfoID:    infoID,
+			Version:   version,
+			Author:    ctx.Author,
+			Operation: RevisionOpBaseline,
+			Summary:   diffSnapshot(model.RevisionSnapshot{Zones: make([]model.RevisionZone, 0)}, before).Summary(),
+			Snapshot:  before,
+		}
+		err = operator.Database.CreateEntity(model.TableNameMapRevision, &baseline)
+		if err != nil {
+			log.Error("地图切片初始版本创建失败. err:[%v]", err)
+			return err
+		}
+	}
+	revision := model.MapRevision{
+		InfoID:    infoID,
+		Version:   version + 1,
+		Author:    ctx.Author,
+		Operation: operation,
+		Summary:   diffSnapshot(before, after).Summary(),
+		Snapshot:  after,
+	}
+	err = operator.Database.CreateEntity(model.TableNameMapRevision, &revision)
+	if err != nil {
+		log.Error("地图切片修订版本创建失败. err:[%v]", err)
+		return err
+	}
+	return nil
+}
+
+// latestRevision 切片最新的修订版本，尚无修订记录时返回空记录
+func (operator *ResourceOperator) latestRevision(infoID int) (*model.MapRevision, error) {
+	var latest model.MapRevision
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	params := model.OneQuery
+	params.Orders = []model.Order{{Field: model.FieldVersion, Direction: apimodel.OrderDesc}}
+	err := operator.Database.ListEntityByFilter(model.TableNameMapRevision, selector, params, &latest)
+	if err != nil {
+		return nil, err
+	}
+	return &latest, nil
+}
+
+func (operator *ResourceOperator) ListMapRevisions(req *apimodel.MapRevisionRequest) (*apimodel.MapRevisionResponse, error) {
+	var resp apimodel.MapRevisionResponse
+	selector := make(map[string]interface{})
+	queryParams := model.QueryParams{}
+	selector[model.FieldInfoId] = req.InfoID
+	var count int64
+	var revisions []model.MapRevision
+	err := operator.Database.CountEntityByFilter(model.TableNameMapRevision, selector, model.QueryParams{}, &count)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		queryParams.Orders = append(queryParams.Orders, model.Order{Field: req.OrderBy, Direction: req.Order})
+		if req.PageSize > 0 {
+			queryParams.Limit = &req.PageSize
+			offset := (req.PageNo - 1) * req.PageSize
+			queryParams.Offset = &offset
+		}
+		err = operator.Database.ListEntityByFilter(model.TableNameMapRevision, selector, queryParams, &revisions)
+		if err != nil {
+			log.Error("地图切片修订版本查询失败. err:[%v]", err)
+			return nil, err
+		}
+	}
+	resp.Load(count, revisions)
+	return &resp, nil
+}
+
+// DiffMapRevisions 对比切片的两个修订版本
+func (operator *ResourceOperator) DiffMapRevisions(req *apimodel.MapRevisionRequest) (*apimodel.MapRevisionDiffResponse, error) {
+	from, err := operator.getRevision(req.InfoID, req.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := operator.getRevision(req.InfoID, req.To)
+	if err != nil {
+		return nil, err
+	}
+	resp := diffSnapshot(from.Snapshot, to.Snapshot)
+	resp.InfoID = req.InfoID
+	resp.From = req.From
+	resp.To = req.To
+	return &resp, nil
+}
+
+// RollbackMapRevision 将切片的节点、路径与区域整体恢复至指定版本，并记录为新版本
+func (operator *ResourceOperator) RollbackMapRevision(req *apimodel.MapRevisionRequest) error {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, req.InfoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return err
+	}
+	target, err := operator.getRevision(req.InfoID, req.Version)
+	if err != nil {
+		return err
+	}
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("RollbackMapRevision TransactionBegin Error.err[%v]", err)
+		return err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	_, err = tx.lockSliceForEdit(req.InfoID, req.EditContext)
+	if err != nil {
+		return err
+	}
+	before, err := tx.loadSnapshot(req.InfoID)
+	if err != nil {
+		return err
+	}
+	err = tx.applySnapshot(req.InfoID, target.Snapshot)
+	if err != nil {
+		return err
+	}
+	err = tx.recordRevision(req.InfoID, before, req.EditContext, fmt.Sprintf(RevisionOpRollback, req.Version))
+	if err != nil {
+		return err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("RollbackMapRevision TransactionCommit Error.err[%v]", err)
+		return err
+	}
+	log.Info("地图切片[%d]已回滚至版本[%d]", req.InfoID, req.Version)
+	return nil
+}
+
+func (operator *ResourceOperator) getRevision(infoID, version int) (*model.MapRevision, error) {
+	var revision model.MapRevision
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	selector[model.FieldVersion] = version
+	err := operator.Database.ListEntityByFilter(model.TableNameMapRevision, selector, model.OneQuery, &revision)
+	if err != nil {
+		return nil, err
+	}
+	if revision.ID == 0 {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, fmt.Sprintf("版本[%d]", version))
+	}
+	return &revision, nil
+}
+
+// applySnapshot 使切片当前的节点、路径与区域与快照一致：快照外的数据逻辑删除，
+// 快照内已删除的数据恢复后按快照内容覆盖，已被永久清理的数据按原id重建。早期快照不含区域，区域保持不变
+func (operator *ResourceOperator) applySnapshot(infoID int, snapshot model.RevisionSnapshot) error {
+	now := time.Now()
+	nodeIDs := make([]int, 0, len(snapshot.Nodes))
+	for _, v := range snapshot.Nodes {
+		nodeIDs = append(nodeIDs, v.ID)
+	}
+	routeIDs := make([]int, 0, len(snapshot.Routes))
+	for _, v := range snapshot.Routes {
+		routeIDs = append(routeIDs, v.ID)
+	}
+	err := operator.retainOnly(model.TableNameMapRouteNodes, infoID, nodeIDs, now)
+	if err != nil {
+		return err
+	}
+	err = operator.retainOnly(model.TableNameMapRoutes, infoID, routeIDs, now)
+	if err != nil {
+		return err
+	}
+
+	nodes := make(map[int]model.MapRouteNodes)
+	var nodeList []model.MapRouteNodes
+	if len(nodeIDs) > 0 {
+		err = operator.Database.ListUnscopedEntityByFilter(model.TableNameMapRouteNodes, model.EmptyFilter, idQuery(nodeIDs), &nodeList)
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range nodeList {
+		nodes[v.ID] = v
+	}
+	for _, v := range snapshot.Nodes {
+		node, ok := nodes[v.ID]
+		v.Apply(&node)
+		node.InfoID = infoID
+		node.DeletedAt = gorm.DeletedAt{}
+		if ok {
+			err = operator.Database.SaveEntity(model.TableNameMapRouteNodes, &node)
+		} else {
+			err = operator.Database.CreateEntity(model.TableNameMapRouteNodes, &node)
+		}
+		if err != nil {
+			log.Error("回滚节点[%s]失败. err:[%v]", v.Name, err)
+			return err
+		}
+	}
+
+	routes := make(map[int]model.MapRoutes)
+	var routeList []model.MapRoutes
+	if len(routeIDs) > 0 {
+		err = operator.Database.ListUnscopedEntityByFilter(model.TableNameMapRoutes, model.EmptyFilter, idQuery(routeIDs), &routeList)
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range routeList {
+		routes[v.ID] = v
+	}
+	for _, v := range snapshot.Routes {
+		route, ok := routes[v.ID]
+		v.Apply(&route)
+		route.InfoID = infoID
+		route.DeletedAt = gorm.DeletedAt{}
+		if ok {
+			err = operator.Database.SaveEntity(model.TableNameMapRoutes, &route)
+		} else {
+			err = operator.Database.CreateEntity(model.TableNameMapRoutes, &route)
+		}
+		if err != nil {
+			log.Error("回滚路径[%s]失败. err:[%v]", v.Name, err)
+			return err
+		}
+	}
+	if snapshot.Zones == nil {
+		return nil
+	}
+	return operator.applySnapshotZones(infoID, snapshot.Zones, now)
+}
+
+func (operator *ResourceOperator) applySnapshotZones(infoID int, snapshot []model.RevisionZone, now time.Time) error {
+	zoneIDs := make([]int, 0, len(snapshot))
+	for _, v := range snapshot {
+		zoneIDs = append(zoneIDs, v.ID)
+	}
+	err := operator.retainOnly(model.TableNameMapZone, infoID, zoneIDs, now)
+	if err != nil {
+		return err
+	}
+	zones := make(map[int]model.MapZone)
+	var zoneList []model.MapZone
+	if len(zoneIDs) > 0 {
+		err = operator.Database.ListUnscopedEntityByFilter(model.TableNameMapZone, model.EmptyFilter, idQuery(zoneIDs), &zoneList)
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range zoneList {
+		zones[v.ID] = v
+	}
+	for _, v := range snapshot {
+		zone, ok := zones[v.ID]
+		v.Apply(&zone)
+		zone.InfoID = infoID
+		zone.DeletedAt = gorm.DeletedAt{}
+		if ok {
+			err = operator.Database.SaveEntity(model.TableNameMapZone, &zone)
+		} else {
+			err = operator.Database.CreateEntity(model.TableNameMapZone, &zone)
+		}
+		if err != nil {
+			log.Error("回滚区域[%s]失败. err:[%v]", v.Name, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// retainOnly 逻辑删除切片中id不在ids内的数据，并恢复ids内已逻辑删除的数据
+func (operator *ResourceOperator) retainOnly(table string, infoID int, ids []int, now time.Time) error {
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	queryParams := model.QueryParams{}
+	if len(ids) > 0 {
+		queryParams.NotInQueries = append(queryParams.NotInQueries, map[string]interface{}{model.FieldID: ids})
+	}
+	err := softDeleteEntity(operator.Database, table, selector, queryParams, now)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	updater := map[string]interface{}{model.FieldDeletedTime: nil}
+	return operator.Database.UpdateEntityByFilter(table, model.EmptyFilter, idQuery(ids), &updater)
+}
+
+func idQuery(ids []int) model.QueryParams {
+	queryParams := model.QueryParams{}
+	queryParams.InQueries = append(queryParams.InQueries, &model.InQuery{Field: model.FieldID, Values: ids})
+	return queryParams
+}
+
+// diffSnapshot 对比两个快照，节点、路径与区域按id对应
+func diffSnapshot(from, to model.RevisionSnapshot) apimodel.MapRevisionDiffResponse {
+	resp := apimodel.MapRevisionDiffResponse{
+		Nodes: apimodel.NodeDiff{
+			Added:   make([]model.RevisionNode, 0),
+			Removed: make([]model.RevisionNode, 0),
+			Changed: make([]apimodel.NodeChange, 0),
+		},
+		Routes: apimodel.RouteDiff{
+			Added:   make([]model.RevisionRoute, 0),
+			Removed: make([]model.RevisionRoute, 0),
+			Changed: make([]apimodel.RouteChange, 0),
+		},
+		Zones: apimodel.ZoneDiff{
+			Added:   make([]model.RevisionZone, 0),
+			Removed: make([]model.RevisionZone, 0),
+			Changed: make([]apimodel.ZoneChange, 0),
+		},
+	}
+	fromNodes := make(map[int]model.RevisionNode)
+	for _, v := range from.Nodes {
+		fromNodes[v.ID] = v
+	}
+	toNodes := make(map[int]struct{})
+	for _, v := range to.Nodes {
+		toNodes[v.ID] = struct{}{}
+		old, ok := fromNodes[v.ID]
+		if !ok {
+			resp.Nodes.Added = append(resp.Nodes.Added, v)
+			continue
+		}
+		changes := utils.GetDifference(old, v)
+		if changes == "" {
+			continue
+		}
+		resp.Nodes.Changed = append(resp.Nodes.Changed, apimodel.NodeChange{
+			ID:      v.ID,
+			Name:    v.Name,
+			Moved:   !reflect.DeepEqual([]float64(old.Roi), []float64(v.Roi)),
+			Changes: changes,
+			Before:  old,
+			After:   v,
+		})
+	}
+	for _, v := range from.Nodes {
+		if _, ok := toNodes[v.ID]; !ok {
+			resp.Nodes.Removed = append(resp.Nodes.Removed, v)
+		}
+	}
+
+	fromRoutes := make(map[int]model.RevisionRoute)
+	for _, v := range from.Routes {
+		fromRoutes[v.ID] = v
+	}
+	toRoutes := make(map[int]struct{})
+	for _, v := range to.Routes {
+		toRoutes[v.ID] = struct{}{}
+		old, ok := fromRoutes[v.ID]
+		if !ok {
+			resp.Routes.Added = append(resp.Routes.Added, v)
+			continue
+		}
+		changes := utils.GetDifference(old, v)
+		if changes == "" {
+			continue
+		}
+		moved := old.StartNodeID != v.StartNodeID || old.EndNodeID != v.EndNodeID || old.Shape != v.Shape ||
+			!reflect.DeepEqual([]float64(old.ControlPoints), []float64(v.ControlPoints)) ||
+			!reflect.DeepEqual([]float64(old.StartRoi), []float64(v.StartRoi)) ||
+			!reflect.DeepEqual([]float64(old.EndRoi), []float64(v.EndRoi))
+		resp.Routes.Changed = append(resp.Routes.Changed, apimodel.RouteChange{
+			ID:      v.ID,
+			Name:    v.Name,
+			Moved:   moved,
+			Changes: changes,
+			Before:  old,
+			After:   v,
+		})
+	}
+	for _, v := range from.Routes {
+		if _, ok := toRoutes[v.ID]; !ok {
+			resp.Routes.Removed = append(resp.Routes.Removed, v)
+		}
+	}
+
+	// 早期快照不含区域，无法判断区域变化
+	if from.Zones == nil || to.Zones == nil {
+		return resp
+	}
+	fromZones := make(map[int]model.RevisionZone)
+	for _, v := range from.Zones {
+		fromZones[v.ID] = v
+	}
+	toZones := make(map[int]struct{})
+	for _, v := range to.Zones {
+		toZones[v.ID] = struct{}{}
+		old, ok := fromZones[v.ID]
+		if !ok {
+			resp.Zones.Added = append(resp.Zones.Added, v)
+			continue
+		}
+		changes := utils.GetDifference(old, v)
+		if changes == "" {
+			continue
+		}
+		resp.Zones.Changed = append(resp.Zones.Changed, apimodel.ZoneChange{
+			ID:      v.ID,
+			Name:    v.Name,
+			Changes: changes,
+			Before:  old,
+			After:   v,
+		})
+	}
+	for _, v := range from.Zones {
+		if _, ok := toZones[v.ID]; !ok {
+			resp.Zones.Removed = append(resp.Zones.Removed, v)
+		}
+	}
+	return resp
+}
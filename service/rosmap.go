@@ -0,0 +1,289 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"demo-gogo/api/apimodel"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils"
+	"demo-gogo/utils/storage"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/rs/xid"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"path"
+	"strings"
+)
+
+const (
+	rosMapYamlName  = "map.yaml"
+	rosMapImageName = "map.pgm"
+	folderRosMap    = "maps"
+)
+
+// ImportRosMap 导入ROS map_server地图包(yaml+图片)，图片统一转存为png并创建地图切片
+func (operator *ResourceOperator) ImportRosMap(req *apimodel.RosMapImportRequest) (*apimodel.MapInfoInfo, error) {
+	yamlData, imageData, err := readRosMapBundle(req)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := utils.ParseRosMapYAML(yamlData)
+	if err != nil {
+		log.Error("ROS地图yaml解析失败,err:[%v]", err)
+		return nil, fmt.Errorf("%s:%v", errcode.ErrorMsgRosMapYaml, err)
+	}
+	// 压缩后很小的图片可能声明极大的尺寸，解码前按图片头校验
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		log.Error("ROS地图图片[%s]解码失败,err:[%v]", meta.Image, err)
+		return nil, errors.New(errcode.ErrorMsgMapImageDecode)
+	}
+	if limit := config.Conf.Map.RosMapMaxPixels; limit > 0 && int64(cfg.Width)*int64(cfg.Height) > limit {
+		log.Error("ROS地图图片[%s]尺寸[%dx%d]超过像素数上限[%d]", meta.Image, cfg.Width, cfg.Height, limit)
+		return nil, errors.New(errcode.ErrorMsgRosMapTooLarge)
+	}
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		log.Error("ROS地图图片[%s]解码失败,err:[%v]", meta.Image, err)
+		return nil, errors.New(errcode.ErrorMsgMapImageDecode)
+	}
+	name := req.Name
+	if name == "" {
+		name = strings.TrimSuffix(path.Base(meta.Image), path.Ext(meta.Image))
+	}
+
+	var opt model.MapInfo
+	var mapDB model.Map
+	selector := make(map[string]interface{})
+	selector[model.FieldName] = name
+	err = operator.Database.ListEntityByFilter(model.TableNameMapInfo, selector, model.OneQuery, &opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.ID != 0 {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamExists, "地图信息")
+	}
+	err = operator.Database.GetEntityByID(model.TableNameMap, req.MapID, &mapDB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "关联地图")
+		}
+		return nil, err
+	}
+
+	imageKey, err := saveMapImage(toGray(img))
+	if err != nil {
+		log.Error("ROS地图图片保存失败,err:[%v]", err)
+		return nil, err
+	}
+	mapInfo := model.MapInfo{
+		Name:           name,
+		MapURL:         imageKey,
+		MapID:          req.MapID,
+		Negate:         meta.Negate == 1,
+		OccupiedThresh: &meta.OccupiedThresh,
+		FreeThresh:     &meta.FreeThresh,
+		Resolution:     meta.Resolution,
+		MapOrigin:      pq.Float64Array(meta.Origin),
+		YAxis:          model.YAxisDown,
+	}
+	err = operator.Database.CreateEntity(model.TableNameMapInfo, &mapInfo)
+	if err != nil {
+		log.Error("地图信息数据创建失败. err:[%v]", err)
+		_ = storage.Default.Delete(imageKey)
+		return nil, err
+	}
+	operator.startCompressJob(mapInfo.ID)
+	var info apimodel.MapInfoInfo
+	info.Load(mapInfo)
+	return &info, nil
+}
+
+// ExportRosMap 将地图切片导出为ROS map_server可直接加载的zip包(map.yaml+map.pgm)，
+// ROS地图图片的首行为最上方，y轴向上的切片导出时上下翻转
+func (operator *ResourceOperator) ExportRosMap(req *apimodel.MapInfoRequest) (*apimodel.RosMapBundle, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, req.ID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	img, err := decodeMapImage(mapInfo.MapURL)
+	if err != nil {
+		return nil, err
+	}
+	grid := newOccupancyGrid(img, mapInfo)
+	meta := utils.RosMapMeta{
+		Image:          rosMapImageName,
+		Mode:           "trinary",
+		Resolution:     mapInfo.Resolution,
+		Origin:         mapInfo.MapOrigin,
+		OccupiedThresh: grid.occupiedThresh,
+		FreeThresh:     grid.freeThresh,
+	}
+	if meta.Resolution <= 0 {
+		meta.Resolution = config.Conf.Map.Resolution
+	}
+	if len(meta.Origin) != 3 {
+		meta.Origin = []float64{0, 0, 0}
+	}
+	if grid.negate {
+		meta.Negate = 1
+	}
+	yamlData, err := utils.MarshalRosMapYAML(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	writer, err := zipWriter.Create(rosMapYamlName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(yamlData); err != nil {
+		return nil, err
+	}
+	writer, err = zipWriter.Create(rosMapImageName)
+	if err != nil {
+		return nil, err
+	}
+	gray := grid.gray
+	if mapInfo.YAxis == model.YAxisUp {
+		gray = utils.FlipVertical(gray)
+	}
+	if err = utils.EncodePGM(writer, gray); err != nil {
+		return nil, err
+	}
+	if err = zipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return &apimodel.RosMapBundle{FileName: mapInfo.Name + ".zip", Data: buf.Bytes()}, nil
+}
+
+// readRosMapBundle 读取上传的yaml与图片内容，zip包中按yaml的image字段查找图片
+func readRosMapBundle(req *apimodel.RosMapImportRequest) ([]byte, []byte, error) {
+	if req.File == nil {
+		yamlData, err := readMultipartFile(req.Yaml)
+		if err != nil {
+			return nil, nil, err
+		}
+		imageData, err := readMultipartFile(req.Image)
+		if err != nil {
+			return nil, nil, err
+		}
+		return yamlData, imageData, nil
+	}
+	data, err := readMultipartFile(req.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Error("ROS地图包解压失败,err:[%v]", err)
+		return nil, nil, errors.New(errcode.ErrorMsgRosMapBundle)
+	}
+	files := make(map[string]*zip.File)
+	var yamlFile *zip.File
+	for _, f := range zipReader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		files[f.Name] = f
+		ext := strings.ToLower(path.Ext(f.Name))
+		if yamlFile == nil && (ext == ".yaml" || ext == ".yml") {
+			yamlFile = f
+		}
+	}
+	if yamlFile == nil {
+		return nil, nil, errors.New(errcode.ErrorMsgRosMapBundle)
+	}
+	yamlData, err := readZipFile(yamlFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, err := utils.ParseRosMapYAML(yamlData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s:%v", errcode.ErrorMsgRosMapYaml, err)
+	}
+	// image为相对yaml文件的路径
+	imageFile, ok := files[path.Join(path.Dir(yamlFile.Name), meta.Image)]
+	if !ok {
+		return nil, nil, errors.New(errcode.ErrorMsgRosMapBundle)
+	}
+	imageData, err := readZipFile(imageFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return yamlData, imageData, nil
+}
+
+func readMultipartFile(header *multipart.FileHeader) ([]byte, error) {
+	if limit := config.Conf.Map.RosMapMaxBytes; limit > 0 && header.Size > limit {
+		log.Error("ROS地图文件[%s]大小[%d]超过上限[%d]", header.Filename, header.Size, limit)
+		return nil, errors.New(errcode.ErrorMsgRosMapTooLarge)
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLimited(f, header.Filename)
+}
+
+// readZipFile 读取zip内的文件，先按声明的解压大小拒绝，再限制实际读取量，防止压缩炸弹
+func readZipFile(f *zip.File) ([]byte, error) {
+	if limit := config.Conf.Map.RosMapMaxBytes; limit > 0 && f.UncompressedSize64 > uint64(limit) {
+		log.Error("ROS地图包内文件[%s]解压大小[%d]超过上限[%d]", f.Name, f.UncompressedSize64, limit)
+		return nil, errors.New(errcode.ErrorMsgRosMapTooLarge)
+	}
+	reader, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readLimited(reader, f.Name)
+}
+
+// readLimited 最多读取配置上限的字节数，超出时报错
+func readLimited(reader io.Reader, name string) ([]byte, error) {
+	limit := config.Conf.Map.RosMapMaxBytes
+	if limit <= 0 {
+		return io.ReadAll(reader)
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		log.Error("ROS地图文件[%s]超过大小上限[%d]", name, limit)
+		return nil, errors.New(errcode.ErrorMsgRosMapTooLarge)
+	}
+	return data, nil
+}
+
+// saveMapImage 将地图图片以png格式写入文件存储，返回存储key
+func saveMapImage(img image.Image) (string, error) {
+	if storage.Default == nil {
+		return "", errors.New("文件存储未初始化")
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	key := path.Join(folderRosMap, xid.New().String()+".png")
+	if err := storage.Default.Put(key, &buf, int64(buf.Len())); err != nil {
+		return "", err
+	}
+	return key, nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/utils"
+	"github.com/lib/pq"
+)
+
+const bezierSegments = 32 //贝塞尔曲线采样段数
+
+// routeShape 路径的几何形状及中间控制点
+type routeShape struct {
+	shape   string
+	control pq.Float64Array
+}
+
+// routeSplit 路径在吸附点处拆分的结果
+type routeSplit struct {
+	point      pq.Float64Array
+	head, tail routeShape //起点至吸附点、吸附点至终点两段的几何
+}
+
+// toPoints 将[x1,y1,x2,y2...]展开的坐标转为点列表
+func toPoints(values []float64) [][2]float64 {
+	points := make([][2]float64, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		points = append(points, [2]float64{values[i], values[i+1]})
+	}
+	return points
+}
+
+// flattenPoints 将点列表展开为[x1,y1,x2,y2...]
+func flattenPoints(points [][2]float64) pq.Float64Array {
+	values := make(pq.Float64Array, 0, len(points)*2)
+	for _, p := range points {
+		values = append(values, p[0], p[1])
+	}
+	return values
+}
+
+// polylineShape 以points为途经点的折线，没有途经点时为直线
+func polylineShape(points [][2]float64) routeShape {
+	if len(points) == 0 {
+		return routeShape{shape: model.RouteShapeLine}
+	}
+	return routeShape{shape: model.RouteShapePolyline, control: flattenPoints(points)}
+}
+
+// routeGeometry 路径的采样几何，依次为起点、途经点(贝塞尔曲线为采样点)、终点，端点坐标缺失时返回false
+func routeGeometry(shape string, control, start, end []float64) ([][2]float64, bool) {
+	if len(start) < 2 || len(end) < 2 {
+		return nil, false
+	}
+	p0, p3 := [2]float64{start[0], start[1]}, [2]float64{end[0], end[1]}
+	points := toPoints(control)
+	switch shape {
+	case model.RouteShapeBezier:
+		if len(points) == 2 {
+			samples := make([][2]float64, 0, bezierSegments+1)
+			for i := 0; i <= bezierSegments; i++ {
+				samples = append(samples, utils.CubicBezier(p0, points[0], points[1], p3, float64(i)/bezierSegments))
+			}
+			return samples, true
+		}
+	case model.RouteShapePolyline:
+		samples := make([][2]float64, 0, len(points)+2)
+		samples = append(samples, p0)
+		samples = append(samples, points...)
+		return append(samples, p3), true
+	}
+	return [][2]float64{p0, p3}, true
+}
+
+// fillRouteGeometry 按起终点坐标计算路径的采样几何与长度(像素)
+func fillRouteGeometry(route *apimodel.MapRoutesInfo) {
+	points, ok := routeGeometry(route.Shape, route.ControlPoints, route.StartRoi, route.EndRoi)
+	if !ok {
+		return
+	}
+	route.Geometry = make([]pq.Float64Array, 0, len(points))
+	for _, p := range points {
+		route.Geometry = append(route.Geometry, pq.Float64Array{p[0], p[1]})
+	}
+	route.Length = utils.PolylineLength(points)
+}
+
+// snapToRoute 判断点p是否落在路径几何上(到几何的距离小于tolerance)，是则返回路径上的吸附点及拆分后两段的几何
+func snapToRoute(p []float64, route model.MapRoutes, start, end []float64, tolerance float64) (*routeSplit, bool) {
+	if len(p) < 2 {
+		return nil, false
+	}
+	points, ok := routeGeometry(route.Shape, route.ControlPoints, start, end)
+	if !ok {
+		return nil, false
+	}
+	closest, segment, ratio, distance := utils.ClosestPointOnPolyline([2]float64{p[0], p[1]}, points)
+	if distance >= tolerance {
+		return nil, false
+	}
+	split := routeSplit{point: pq.Float64Array{closest[0], closest[1]}}
+	switch route.Shape {
+	case model.RouteShapeBezier:
+		//采样线段上的比例近似为曲线参数，吸附点取曲线上的精确坐标
+		t := (float64(segment) + ratio) / bezierSegments
+		control := toPoints(route.ControlPoints)
+		p0, p3 := points[0], points[len(points)-1]
+		head, tail := utils.SplitCubicBezier(p0, control[0], control[1], p3, t)
+		point := utils.CubicBezier(p0, control[0], control[1], p3, t)
+		split.point = pq.Float64Array{point[0], point[1]}
+		split.head = routeShape{shape: model.RouteShapeBezier, control: flattenPoints(head[:])}
+		split.tail = routeShape{shape: model.RouteShapeBezier, control: flattenPoints(tail[:])}
+	case model.RouteShapePolyline:
+		//points[0]为起点，第segment段之前的途经点归前段，其余归后段
+		control := points[1 : len(points)-1]
+		split.head = polylineShape(control[:segment])
+		split.tail = polylineShape(control[segment:])
+	default:
+		split.head = routeShape{shape: model.RouteShapeLine}
+		split.tail = routeShape{shape: model.RouteShapeLine}
+	}
+	return &split, true
+}
+
+// mergeShape 首尾相接的两段路径a、b合并后的几何，均为直线时仍为直线，否则按采样点合并为折线
+func mergeShape(a, b model.MapRoutes, rois map[int]pq.Float64Array) routeShape {
+	if isLine(a.Shape) && isLine(b.Shape) {
+		return routeShape{shape: model.RouteShapeLine}
+	}
+	points := interiorPoints(a, rois)
+	if mid := rois[a.EndNodeID]; len(mid) >= 2 {
+		points = append(points, [2]float64{mid[0], mid[1]})
+	}
+	points = append(points, interiorPoints(b, rois)...)
+	return polylineShape(points)
+}
+
+// interiorPoints 路径几何中除起终点以外的点，端点坐标缺失时退化为控制点
+func interiorPoints(route model.MapRoutes, rois map[int]pq.Float64Array) [][2]float64 {
+	points, ok := routeGeometry(route.Shape, route.ControlPoints, rois[route.StartNodeID], rois[route.EndNodeID])
+	if !ok {
+		return toPoints(route.ControlPoints)
+	}
+	return points[1 : len(points)-1]
+}
+
+func isLine(shape string) bool {
+	return shape == "" || shape == model.RouteShapeLine
+}
+
+// nodeRois 切片内全部节点(含已删除)的坐标，按节点id索引
+func (operator *ResourceOperator) nodeRois(infoID int) (map[int]pq.Float64Array, error) {
+	var nodes []model.MapRouteNodes
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	err := operator.Database.ListUnscopedEntityByFilter(model.TableNameMapRouteNodes, selector, model.QueryParams{}, &nodes)
+	if err != nil {
+		return nil, err
+	}
+	rois := make(map[int]pq.Float64Array, len(nodes))
+	for _, v := range nodes {
+		rois[v.ID] = v.Roi
+	}
+	return rois, nil
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"demo-gogo/database/model"
+	"github.com/lib/pq"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRouteGeometry(t *testing.T) {
+	start, end := []float64{0, 0}, []float64{10, 0}
+	tests := []struct {
+		name    string
+		shape   string
+		control []float64
+		start   []float64
+		end     []float64
+		want    [][2]float64
+		wantOK  bool
+	}{
+		{"直线", model.RouteShapeLine, nil, start, end, [][2]float64{{0, 0}, {10, 0}}, true},
+		{"形状为空按直线", "", []float64{5, 5}, start, end, [][2]float64{{0, 0}, {10, 0}}, true},
+		{"折线", model.RouteShapePolyline, []float64{3, 4, 6, 4}, start, end, [][2]float64{{0, 0}, {3, 4}, {6, 4}, {10, 0}}, true},
+		{"控制点数量不足的曲线按直线", model.RouteShapeBezier, []float64{3, 4}, start, end, [][2]float64{{0, 0}, {10, 0}}, true},
+		{"缺少起点坐标", model.RouteShapeLine, nil, nil, end, nil, false},
+		{"终点坐标不完整", model.RouteShapeLine, nil, start, []float64{1}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := routeGeometry(tt.shape, tt.control, tt.start, tt.end)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("routeGeometry() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRouteGeometryBezier(t *testing.T) {
+	got, ok := routeGeometry(model.RouteShapeBezier, []float64{0, 10, 10, 10}, []float64{0, 0}, []float64{10, 0})
+	if !ok || len(got) != bezierSegments+1 {
+		t.Fatalf("routeGeometry() = %d个采样点, %v, want %d个", len(got), ok, bezierSegments+1)
+	}
+	if got[0] != [2]float64{0, 0} || got[bezierSegments] != [2]float64{10, 0} {
+		t.Errorf("曲线端点 = %v, %v", got[0], got[bezierSegments])
+	}
+	//对称控制点的曲线中点位于x=5、y=7.5
+	if mid := got[bezierSegments/2]; math.Abs(mid[0]-5) > 1e-9 || math.Abs(mid[1]-7.5) > 1e-9 {
+		t.Errorf("曲线中点 = %v, want [5 7.5]", mid)
+	}
+}
+
+func TestSnapToRoute(t *testing.T) {
+	start, end := pq.Float64Array{0, 0}, pq.Float64Array{10, 0}
+	line := model.MapRoutes{Shape: model.RouteShapeLine}
+	polyline := model.MapRoutes{Shape: model.RouteShapePolyline, ControlPoints: pq.Float64Array{2, 4, 8, 4}}
+	tests := []struct {
+		name      string
+		p         []float64
+		route     model.MapRoutes
+		wantOK    bool
+		wantPoint pq.Float64Array
+		wantHead  routeShape
+		wantTail  routeShape
+	}{
+		{
+			name: "直线上", p: []float64{4, 0.5}, route: line, wantOK: true,
+			wantPoint: pq.Float64Array{4, 0},
+			wantHead:  routeShape{shape: model.RouteShapeLine},
+			wantTail:  routeShape{shape: model.RouteShapeLine},
+		},
+		{name: "超出容差", p: []float64{4, 3}, route: line},
+		{name: "坐标不完整", p: []float64{4}, route: line},
+		{
+			name: "折线中间段", p: []float64{5, 4}, route: polyline, wantOK: true,
+			wantPoint: pq.Float64Array{5, 4},
+			wantHead:  routeShape{shape: model.RouteShapePolyline, control: pq.Float64Array{2, 4}},
+			wantTail:  routeShape{shape: model.RouteShapePolyline, control: pq.Float64Array{8, 4}},
+		},
+		{
+			name: "折线第一段", p: []float64{1, 2}, route: polyline, wantOK: true,
+			wantPoint: pq.Float64Array{1, 2},
+			wantHead:  routeShape{shape: model.RouteShapeLine},
+			wantTail:  routeShape{shape: model.RouteShapePolyline, control: pq.Float64Array{2, 4, 8, 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			split, ok := snapToRoute(tt.p, tt.route, start, end, 1)
+			if ok != tt.wantOK {
+				t.Fatalf("snapToRoute() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !reflect.DeepEqual(split.point, tt.wantPoint) || !reflect.DeepEqual(split.head, tt.wantHead) || !reflect.DeepEqual(split.tail, tt.wantTail) {
+				t.Errorf("snapToRoute() = %+v, want point %v head %+v tail %+v", *split, tt.wantPoint, tt.wantHead, tt.wantTail)
+			}
+		})
+	}
+}
+
+func TestSnapToRouteBezier(t *testing.T) {
+	route := model.MapRoutes{Shape: model.RouteShapeBezier, ControlPoints: pq.Float64Array{0, 10, 10, 10}}
+	split, ok := snapToRoute([]float64{5, 7.6}, route, []float64{0, 0}, []float64{10, 0}, 1)
+	if !ok {
+		t.Fatal("snapToRoute() 未吸附到曲线")
+	}
+	if math.Abs(split.point[0]-5) > 1e-9 || math.Abs(split.point[1]-7.5) > 1e-9 {
+		t.Errorf("吸附点 = %v, want [5 7.5]", split.point)
+	}
+	//拆分后两段均为曲线，前段终点与后段起点均为吸附点
+	head, ok := routeGeometry(split.head.shape, split.head.control, []float64{0, 0}, split.point)
+	if !ok || split.head.shape != model.RouteShapeBezier || head[len(head)-1] != [2]float64{5, 7.5} {
+		t.Errorf("前段 = %+v", split.head)
+	}
+	if split.tail.shape != model.RouteShapeBezier || len(split.tail.control) != 4 {
+		t.Errorf("后段 = %+v", split.tail)
+	}
+}
+
+func TestMergeShape(t *testing.T) {
+	rois := map[int]pq.Float64Array{1: {0, 0}, 2: {5, 0}, 3: {10, 0}}
+	newRoute := func(shape string, control pq.Float64Array, start, end int) model.MapRoutes {
+		return model.MapRoutes{Shape: shape, ControlPoints: control, StartNodeID: start, EndNodeID: end}
+	}
+	tests := []struct {
+		name string
+		a, b model.MapRoutes
+		want routeShape
+	}{
+		{
+			"两段直线",
+			newRoute(model.RouteShapeLine, nil, 1, 2), newRoute("", nil, 2, 3),
+			routeShape{shape: model.RouteShapeLine},
+		},
+		{
+			"直线与折线",
+			newRoute(model.RouteShapeLine, nil, 1, 2), newRoute(model.RouteShapePolyline, pq.Float64Array{7, 2}, 2, 3),
+			routeShape{shape: model.RouteShapePolyline, control: pq.Float64Array{5, 0, 7, 2}},
+		},
+		{
+			"两段折线",
+			newRoute(model.RouteShapePolyline, pq.Float64Array{2, 1}, 1, 2), newRoute(model.RouteShapePolyline, pq.Float64Array{7, 2, 8, 1}, 2, 3),
+			routeShape{shape: model.RouteShapePolyline, control: pq.Float64Array{2, 1, 5, 0, 7, 2, 8, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeShape(tt.a, tt.b, rois); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeShape() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolylineShape(t *testing.T) {
+	tests := []struct {
+		name   string
+		points [][2]float64
+		want   routeShape
+	}{
+		{"无途经点为直线", nil, routeShape{shape: model.RouteShapeLine}},
+		{"有途经点为折线", [][2]float64{{1, 2}, {3, 4}}, routeShape{shape: model.RouteShapePolyline, control: pq.Float64Array{1, 2, 3, 4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := polylineShape(tt.points)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("polylineShape() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"github.com/lib/pq"
+	"math"
+	"sort"
+)
+
+const spatialCellSize = 64 //路径空间索引的网格边长(像素)
+
+// routeIndex 切片内路径几何的均匀网格索引，每条路径登记到其各线段外接矩形覆盖的网格，
+// 查询时只需检查点附近网格内的路径，避免节点吸附时遍历全部路径
+type routeIndex struct {
+	routes []model.MapRoutes
+	cells  map[[2]int][]int //网格坐标 -> routes下标(升序)
+}
+
+// newRouteIndex 按节点坐标rois计算路径几何并建立索引，端点坐标缺失的路径不登记
+func newRouteIndex(routes []model.MapRoutes, rois map[int]pq.Float64Array) *routeIndex {
+	index := &routeIndex{routes: routes, cells: make(map[[2]int][]int)}
+	for i, v := range routes {
+		points, ok := routeGeometry(v.Shape, v.ControlPoints, rois[v.StartNodeID], rois[v.EndNodeID])
+		if !ok {
+			continue
+		}
+		for j := 0; j+1 < len(points); j++ {
+			a, b := points[j], points[j+1]
+			index.insert(i, math.Min(a[0], b[0]), math.Min(a[1], b[1]), math.Max(a[0], b[0]), math.Max(a[1], b[1]))
+		}
+	}
+	return index
+}
+
+func (index *routeIndex) insert(i int, minX, minY, maxX, maxY float64) {
+	x0, y0 := cellOf(minX), cellOf(minY)
+	x1, y1 := cellOf(maxX), cellOf(maxY)
+	for x := x0; x <= x1; x++ {
+		for y := y0; y <= y1; y++ {
+			key := [2]int{x, y}
+			// 同一路径的相邻线段常落在同一网格
+			if ids := index.cells[key]; len(ids) > 0 && ids[len(ids)-1] == i {
+				continue
+			}
+			index.cells[key] = append(index.cells[key], i)
+		}
+	}
+}
+
+// nearby 几何可能距p不超过tolerance的路径，按加入索引的先后顺序返回
+func (index *routeIndex) nearby(p []float64, tolerance float64) []model.MapRoutes {
+	if len(p) < 2 {
+		return nil
+	}
+	seen := make(map[int]struct{})
+	var ids []int
+	for x := cellOf(p[0] - tolerance); x <= cellOf(p[0]+tolerance); x++ {
+		for y := cellOf(p[1] - tolerance); y <= cellOf(p[1]+tolerance); y++ {
+			for _, i := range index.cells[[2]int{x, y}] {
+				if _, ok := seen[i]; ok {
+					continue
+				}
+				seen[i] = struct{}{}
+				ids = append(ids, i)
+			}
+		}
+	}
+	sort.Ints(ids)
+	routes := make([]model.MapRoutes, 0, len(ids))
+	for _, i := range ids {
+		routes = append(routes, index.routes[i])
+	}
+	return routes
+}
+
+// pairs 登记到同一网格的路径下标对(i<j)，按下标升序返回，作为相交检测的候选
+func (index *routeIndex) pairs() [][2]int {
+	seen := make(map[[2]int]struct{})
+	pairs := make([][2]int, 0)
+	for _, ids := range index.cells {
+		for a := 0; a < len(ids); a++ {
+			for b := a + 1; b < len(ids); b++ {
+				key := [2]int{ids[a], ids[b]}
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+				pairs = append(pairs, key)
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	return pairs
+}
+
+func cellOf(v float64) int {
+	return int(math.Floor(v / spatialCellSize))
+}
+
+// snapTolerance 切片的节点吸附距离(像素)，未设置时使用默认配置
+func snapTolerance(mapInfo model.MapInfo) float64 {
+	if mapInfo.SnapTolerance > 0 {
+		return mapInfo.SnapTolerance
+	}
+	return config.Conf.Map.SnapTolerance
+}
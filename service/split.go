@@ -0,0 +1,141 @@
+package service
+
+import (
+	"demo-gogo/database/model"
+	"github.com/lib/pq"
+	log "github.com/wonderivan/logger"
+	"time"
+)
+
+// routeSplitter 节点落在已有路径上时吸附到路径并按吸附点拆分路径，新增节点与批量保存路径共用。
+// 被拆分的路径由前后两段替代，两段分别判重，与已有路径起终点相同的不重复创建
+type routeSplitter struct {
+	infoID    int
+	tolerance float64
+	index     *routeIndex
+	nodes     map[int]model.MapRouteNodes
+	pairs     map[[2]int]struct{} //已有及待创建路径的起终点
+	removed   map[[2]int]struct{} //被拆分路径的起终点
+	snapped   []int               //坐标被吸附的节点id，按吸附顺序
+	splitIDs  []int               //被拆分的已保存路径id
+	created   []model.MapRoutes   //拆分出的新路径
+}
+
+// newRouteSplitter 以切片当前的路径与节点建立拆分器，routes可包含尚未保存的路径
+func newRouteSplitter(mapInfo model.MapInfo, routes []model.MapRoutes, nodes []model.MapRouteNodes) *routeSplitter {
+	s := &routeSplitter{
+		infoID:    mapInfo.ID,
+		tolerance: snapTolerance(mapInfo),
+		nodes:     make(map[int]model.MapRouteNodes, len(nodes)),
+		pairs:     make(map[[2]int]struct{}, len(routes)),
+		removed:   make(map[[2]int]struct{}),
+	}
+	rois := make(map[int]pq.Float64Array, len(nodes))
+	for _, v := range nodes {
+		s.nodes[v.ID] = v
+		rois[v.ID] = v.Roi
+	}
+	for _, v := range routes {
+		s.pairs[[2]int{v.StartNodeID, v.EndNodeID}] = struct{}{}
+	}
+	s.index = newRouteIndex(routes, rois)
+	return s
+}
+
+// snap 将节点吸附到容差范围内的路径上并拆分这些路径，返回吸附后的节点
+func (s *routeSplitter) snap(node model.MapRouteNodes) model.MapRouteNodes {
+	//仅检查空间索引中靠近节点的路径
+	for _, v := range s.index.nearby(node.Roi, s.tolerance) {
+		key := [2]int{v.StartNodeID, v.EndNodeID}
+		if _, ok := s.removed[key]; ok {
+			continue
+		}
+		//拿到路径的起始点、末尾点坐标
+		head, okH := s.nodes[v.StartNodeID]
+		tail, okE := s.nodes[v.EndNodeID]
+		if !okH || !okE || head.Roi == nil || tail.Roi == nil {
+			continue
+		}
+		//略过首位相同点
+		if head.ID == node.ID || tail.ID == node.ID {
+			continue
+		}
+		split, ok := snapToRoute(node.Roi, v, head.Roi, tail.Roi, s.tolerance)
+		if !ok {
+			continue
+		}
+		if node.Roi[0] != split.point[0] || node.Roi[1] != split.point[1] {
+			s.markSnapped(node.ID)
+		}
+		node.Roi = split.point
+		s.nodes[node.ID] = node
+		s.removed[key] = struct{}{}
+		if v.ID > 0 {
+			s.splitIDs = append(s.splitIDs, v.ID)
+		}
+		//在路径几何上，按吸附点拆分，原路径由拆分后的两段替代
+		routeA := newRoute(s.infoID, head, node, v)
+		routeB := newRoute(s.infoID, node, tail, v)
+		routeA.Shape, routeA.ControlPoints = split.head.shape, split.head.control
+		routeB.Shape, routeB.ControlPoints = split.tail.shape, split.tail.control
+		for _, route := range []model.MapRoutes{routeA, routeB} {
+			pair := [2]int{route.StartNodeID, route.EndNodeID}
+			if _, exist := s.pairs[pair]; exist {
+				continue
+			}
+			s.pairs[pair] = struct{}{}
+			s.created = append(s.created, route)
+		}
+	}
+	return node
+}
+
+func (s *routeSplitter) markSnapped(id int) {
+	for _, v := range s.snapped {
+		if v == id {
+			return
+		}
+	}
+	s.snapped = append(s.snapped, id)
+}
+
+// isSplit 路径是否已被拆分，用于剔除尚未保存的待创建路径
+func (s *routeSplitter) isSplit(route model.MapRoutes) bool {
+	_, ok := s.removed[[2]int{route.StartNodeID, route.EndNodeID}]
+	return ok
+}
+
+// save 保存吸附后的节点坐标，并以同一删除时间逻辑删除被拆分的已保存路径；拆分出的新路径由调用方随其它路径一并创建
+func (s *routeSplitter) save(operator *ResourceOperator, now time.Time) error {
+	for _, id := range s.snapped {
+		node := s.nodes[id]
+		err := operator.Database.SaveEntity(model.TableNameMapRouteNodes, &node)
+		if err != nil {
+			log.Error("地图路径节点更新失败,err:[%v]", err)
+			return err
+		}
+	}
+	if len(s.splitIDs) == 0 {
+		return nil
+	}
+	selector := map[string]interface{}{model.FieldInfoId: s.infoID}
+	queryParams := model.QueryParams{}
+	queryParams.InQueries = append(queryParams.InQueries, &model.InQuery{Field: model.FieldID, Values: s.splitIDs})
+	err := softDeleteEntity(operator.Database, model.TableNameMapRoutes, selector, queryParams, now)
+	if err != nil {
+		log.Error("被拆分的地图路径删除失败,err:[%v]", err)
+		return err
+	}
+	return nil
+}
+
+// removeSplitRoutes 剔除已被拆分的路径
+func removeSplitRoutes(routes []model.MapRoutes, s *routeSplitter) []model.MapRoutes {
+	kept := routes[:0]
+	for _, v := range routes {
+		if !s.isSplit(v) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"bytes"
+	"demo-gogo/api/apimodel"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils"
+	"demo-gogo/utils/storage"
+	"errors"
+	"fmt"
+	"github.com/rs/xid"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	folderTiles      = "tiles"
+	tileProgressStep = 64 //每生成若干张瓦片更新一次进度
+	// tileURLPattern 瓦片接口地址，与httpserver中注册的路由一致
+	tileURLPattern = "%s/map/map_info/%d/tiles/{z}/{x}/{y}.png?v=%s"
+)
+
+// tilePyramid 地图切片某一版本的瓦片金字塔。
+// 最大层级为原图分辨率，每降一级宽高减半(向上取整)，第0层整图不超过一张瓦片；瓦片按左上角为原点编号
+type tilePyramid struct {
+	infoID  int
+	version string
+	maxZoom int
+	width   int
+	height  int
+}
+
+func newTilePyramid(infoID int, version string, width, height int) tilePyramid {
+	maxZoom := 0
+	for width > apimodel.MapTileSize<<maxZoom || height > apimodel.MapTileSize<<maxZoom {
+		maxZoom++
+	}
+	return tilePyramid{infoID: infoID, version: version, maxZoom: maxZoom, width: width, height: height}
+}
+
+// tilesOf 地图切片当前可用的瓦片金字塔
+func tilesOf(mapInfo model.MapInfo) tilePyramid {
+	return tilePyramid{
+		infoID:  mapInfo.ID,
+		version: mapInfo.TileVersion,
+		maxZoom: mapInfo.TileMaxZoom,
+		width:   mapInfo.TileWidth,
+		height:  mapInfo.TileHeight,
+	}
+}
+
+// levelSize 第z层图片的宽高
+func (p tilePyramid) levelSize(z int) (int, int) {
+	w, h := p.width, p.height
+	for i := p.maxZoom; i > z; i-- {
+		w, h = (w+1)/2, (h+1)/2
+	}
+	return w, h
+}
+
+// tileCount 第z层瓦片的列数与行数
+func (p tilePyramid) tileCount(z int) (int, int) {
+	w, h := p.levelSize(z)
+	return (w + apimodel.MapTileSize - 1) / apimodel.MapTileSize, (h + apimodel.MapTileSize - 1) / apimodel.MapTileSize
+}
+
+func (p tilePyramid) total() int64 {
+	var total int64
+	for z := 0; z <= p.maxZoom; z++ {
+		cols, rows := p.tileCount(z)
+		total += int64(cols * rows)
+	}
+	return total
+}
+
+func (p tilePyramid) contains(z, x, y int) bool {
+	if z < 0 || z > p.maxZoom || x < 0 || y < 0 {
+		return false
+	}
+	cols, rows := p.tileCount(z)
+	return x < cols && y < rows
+}
+
+func (p tilePyramid) key(z, x, y int) string {
+	return path.Join(folderTiles, strconv.Itoa(p.infoID), p.version,
+		strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(y)+".png")
+}
+
+// remove 删除该版本的全部瓦片，瓦片数量较多，调用方通常在后台执行
+func (p tilePyramid) remove() {
+	if p.version == "" || storage.Default == nil {
+		return
+	}
+	for z := 0; z <= p.maxZoom; z++ {
+		cols, rows := p.tileCount(z)
+		for x := 0; x < cols; x++ {
+			for y := 0; y < rows; y++ {
+				if err := storage.Default.Delete(p.key(z, x, y)); err != nil {
+					log.Warn("地图瓦片[%s]删除失败. err:[%v]", p.key(z, x, y), err)
+				}
+			}
+		}
+	}
+	log.Info("地图切片[%d]瓦片版本[%s]已删除", p.infoID, p.version)
+}
+
+// resetMapTiles 地图图片变更后原瓦片作废，清空mapInfo的瓦片信息并返回旧瓦片，调用方在数据保存成功后删除
+func resetMapTiles(mapInfo *model.MapInfo) tilePyramid {
+	stale := tilesOf(*mapInfo)
+	mapInfo.TileVersion = ""
+	mapInfo.TileMaxZoom, mapInfo.TileWidth, mapInfo.TileHeight = 0, 0, 0
+	return stale
+}
+
+// GenerateMapTiles 启动后台任务将地图图片切分为瓦片金字塔，同一切片同时只允许一个任务，进度通过GetMapTiles查询
+func (operator *ResourceOperator) GenerateMapTiles(req *apimodel.MapTilesRequest) (*apimodel.MapTilesInfo, error) {
+	mapInfo, err := operator.findMapInfo(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mapInfo.MapURL == "" {
+		return nil, errors.New(errcode.ErrorMsgMapImageEmpty)
+	}
+	if storage.Default == nil {
+		return nil, errors.New("文件存储未初始化")
+	}
+	version := xid.New().String()
+	params := map[string]interface{}{"version": version, "map_url": mapInfo.MapURL}
+	job, err := startMapJob(model.GetMapTilesKey(req.ID), params, errcode.ErrorMsgMapTilesRunning)
+	if err != nil {
+		return nil, err
+	}
+	go operator.buildMapTiles(job, mapInfo, version)
+	log.Info("地图切片[%d]瓦片生成任务启动,版本[%s]", req.ID, version)
+	return operator.GetMapTiles(req)
+}
+
+// GetMapTiles 查询地图切片当前可用的瓦片及最近一次生成任务的进度
+func (operator *ResourceOperator) GetMapTiles(req *apimodel.MapTilesRequest) (*apimodel.MapTilesInfo, error) {
+	mapInfo, err := operator.findMapInfo(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	info := apimodel.MapTilesInfo{
+		InfoID:   mapInfo.ID,
+		Version:  mapInfo.TileVersion,
+		MaxZoom:  mapInfo.TileMaxZoom,
+		Width:    mapInfo.TileWidth,
+		Height:   mapInfo.TileHeight,
+		TileSize: apimodel.MapTileSize,
+	}
+	if info.Version != "" {
+		info.TileURL = fmt.Sprintf(tileURLPattern, strings.TrimSuffix(config.Conf.APP.ContextPath, "/"), mapInfo.ID, info.Version)
+	}
+	info.Progress = readMapJobProgress(model.GetMapTilesKey(req.ID))
+	return &info, nil
+}
+
+// GetMapTile 读取当前版本的单张瓦片
+func (operator *ResourceOperator) GetMapTile(req *apimodel.MapTileRequest) (*apimodel.MapTileData, error) {
+	mapInfo, err := operator.findMapInfo(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	y, err := req.TileY()
+	if err != nil {
+		return nil, fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "y")
+	}
+	pyramid := tilesOf(mapInfo)
+	if pyramid.version == "" || !pyramid.contains(req.Z, req.X, y) || storage.Default == nil {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图瓦片")
+	}
+	reader, err := storage.Default.Open(pyramid.key(req.Z, req.X, y))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图瓦片")
+		}
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &apimodel.MapTileData{Data: data, Version: pyramid.version}, nil
+}
+
+func (operator *ResourceOperator) findMapInfo(infoID int) (model.MapInfo, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, infoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mapInfo, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return mapInfo, err
+	}
+	return mapInfo, nil
+}
+
+// buildMapTiles 瓦片生成任务，全部瓦片写入后切换为新版本并删除旧版本瓦片，失败时删除已写入的瓦片
+func (operator *ResourceOperator) buildMapTiles(job *mapJob, mapInfo model.MapInfo, version string) {
+	job.progress.Message = "正在生成地图瓦片"
+	pyramid := tilePyramid{infoID: mapInfo.ID}
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil {
+			log.Error("地图切片[%d]瓦片生成失败,版本[%s] err:[%v]", mapInfo.ID, version, err)
+			pyramid.remove()
+		}
+		job.finish(err, "地图瓦片生成完成")
+	}()
+	img, err := decodeMapImage(mapInfo.MapURL)
+	if err != nil {
+		return
+	}
+	size := img.Bounds().Size()
+	pyramid = newTilePyramid(mapInfo.ID, version, size.X, size.Y)
+	job.progress.Total = pyramid.total()
+	job.update()
+	level := img
+	for z := pyramid.maxZoom; z >= 0; z-- {
+		if z < pyramid.maxZoom {
+			w, h := pyramid.levelSize(z)
+			level = utils.ResizeArea(level, w, h)
+		}
+		cols, rows := pyramid.tileCount(z)
+		for x := 0; x < cols; x++ {
+			for y := 0; y < rows; y++ {
+				var data []byte
+				data, err = encodeTile(level, x, y)
+				if err != nil {
+					return
+				}
+				err = storage.Default.Put(pyramid.key(z, x, y), bytes.NewReader(data), int64(len(data)))
+				if err != nil {
+					return
+				}
+				job.progress.Processed++
+				if job.progress.Processed%tileProgressStep == 0 {
+					job.update()
+				}
+			}
+		}
+	}
+	stale, err := operator.activateMapTiles(mapInfo, pyramid)
+	if err != nil {
+		return
+	}
+	stale.remove()
+	log.Info("地图切片[%d]瓦片生成完成,版本[%s] 层级[0-%d] 瓦片数[%d]", mapInfo.ID, version, pyramid.maxZoom, job.progress.Total)
+}
+
+// activateMapTiles 将切片的瓦片切换为新版本，生成期间地图图片已变更时新瓦片作废，返回被替换的旧瓦片
+func (operator *ResourceOperator) activateMapTiles(mapInfo model.MapInfo, pyramid tilePyramid) (tilePyramid, error) {
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("activateMapTiles TransactionBegin Error.err[%v]", err)
+		return tilePyramid{}, err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	var current model.MapInfo
+	err = tx.Database.GetEntityForUpdate(model.TableNameMapInfo, mapInfo.ID, &current)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tilePyramid{}, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return tilePyramid{}, err
+	}
+	if current.MapURL != mapInfo.MapURL {
+		return tilePyramid{}, errors.New("生成期间地图图片已变更,瓦片作废")
+	}
+	selector := make(map[string]interface{})
+	selector[model.FieldID] = mapInfo.ID
+	updater := map[string]interface{}{
+		model.FieldTileVersion: pyramid.version,
+		model.FieldTileMaxZoom: pyramid.maxZoom,
+		model.FieldTileWidth:   pyramid.width,
+		model.FieldTileHeight:  pyramid.height,
+	}
+	err = tx.Database.UpdateEntityByFilter(model.TableNameMapInfo, selector, model.QueryParams{}, &updater)
+	if err != nil {
+		log.Error("地图切片瓦片版本更新失败. err:[%v]", err)
+		return tilePyramid{}, err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("activateMapTiles TransactionCommit Error.err[%v]", err)
+		return tilePyramid{}, err
+	}
+	return tilesOf(current), nil
+}
+
+// encodeTile 截取第x列第y行瓦片并编码为png，超出图片范围的部分透明
+func encodeTile(level image.Image, x, y int) ([]byte, error) {
+	bounds := level.Bounds()
+	size := apimodel.MapTileSize
+	rect := image.Rect(x*size, y*size, (x+1)*size, (y+1)*size).Add(bounds.Min)
+	var tile image.Image
+	if sub, ok := level.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok && rect.In(bounds) {
+		tile = sub.SubImage(rect)
+	} else {
+		rgba := image.NewRGBA(image.Rect(0, 0, size, size))
+		visible := rect.Intersect(bounds)
+		draw.Draw(rgba, visible.Sub(rect.Min), level, visible.Min, draw.Src)
+		tile = rgba
+	}
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(&buf, tile); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"demo-gogo/database/model"
+	"testing"
+)
+
+func TestNewTilePyramid(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		wantMaxZoom   int
+	}{
+		{"不超过一张瓦片", 256, 256, 0},
+		{"宽度超出一个像素", 257, 10, 1},
+		{"高度决定层级", 100, 1025, 3},
+		{"宽高均超出", 1000, 300, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTilePyramid(1, "v", tt.width, tt.height); got.maxZoom != tt.wantMaxZoom {
+				t.Errorf("newTilePyramid().maxZoom = %v, want %v", got.maxZoom, tt.wantMaxZoom)
+			}
+		})
+	}
+}
+
+func TestTilePyramidLevels(t *testing.T) {
+	p := newTilePyramid(5, "v1", 1000, 301)
+	tests := []struct {
+		z                  int
+		wantW, wantH       int
+		wantCols, wantRows int
+	}{
+		{2, 1000, 301, 4, 2},
+		{1, 500, 151, 2, 1},
+		{0, 250, 76, 1, 1},
+	}
+	for _, tt := range tests {
+		w, h := p.levelSize(tt.z)
+		cols, rows := p.tileCount(tt.z)
+		if w != tt.wantW || h != tt.wantH || cols != tt.wantCols || rows != tt.wantRows {
+			t.Errorf("第%d层 = %dx%d(%dx%d张), want %dx%d(%dx%d张)", tt.z, w, h, cols, rows, tt.wantW, tt.wantH, tt.wantCols, tt.wantRows)
+		}
+	}
+	if got := p.total(); got != 11 {
+		t.Errorf("total() = %v, want 11", got)
+	}
+}
+
+func TestTilePyramidContains(t *testing.T) {
+	p := newTilePyramid(5, "v1", 1000, 301)
+	tests := []struct {
+		name    string
+		z, x, y int
+		want    bool
+	}{
+		{"第0层唯一瓦片", 0, 0, 0, true},
+		{"最大层右下角", 2, 3, 1, true},
+		{"列越界", 2, 4, 0, false},
+		{"行越界", 1, 0, 1, false},
+		{"层级越界", 3, 0, 0, false},
+		{"负层级", -1, 0, 0, false},
+		{"负坐标", 2, -1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.contains(tt.z, tt.x, tt.y); got != tt.want {
+				t.Errorf("contains(%d, %d, %d) = %v, want %v", tt.z, tt.x, tt.y, got, tt.want)
+			}
+		})
+	}
+	if got, want := p.key(2, 3, 1), "tiles/5/v1/2/3/1.png"; got != want {
+		t.Errorf("key() = %v, want %v", got, want)
+	}
+}
+
+func TestResetMapTiles(t *testing.T) {
+	mapInfo := model.MapInfo{TileVersion: "v1", TileMaxZoom: 2, TileWidth: 1000, TileHeight: 301}
+	mapInfo.ID = 5
+	stale := resetMapTiles(&mapInfo)
+	if want := newTilePyramid(5, "v1", 1000, 301); stale != want {
+		t.Errorf("resetMapTiles() = %+v, want %+v", stale, want)
+	}
+	if mapInfo.TileVersion != "" || mapInfo.TileMaxZoom != 0 || mapInfo.TileWidth != 0 || mapInfo.TileHeight != 0 {
+		t.Errorf("resetMapTiles()后瓦片信息未清空: %+v", mapInfo)
+	}
+}
@@ -0,0 +1,466 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"errors"
+	"fmt"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"time"
+)
+
+// trashTables 回收站数据类型对应的表
+var trashTables = map[string]string{
+	apimodel.TrashTypeMap:     model.TableNameMap,
+	apimodel.TrashTypeMapInfo: model.TableNameMapInfo,
+	apimodel.TrashTypeNode:    model.TableNameMapRouteNodes,
+	apimodel.TrashTypeRoute:   model.TableNameMapRoutes,
+}
+
+// deletedQuery 仅查询已逻辑删除的数据
+func deletedQuery() model.QueryParams {
+	queryParams := model.QueryParams{}
+	queryParams.NotInQueries = append(queryParams.NotInQueries, map[string]interface{}{model.FieldDeletedTime: nil})
+	return queryParams
+}
+
+// ListTrash 按数据类型查询回收站
+func (operator *ResourceOperator) ListTrash(req *apimodel.TrashRequest) (*apimodel.TrashResponse, error) {
+	resp := apimodel.TrashResponse{List: make([]apimodel.TrashInfo, 0)}
+	table := trashTables[req.Type]
+	selector := make(map[string]interface{})
+	if req.ID > 0 {
+		selector[model.FieldID] = req.ID
+	}
+	if req.MapID > 0 && req.Type == apimodel.TrashTypeMapInfo {
+		selector[model.FieldMapId] = req.MapID
+	}
+	if req.InfoID > 0 && (req.Type == apimodel.TrashTypeNode || req.Type == apimodel.TrashTypeRoute) {
+		selector[model.FieldInfoId] = req.InfoID
+	}
+	queryParams := deletedQuery()
+	var count int64
+	err := operator.Database.CountAllEntityByFilter(table, selector, queryParams, &count)
+	if err != nil {
+		return nil, err
+	}
+	resp.TotalSize = int(count)
+	if count == 0 {
+		return &resp, nil
+	}
+	queryParams.Orders = append(queryParams.Orders, model.Order{Field: req.OrderBy, Direction: req.Order})
+	if req.PageSize > 0 {
+		queryParams.Limit = &req.PageSize
+		offset := (req.PageNo - 1) * req.PageSize
+		queryParams.Offset = &offset
+	}
+	switch req.Type {
+	case apimodel.TrashTypeMap:
+		var list []model.Map
+		err = operator.Database.ListUnscopedEntityByFilter(table, selector, queryParams, &list)
+		for _, v := range list {
+			info := apimodel.TrashInfo{}
+			info.Load(req.Type, v.Model, v.Name, 0, 0)
+			resp.List = append(resp.List, info)
+		}
+	case apimodel.TrashTypeMapInfo:
+		var list []model.MapInfo
+		err = operator.Database.ListUnscopedEntityByFilter(table, selector, queryParams, &list)
+		for _, v := range list {
+			info := apimodel.TrashInfo{}
+			info.Load(req.Type, v.Model, v.Name, v.MapID, 0)
+			resp.List = append(resp.List, info)
+		}
+	case apimodel.TrashTypeNode:
+		var list []model.MapRouteNodes
+		err = operator.Database.ListUnscopedEntityByFilter(table, selector, queryParams, &list)
+		for _, v := range list {
+			info := apimodel.TrashInfo{}
+			info.Load(req.Type, v.Model, v.NodeName, 0, v.InfoID)
+			resp.List = append(resp.List, info)
+		}
+	case apimodel.TrashTypeRoute:
+		var list []model.MapRoutes
+		err = operator.Database.ListUnscopedEntityByFilter(table, selector, queryParams, &list)
+		for _, v := range list {
+			info := apimodel.TrashInfo{}
+			info.Load(req.Type, v.Model, v.RoutesName, 0, v.InfoID)
+			resp.List = append(resp.List, info)
+		}
+	}
+	if err != nil {
+		log.Error("回收站数据查询失败. err:[%v]", err)
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RestoreTrash 恢复回收站数据，与其同一次删除的下级数据一并恢复
+func (operator *ResourceOperator) RestoreTrash(req *apimodel.TrashRequest) (*apimodel.CascadeCount, error) {
+	var resp apimodel.CascadeCount
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("RestoreTrash TransactionBegin Error.err[%v]", err)
+		return nil, err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	switch req.Type {
+	case apimodel.TrashTypeMap:
+		err = tx.restoreMap(req.ID, &resp)
+	case apimodel.TrashTypeMapInfo:
+		err = tx.restoreMapInfo(req.ID, &resp)
+	case apimodel.TrashTypeNode:
+		var node model.MapRouteNodes
+		err = tx.getTrashEntity(model.TableNameMapRouteNodes, req.ID, &node, &node.Model, "节点")
+		if err == nil {
+			err = tx.restoreSliceItems(node.InfoID, node.DeletedAt.Time, req.EditContext, &resp)
+		}
+	case apimodel.TrashTypeRoute:
+		var route model.MapRoutes
+		err = tx.getTrashEntity(model.TableNameMapRoutes, req.ID, &route, &route.Model, "路径")
+		if err == nil {
+			err = tx.restoreSliceItems(route.InfoID, route.DeletedAt.Time, req.EditContext, &resp)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("RestoreTrash TransactionCommit Error.err[%v]", err)
+		return nil, err
+	}
+	log.Info("回收站数据[%s:%d]恢复完成,%+v", req.Type, req.ID, resp)
+	return &resp, nil
+}
+
+// getTrashEntity 查询回收站中的数据，base为entity内嵌的Model
+func (operator *ResourceOperator) getTrashEntity(table string, id int, entity interface{}, base *model.Model, name string) error {
+	selector := make(map[string]interface{})
+	selector[model.FieldID] = id
+	err := operator.Database.ListUnscopedEntityByFilter(table, selector, deletedQuery(), entity)
+	if err != nil {
+		return err
+	}
+	if base.ID == 0 {
+		return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "回收站中的"+name)
+	}
+	return nil
+}
+
+// restoreMap 恢复地图及同一次删除的切片、节点、路径与连接器
+func (operator *ResourceOperator) restoreMap(id int, resp *apimodel.CascadeCount) error {
+	var mapDB model.Map
+	err := operator.getTrashEntity(model.TableNameMap, id, &mapDB, &mapDB.Model, "地图")
+	if err != nil {
+		return err
+	}
+	deletedAt := mapDB.DeletedAt.Time
+	err = operator.assertNameFree(model.TableNameMap, model.EmptyFilter, []string{mapDB.Name}, "地图")
+	if err != nil {
+		return err
+	}
+	var infos []model.MapInfo
+	selector := make(map[string]interface{})
+	selector[model.FieldMapId] = id
+	selector[model.FieldDeletedTime] = deletedAt
+	err = operator.Database.ListUnscopedEntityByFilter(model.TableNameMapInfo, selector, model.QueryParams{}, &infos)
+	if err != nil {
+		return err
+	}
+	infoIDs := make([]int, 0, len(infos))
+	names := make([]string, 0, len(infos))
+	for _, v := range infos {
+		infoIDs = append(infoIDs, v.ID)
+		names = append(names, v.Name)
+	}
+	err = operator.assertNameFree(model.TableNameMapInfo, model.EmptyFilter, names, "地图切片")
+	if err != nil {
+		return err
+	}
+
+	selector = make(map[string]interface{})
+	selector[model.FieldID] = id
+	err = operator.restoreBatch(model.TableNameMap, selector, model.QueryParams{}, deletedAt, &resp.Maps)
+	if err != nil {
+		return err
+	}
+	selector = make(map[string]interface{})
+	selector[model.FieldMapId] = id
+	err = operator.restoreBatch(model.TableNameMapInfo, selector, model.QueryParams{}, deletedAt, &resp.MapInfos)
+	if err != nil {
+		return err
+	}
+	err = operator.restoreBatch(model.TableNameMapConnector, selector, model.QueryParams{}, deletedAt, &resp.Connectors)
+	if err != nil {
+		return err
+	}
+	if len(infoIDs) == 0 {
+		return nil
+	}
+	queryParams := model.QueryParams{}
+	queryParams.InQueries = append(queryParams.InQueries, &model.InQuery{Field: model.FieldInfoId, Values: infoIDs})
+	err = operator.restoreBatch(model.TableNameMapRouteNodes, model.EmptyFilter, queryParams, deletedAt, &resp.Nodes)
+	if err != nil {
+		return err
+	}
+	err = operator.restoreBatch(model.TableNameMapZone, model.EmptyFilter, queryParams, deletedAt, &resp.Zones)
+	if err != nil {
+		return err
+	}
+	return operator.restoreBatch(model.TableNameMapRoutes, model.EmptyFilter, queryParams, deletedAt, &resp.Routes)
+}
+
+// restoreMapInfo 恢复地图切片及同一次删除的节点、路径、区域，以及另一端切片仍存在的连接器
+func (operator *ResourceOperator) restoreMapInfo(id int, resp *apimodel.CascadeCount) error {
+	var mapInfo model.MapInfo
+	err := operator.getTrashEntity(model.TableNameMapInfo, id, &mapInfo, &mapInfo.Model, "地图切片")
+	if err != nil {
+		return err
+	}
+	deletedAt := mapInfo.DeletedAt.Time
+	var mapDB model.Map
+	err = operator.Database.GetEntityByID(model.TableNameMap, mapInfo.MapID, &mapDB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(errcode.ErrorMsgTrashParent)
+		}
+		return err
+	}
+	err = operator.assertNameFree(model.TableNameMapInfo, model.EmptyFilter, []string{mapInfo.Name}, "地图切片")
+	if err != nil {
+		return err
+	}
+
+	// 连接器另一端切片已删除时不恢复，避免产生悬空连接
+	var connectorIDs []int
+	for _, field := range []string{model.FieldFromInfoID, model.FieldToInfoID} {
+		var connectors []model.MapConnector
+		selector := make(map[string]interface{})
+		selector[field] = id
+		selector[model.FieldDeletedTime] = deletedAt
+		err = operator.Database.ListUnscopedEntityByFilter(model.TableNameMapConnector, selector, model.QueryParams{}, &connectors)
+		if err != nil {
+			return err
+		}
+		for _, v := range connectors {
+			other := v.ToInfoID
+			if field == model.FieldToInfoID {
+				other = v.FromInfoID
+			}
+			if other != id {
+				var count int64
+				selector = make(map[string]interface{})
+				selector[model.FieldID] = other
+				err = operator.Database.CountEntityByFilter(model.TableNameMapInfo, selector, model.QueryParams{}, &count)
+				if err != nil {
+					return err
+				}
+				if count == 0 {
+					continue
+				}
+			}
+			connectorIDs = append(connectorIDs, v.ID)
+		}
+	}
+
+	selector := make(map[string]interface{})
+	selector[model.FieldID] = id
+	err = operator.restoreBatch(model.TableNameMapInfo, selector, model.QueryParams{}, deletedAt, &resp.MapInfos)
+	if err != nil {
+		return err
+	}
+	selector = make(map[string]interface{})
+	selector[model.FieldInfoId] = id
+	err = operator.restoreBatch(model.TableNameMapRouteNodes, selector, model.QueryParams{}, deletedAt, &resp.Nodes)
+	if err != nil {
+		return err
+	}
+	err = operator.restoreBatch(model.TableNameMapRoutes, selector, model.QueryParams{}, deletedAt, &resp.Routes)
+	if err != nil {
+		return err
+	}
+	err = operator.restoreBatch(model.TableNameMapZone, selector, model.QueryParams{}, deletedAt, &resp.Zones)
+	if err != nil {
+		return err
+	}
+	if len(connectorIDs) == 0 {
+		return nil
+	}
+	queryParams := model.QueryParams{}
+	queryParams.InQueries = append(queryParams.InQueries, &model.InQuery{Field: model.FieldID, Values: connectorIDs})
+	return operator.restoreBatch(model.TableNameMapConnector, model.EmptyFilter, queryParams, deletedAt, &resp.Connectors)
+}
+
+// restoreSliceItems 恢复切片内同一次删除的节点与路径，并移除删除时合并生成的路径
+func (operator *ResourceOperator) restoreSliceItems(infoID int, deletedAt time.Time, ctx apimodel.EditContext, resp *apimodel.CascadeCount) error {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, infoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(errcode.ErrorMsgTrashParent)
+		}
+		return err
+	}
+	_, err = operator.lockSliceForEdit(infoID, ctx)
+	if err != nil {
+		return err
+	}
+	before, err := operator.loadSnapshot(infoID)
+	if err != nil {
+		return err
+	}
+	var nodes []model.MapRouteNodes
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	selector[model.FieldDeletedTime] = deletedAt
+	err = operator.Database.ListUnscopedEntityByFilter(model.TableNameMapRouteNodes, selector, model.QueryParams{}, &nodes)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(nodes))
+	for _, v := range nodes {
+		names = append(names, v.NodeName)
+	}
+	selector = make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	err = operator.assertNameFree(model.TableNameMapRouteNodes, selector, names, "节点")
+	if err != nil {
+		return err
+	}
+
+	selector[model.FieldCreatedTime] = deletedAt
+	selector[model.FieldDeletedTime] = nil
+	err = operator.Database.DeleteUnscopedEntityByFilter(model.TableNameMapRoutes, selector, model.QueryParams{}, &model.MapRoutes{})
+	if err != nil {
+		log.Error("移除合并生成的路径失败. err:[%v]", err)
+		return err
+	}
+	selector = make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	err = operator.restoreBatch(model.TableNameMapRouteNodes, selector, model.QueryParams{}, deletedAt, &resp.Nodes)
+	if err != nil {
+		return err
+	}
+	err = operator.restoreBatch(model.TableNameMapRoutes, selector, model.QueryParams{}, deletedAt, &resp.Routes)
+	if err != nil {
+		return err
+	}
+	return operator.recordRevision(infoID, before, ctx, RevisionOpRestore)
+}
+
+// restoreBatch 恢复删除时间为deletedAt的数据并累加恢复条数
+func (operator *ResourceOperator) restoreBatch(table string, filter map[string]interface{}, params model.QueryParams, deletedAt time.Time, count *int64) error {
+	selector := make(map[string]interface{})
+	for k, v := range filter {
+		selector[k] = v
+	}
+	selector[model.FieldDeletedTime] = deletedAt
+	var n int64
+	err := operator.Database.CountAllEntityByFilter(table, selector, params, &n)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	updater := map[string]interface{}{model.FieldDeletedTime: nil}
+	err = operator.Database.UpdateEntityByFilter(table, selector, params, &updater)
+	if err != nil {
+		log.Error("恢复[%s]数据失败. err:[%v]", table, err)
+		return err
+	}
+	*count += n
+	return nil
+}
+
+// assertNameFree 待恢复数据的名称已被现有数据占用时返回错误
+func (operator *ResourceOperator) assertNameFree(table string, filter map[string]interface{}, names []string, name string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var count int64
+	queryParams := model.QueryParams{}
+	queryParams.InQueries = append(queryParams.InQueries, &model.InQuery{Field: model.FieldName, Values: names})
+	err := operator.Database.CountEntityByFilter(table, filter, queryParams, &count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf(errcode.ErrorMsgSuffixParamExists, "同名"+name)
+	}
+	return nil
+}
+
+// purgeTarget 回收站清理的数据表，mode用于触发硬删除
+type purgeTarget struct {
+	table string
+	mode  interface{}
+	count *int64
+}
+
+// PurgeTrash 永久删除超过保留天数的回收站数据
+func (operator *ResourceOperator) PurgeTrash(req *apimodel.PurgeTrashRequest) (*apimodel.PurgeTrashResponse, error) {
+	days := config.Conf.Trash.RetentionDays
+	if req.RetentionDays != nil {
+		days = *req.RetentionDays
+	}
+	before := time.Now().AddDate(0, 0, -days)
+	resp := apimodel.PurgeTrashResponse{DryRun: req.DryRun, DeletedBefore: model.LocalTime(before).String()}
+	targets := []purgeTarget{
+		{table: model.TableNameMapRoutes, mode: &model.MapRoutes{}, count: &resp.Routes},
+		{table: model.TableNameMapRouteNodes, mode: &model.MapRouteNodes{}, count: &resp.Nodes},
+		{table: model.TableNameMapConnector, mode: &model.MapConnector{}, count: &resp.Connectors},
+		{table: model.TableNameMapZone, mode: &model.MapZone{}, count: &resp.Zones},
+		{table: model.TableNameMapInfo, mode: &model.MapInfo{}, count: &resp.MapInfos},
+		{table: model.TableNameMap, mode: &model.Map{}, count: &resp.Maps},
+	}
+	queryParams := model.QueryParams{}
+	queryParams.CompareQueries = append(queryParams.CompareQueries, &model.CompareQuery{
+		Field:              model.FieldDeletedTime,
+		ComparisonOperator: model.LT,
+		Value:              before,
+	})
+	for _, target := range targets {
+		err := operator.Database.CountAllEntityByFilter(target.table, model.EmptyFilter, queryParams, target.count)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if req.DryRun {
+		return &resp, nil
+	}
+
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("PurgeTrash TransactionBegin Error.err[%v]", err)
+		return nil, err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	for _, target := range targets {
+		if *target.count == 0 {
+			continue
+		}
+		err = tx.Database.DeleteUnscopedEntityByFilter(target.table, model.EmptyFilter, queryParams, target.mode)
+		if err != nil {
+			log.Error("回收站[%s]数据清理失败. err:[%v]", target.table, err)
+			return nil, err
+		}
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("PurgeTrash TransactionCommit Error.err[%v]", err)
+		return nil, err
+	}
+	log.Info("回收站清理完成,删除时间早于[%s],%+v", resp.DeletedBefore, resp.CascadeCount)
+	return &resp, nil
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"crypto/sha256"
+	"demo-gogo/api/apimodel"
+	"demo-gogo/config"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils/redis"
+	"demo-gogo/utils/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/rs/xid"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"image"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	folderUpload     = "uploads"      //上传中的临时文件
+	folderPointCloud = "point_clouds" //上传完成的点云
+	uploadPartExt    = ".part"
+
+	uploadLockWait = 3 * time.Second
+	uploadLockTime = 10 * time.Minute //单个分片写入的最长时间
+)
+
+// uploadTask Redis中记录的分片上传任务
+type uploadTask struct {
+	token      string
+	infoID     int
+	kind       string
+	fileName   string
+	fileSize   int64
+	uploadSize int64
+	path       string //临时文件路径
+	checksum   string
+	expiresAt  time.Time
+}
+
+func uploadTTL() time.Duration {
+	return time.Duration(config.Conf.APP.UploadExpireHours) * time.Hour
+}
+
+func uploadLockKey(token string) string {
+	return model.GetFileUploadKey(token) + ":lock"
+}
+
+// InitUpload 创建分片上传任务，返回后续上传分片使用的token
+func (operator *ResourceOperator) InitUpload(req *apimodel.UploadInitRequest) (*apimodel.UploadInfo, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, req.InfoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	dir := filepath.Join(config.Conf.APP.UploadBasePath, folderUpload)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	removeExpiredUploads(dir)
+
+	task := uploadTask{
+		token:     xid.New().String(),
+		infoID:    req.InfoID,
+		kind:      req.Kind,
+		fileName:  filepath.Base(req.FileName),
+		fileSize:  req.FileSize,
+		checksum:  strings.ToLower(req.Checksum),
+		expiresAt: time.Now().Add(uploadTTL()),
+	}
+	task.path = filepath.Join(dir, task.token+uploadPartExt)
+	f, err := os.Create(task.path)
+	if err != nil {
+		return nil, err
+	}
+	_ = f.Close()
+	fields := map[string]interface{}{
+		model.FileUploadToken:  task.token,
+		model.FileUploadInfoID: task.infoID,
+		model.FileUploadKind:   task.kind,
+		model.FileName:         task.fileName,
+		model.FileSize:         task.fileSize,
+		model.FileUploadSize:   0,
+		model.FilePath:         task.path,
+		model.FileChecksum:     task.checksum,
+	}
+	key := model.GetFileUploadKey(task.token)
+	err = redis.RedisClient.HMSet(key, fields).Err()
+	if err == nil {
+		err = redis.RedisClient.Expire(key, uploadTTL()).Err()
+	}
+	if err != nil {
+		log.Error("上传任务写入失败. err:[%v]", err)
+		_ = os.Remove(task.path)
+		return nil, err
+	}
+	log.Info("创建上传任务[%s],切片[%d] 用途[%s] 文件[%s] 大小[%d]", task.token, task.infoID, task.kind, task.fileName, task.fileSize)
+	info := task.info()
+	return &info, nil
+}
+
+// GetUpload 查询上传进度，断点续传时从upload_size处继续上传
+func (operator *ResourceOperator) GetUpload(req *apimodel.UploadRequest) (*apimodel.UploadInfo, error) {
+	task, err := loadUploadTask(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	info := task.info()
+	return &info, nil
+}
+
+// UploadChunk 在offset处写入一个分片，offset须等于已上传大小。
+// 连接中断时已写入的部分仍计入进度，客户端查询进度后从断点继续
+func (operator *ResourceOperator) UploadChunk(req *apimodel.UploadChunkRequest) (*apimodel.UploadInfo, error) {
+	uuid, err := redis.LockWithTimeout(uploadLockKey(req.Token), uploadLockWait, uploadLockTime)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = redis.UnLock(uploadLockKey(req.Token), uuid)
+	}()
+	task, err := loadUploadTask(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if req.Offset != task.uploadSize {
+		return nil, errors.New(errcode.ErrorMsgUploadOffset)
+	}
+
+	f, err := os.OpenFile(task.path, os.O_WRONLY, 0)
+	if err != nil {
+		log.Error("上传临时文件[%s]打开失败. err:[%v]", task.path, err)
+		return nil, err
+	}
+	_, err = f.Seek(req.Offset, io.SeekStart)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	// 超出文件大小的数据不写入
+	written, copyErr := io.Copy(f, io.LimitReader(req.Data, task.fileSize-req.Offset))
+	if copyErr == nil && written == task.fileSize-req.Offset {
+		if n, _ := req.Data.Read(make([]byte, 1)); n > 0 {
+			copyErr = fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "offset")
+		}
+	}
+	err = f.Close()
+	if copyErr == nil {
+		copyErr = err
+	}
+
+	task.uploadSize += written
+	task.expiresAt = time.Now().Add(uploadTTL())
+	key := model.GetFileUploadKey(task.token)
+	err = redis.RedisClient.HSet(key, model.FileUploadSize, task.uploadSize).Err()
+	if err == nil {
+		err = redis.RedisClient.Expire(key, uploadTTL()).Err()
+	}
+	if err != nil {
+		log.Error("上传进度写入失败. err:[%v]", err)
+		return nil, err
+	}
+	if copyErr != nil {
+		log.Warn("上传任务[%s]分片写入中断,已上传[%d/%d]. err:[%v]", task.token, task.uploadSize, task.fileSize, copyErr)
+		return nil, copyErr
+	}
+	info := task.info()
+	return &info, nil
+}
+
+// CompleteUpload 校验文件大小与sha256后将文件转存并写入地图切片对应字段，校验和不一致时丢弃已上传数据
+func (operator *ResourceOperator) CompleteUpload(req *apimodel.UploadRequest) (*apimodel.UploadInfo, error) {
+	uuid, err := redis.LockWithTimeout(uploadLockKey(req.Token), uploadLockWait, uploadLockTime)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = redis.UnLock(uploadLockKey(req.Token), uuid)
+	}()
+	task, err := loadUploadTask(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if task.uploadSize != task.fileSize {
+		return nil, errors.New(errcode.ErrorMsgUploadIncomplete)
+	}
+	checksum := strings.ToLower(req.Checksum)
+	if checksum == "" {
+		checksum = task.checksum
+	}
+	if checksum == "" {
+		return nil, fmt.Errorf(errcode.ErrorMsgPrefixInvalidParameter, "checksum")
+	}
+	actual, err := fileSHA256(task.path)
+	if err != nil {
+		return nil, err
+	}
+	if actual != checksum {
+		log.Warn("上传任务[%s]校验和不一致,期望[%s] 实际[%s]", task.token, checksum, actual)
+		task.remove()
+		return nil, errors.New(errcode.ErrorMsgUploadChecksum)
+	}
+	if task.kind != apimodel.UploadKindPointCloud {
+		if err = checkImageFile(task.path); err != nil {
+			task.remove()
+			return nil, err
+		}
+	}
+
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("CompleteUpload TransactionBegin Error.err[%v]", err)
+		return nil, err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	var mapInfo model.MapInfo
+	err = tx.Database.GetEntityForUpdate(model.TableNameMapInfo, task.infoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			task.remove()
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	// 切片被他人锁定时保留上传任务，释放锁后可重新完成
+	err = assertEditLock(task.infoID, req.EditContext)
+	if err != nil {
+		return nil, err
+	}
+	folder := folderRosMap
+	if task.kind == apimodel.UploadKindPointCloud {
+		folder = folderPointCloud
+	}
+	if storage.Default == nil {
+		return nil, errors.New("文件存储未初始化")
+	}
+	var scale float64
+	if task.kind == apimodel.UploadKindCompress {
+		scale = uploadedCompressScale(mapInfo, task.path)
+	}
+	key := path.Join(folder, task.token+strings.ToLower(filepath.Ext(task.fileName)))
+	if err = storage.Default.PutFile(key, task.path); err != nil {
+		log.Error("上传文件[%s]转存失败. err:[%v]", task.path, err)
+		return nil, err
+	}
+	// 被替换的文件在提交后删除
+	var replaced []string
+	var staleTiles tilePyramid
+	switch task.kind {
+	case apimodel.UploadKindMap:
+		replaced = append(replaced, mapInfo.MapURL)
+		mapInfo.MapURL = key
+		staleTiles = resetMapTiles(&mapInfo)
+		// 地图图片替换后清空压缩图片，提交后在后台重新生成
+		replaced = append(replaced, mapInfo.MapURLCompress)
+		mapInfo.MapURLCompress, mapInfo.CompressScale = "", 0
+	case apimodel.UploadKindCompress:
+		replaced = append(replaced, mapInfo.MapURLCompress)
+		mapInfo.MapURLCompress = key
+		mapInfo.CompressScale = scale
+	case apimodel.UploadKindPointCloud:
+		replaced = append(replaced, mapInfo.PointCloud)
+		mapInfo.PointCloud = key
+	}
+	err = tx.Database.SaveEntity(model.TableNameMapInfo, &mapInfo)
+	if err == nil {
+		err = tx.TransactionCommit()
+	}
+	if err != nil {
+		log.Error("地图信息数据更新失败. err:[%v]", err)
+		_ = storage.Default.Delete(key)
+		task.remove()
+		return nil, err
+	}
+	for _, v := range replaced {
+		if v != key {
+			removeStoredFile(v)
+		}
+	}
+	go staleTiles.remove()
+	if task.kind == apimodel.UploadKindMap {
+		operator.startCompressJob(task.infoID)
+	}
+	task.remove()
+	log.Info("上传任务[%s]完成,切片[%d] 用途[%s] 存储key[%s]", task.token, task.infoID, task.kind, key)
+	info := task.info()
+	info.FileURL = storage.URL(key)
+	return &info, nil
+}
+
+// AbortUpload 取消上传任务并删除已上传的数据
+func (operator *ResourceOperator) AbortUpload(req *apimodel.UploadRequest) error {
+	uuid, err := redis.LockWithTimeout(uploadLockKey(req.Token), uploadLockWait, uploadLockTime)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = redis.UnLock(uploadLockKey(req.Token), uuid)
+	}()
+	task, err := loadUploadTask(req.Token)
+	if err != nil {
+		return err
+	}
+	task.remove()
+	log.Info("取消上传任务[%s]", task.token)
+	return nil
+}
+
+// loadUploadTask 读取上传任务，任务不存在或已过期时返回错误
+func loadUploadTask(token string) (*uploadTask, error) {
+	fields, err := redis.RedisClient.HGetAll(model.GetFileUploadKey(token)).Result()
+	if err != nil {
+		log.Error("上传任务查询失败. err:[%v]", err)
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "上传任务")
+	}
+	task := uploadTask{
+		token:    token,
+		kind:     fields[model.FileUploadKind],
+		fileName: fields[model.FileName],
+		path:     fields[model.FilePath],
+		checksum: fields[model.FileChecksum],
+	}
+	task.infoID, _ = strconv.Atoi(fields[model.FileUploadInfoID])
+	task.fileSize, _ = strconv.ParseInt(fields[model.FileSize], 10, 64)
+	task.uploadSize, _ = strconv.ParseInt(fields[model.FileUploadSize], 10, 64)
+	ttl, err := redis.RedisClient.PTTL(model.GetFileUploadKey(token)).Result()
+	if err == nil && ttl > 0 {
+		task.expiresAt = time.Now().Add(ttl)
+	}
+	return &task, nil
+}
+
+func (task *uploadTask) info() apimodel.UploadInfo {
+	return apimodel.UploadInfo{
+		Token:      task.token,
+		InfoID:     task.infoID,
+		Kind:       task.kind,
+		FileName:   task.fileName,
+		FileSize:   task.fileSize,
+		UploadSize: task.uploadSize,
+		ChunkSize:  config.Conf.APP.UploadFileSize,
+		ExpiresAt:  model.LocalTime(task.expiresAt).String(),
+	}
+}
+
+// remove 删除任务记录及临时文件
+func (task *uploadTask) remove() {
+	_ = os.Remove(task.path)
+	err := redis.RedisClient.Del(model.GetFileUploadKey(task.token)).Err()
+	if err != nil {
+		log.Error("上传任务[%s]删除失败. err:[%v]", task.token, err)
+	}
+}
+
+// removeExpiredUploads 清理任务已过期仍残留的临时文件
+func removeExpiredUploads(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	deadline := time.Now().Add(-uploadTTL())
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != uploadPartExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err = os.Remove(path); err == nil {
+			log.Info("清理过期上传临时文件[%s]", path)
+		}
+	}
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// uploadedCompressScale 手动上传的压缩图片与原图的宽度比例，原图不可读时返回0(不支持压缩坐标换算)
+func uploadedCompressScale(mapInfo model.MapInfo, path string) float64 {
+	width, _, err := mapImageSize(mapInfo.MapURL)
+	if err != nil || width == 0 {
+		log.Warn("地图切片[%d]原图尺寸读取失败,压缩图片比例未知. err:[%v]", mapInfo.ID, err)
+		return 0
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0
+	}
+	return float64(cfg.Width) / float64(width)
+}
+
+// checkImageFile 校验地图图片可被解码
+func checkImageFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, _, err = image.DecodeConfig(f); err != nil {
+		log.Error("上传的地图图片[%s]解码失败. err:[%v]", path, err)
+		return errors.New(errcode.ErrorMsgMapImageDecode)
+	}
+	return nil
+}
@@ -0,0 +1,571 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+// sliceGraph 地图切片拓扑校验所需的全部数据
+type sliceGraph struct {
+	nodes          []model.MapRouteNodes
+	routes         []model.MapRoutes
+	connectorNodes map[int]struct{} //被楼层连接器引用的节点id
+}
+
+// graphFix 自动修复需要执行的数据变更
+type graphFix struct {
+	deleteRouteIDs []int
+	deleteNodeIDs  []int
+	updateRoutes   []model.MapRoutes
+}
+
+// ValidateMapInfo 校验地图切片路径网络的拓扑完整性，fix为true时在事务内自动修复可修复的问题并复查
+func (operator *ResourceOperator) ValidateMapInfo(req *apimodel.ValidateMapInfoRequest) (*apimodel.ValidateMapInfoResponse, error) {
+	var mapInfo model.MapInfo
+	err := operator.Database.GetEntityByID(model.TableNameMapInfo, req.InfoID, &mapInfo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片")
+		}
+		return nil, err
+	}
+	resp := apimodel.ValidateMapInfoResponse{InfoID: req.InfoID}
+	graph, err := operator.loadSliceGraph(req.InfoID)
+	if err != nil {
+		return nil, err
+	}
+	issues, fix := graph.check()
+	resp.Issues = issues
+	if !req.Fix || len(fix.deleteRouteIDs)+len(fix.deleteNodeIDs)+len(fix.updateRoutes) == 0 {
+		resp.Valid = len(issues) == 0
+		return &resp, nil
+	}
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("ValidateMapInfo TransactionBegin Error.err[%v]", err)
+		return nil, err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	_, err = tx.lockSliceForEdit(req.InfoID, req.EditContext)
+	if err != nil {
+		return nil, err
+	}
+	// 锁定切片后重新检查，按锁定时的数据修复
+	graph, err = tx.loadSliceGraph(req.InfoID)
+	if err != nil {
+		return nil, err
+	}
+	resp.Issues, fix = graph.check()
+	before, err := tx.loadSnapshot(req.InfoID)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.applyGraphFix(req.InfoID, fix)
+	if err != nil {
+		return nil, err
+	}
+	// 事务内复查，修复后的剩余问题作为最终结果
+	graph, err = tx.loadSliceGraph(req.InfoID)
+	if err != nil {
+		return nil, err
+	}
+	remaining, _ := graph.check()
+	err = tx.recordRevision(req.InfoID, before, req.EditContext, RevisionOpFix)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("ValidateMapInfo TransactionCommit Error.err[%v]", err)
+		return nil, err
+	}
+	// 复查后不再出现的问题视为已修复
+	left := make(map[string]struct{}, len(remaining))
+	for _, v := range remaining {
+		left[issueKey(v)] = struct{}{}
+	}
+	for i := range resp.Issues {
+		_, ok := left[issueKey(resp.Issues[i])]
+		resp.Issues[i].Fixed = !ok
+	}
+	resp.Remaining = remaining
+	resp.Valid = len(remaining) == 0
+	log.Info("地图切片[%d]拓扑自动修复完成,删除路径[%v] 删除节点[%v] 合并路径[%d]条", req.InfoID, fix.deleteRouteIDs, fix.deleteNodeIDs, len(fix.updateRoutes))
+	return &resp, nil
+}
+
+func (operator *ResourceOperator) loadSliceGraph(infoID int) (*sliceGraph, error) {
+	graph := sliceGraph{connectorNodes: make(map[int]struct{})}
+	selector := make(map[string]interface{})
+	queryParams := model.QueryParams{
+		Orders: []model.Order{{Field: model.FieldID, Direction: apimodel.OrderAsc}},
+	}
+	selector[model.FieldInfoId] = infoID
+	err := operator.Database.ListEntityByFilter(model.TableNameMapRouteNodes, selector, queryParams, &graph.nodes)
+	if err != nil {
+		log.Error("节点数据查询失败. err:[%v]", err)
+		return nil, err
+	}
+	err = operator.Database.ListEntityByFilter(model.TableNameMapRoutes, selector, queryParams, &graph.routes)
+	if err != nil {
+		log.Error("路径数据查询失败. err:[%v]", err)
+		return nil, err
+	}
+	for _, field := range []string{model.FieldFromInfoID, model.FieldToInfoID} {
+		var connectors []model.MapConnector
+		err = operator.Database.ListEntityByFilter(model.TableNameMapConnector, map[string]interface{}{field: infoID}, model.QueryParams{}, &connectors)
+		if err != nil {
+			log.Error("连接器数据查询失败. err:[%v]", err)
+			return nil, err
+		}
+		for _, v := range connectors {
+			graph.connectorNodes[v.FromNodeID] = struct{}{}
+			graph.connectorNodes[v.ToNodeID] = struct{}{}
+		}
+	}
+	return &graph, nil
+}
+
+// check 检查拓扑问题，返回问题列表及可自动修复部分的修复方案
+func (g *sliceGraph) check() ([]apimodel.GraphIssue, graphFix) {
+	issues := make([]apimodel.GraphIssue, 0)
+	var fix graphFix
+
+	// 节点重名、缺少坐标
+	names := make(map[string][]model.MapRouteNodes)
+	byID := make(map[int]model.MapRouteNodes, len(g.nodes))
+	for _, v := range g.nodes {
+		byID[v.ID] = v
+		names[v.NodeName] = append(names[v.NodeName], v)
+		if len(v.Roi) < 2 {
+			issues = append(issues, apimodel.GraphIssue{
+				Type:    apimodel.IssueNodeMissingRoi,
+				NodeIDs: []int{v.ID},
+				Message: fmt.Sprintf("节点[%s]缺少坐标", v.NodeName),
+			})
+		}
+	}
+	for _, v := range g.nodes {
+		same := names[v.NodeName]
+		if len(same) > 1 && same[0].ID == v.ID {
+			issues = append(issues, apimodel.GraphIssue{
+				Type:    apimodel.IssueDuplicateNodeName,
+				NodeIDs: nodeIDs(same),
+				Message: fmt.Sprintf("节点名称[%s]重复%d次", v.NodeName, len(same)),
+			})
+		}
+	}
+
+	// 悬空路径、自环路径，其余路径按无序节点对分组，路径按节点id引用起终点
+	referenced := make(map[int]struct{})
+	pairs := make(map[[2]int][]model.MapRoutes)
+	pairKeys := make([][2]int, 0)
+	for _, v := range g.routes {
+		referenced[v.StartNodeID] = struct{}{}
+		referenced[v.EndNodeID] = struct{}{}
+		_, startOK := byID[v.StartNodeID]
+		_, endOK := byID[v.EndNodeID]
+		if !startOK || !endOK {
+			issues = append(issues, apimodel.GraphIssue{
+				Type:     apimodel.IssueDanglingRoute,
+				RouteIDs: []int{v.ID},
+				Message:  fmt.Sprintf("路径[%s]的起点[%s]或终点[%s]不存在", v.RoutesName, v.Start, v.End),
+				Fixable:  true,
+			})
+			fix.deleteRouteIDs = append(fix.deleteRouteIDs, v.ID)
+			continue
+		}
+		if v.StartNodeID == v.EndNodeID {
+			issues = append(issues, apimodel.GraphIssue{
+				Type:     apimodel.IssueSelfLoopRoute,
+				RouteIDs: []int{v.ID},
+				Message:  fmt.Sprintf("路径[%s]的起点与终点相同", v.RoutesName),
+				Fixable:  true,
+			})
+			fix.deleteRouteIDs = append(fix.deleteRouteIDs, v.ID)
+			continue
+		}
+		key := [2]int{v.StartNodeID, v.EndNodeID}
+		if key[0] > key[1] {
+			key[0], key[1] = key[1], key[0]
+		}
+		if _, ok := pairs[key]; !ok {
+			pairKeys = append(pairKeys, key)
+		}
+		pairs[key] = append(pairs[key], v)
+	}
+
+	// 重复路径合并到id最小的一条，通行方向取并集，后续检查按合并后的路径进行
+	valid := make([]model.MapRoutes, 0, len(pairKeys))
+	for _, key := range pairKeys {
+		group := pairs[key]
+		if len(group) == 1 {
+			valid = append(valid, group[0])
+			continue
+		}
+		merged := unionRoute(group)
+		valid = append(valid, merged)
+		issues = append(issues, apimodel.GraphIssue{
+			Type:     apimodel.IssueDuplicateRoute,
+			RouteIDs: routeIDs(group),
+			Message:  fmt.Sprintf("节点[%s]与[%s]之间存在%d条路径", byID[key[0]].NodeName, byID[key[1]].NodeName, len(group)),
+			Fixable:  true,
+		})
+		fix.updateRoutes = append(fix.updateRoutes, merged)
+		fix.deleteRouteIDs = append(fix.deleteRouteIDs, routeIDs(group[1:])...)
+	}
+
+	// 孤立节点，充电桩等类型节点可能刚放置尚未连线，只报告不自动删除
+	for _, v := range g.nodes {
+		if _, ok := referenced[v.ID]; ok {
+			continue
+		}
+		if _, ok := g.connectorNodes[v.ID]; ok {
+			continue
+		}
+		normal := nodeType(v.Type) == model.NodeTypeNormal
+		issues = append(issues, apimodel.GraphIssue{
+			Type:    apimodel.IssueOrphanNode,
+			NodeIDs: []int{v.ID},
+			Message: fmt.Sprintf("节点[%s]未被任何路径引用", v.NodeName),
+			Fixable: normal,
+		})
+		if normal {
+			fix.deleteNodeIDs = append(fix.deleteNodeIDs, v.ID)
+		}
+	}
+
+	issues = append(issues, crossingIssues(valid, byID)...)
+	issues = append(issues, connectivityIssues(g.nodes, valid)...)
+	for i := range issues {
+		issues[i].Level = apimodel.IssueLevel(issues[i].Type)
+	}
+	return issues, fix
+}
+
+// crossingIssues 无公共节点的相交路径，经空间索引只比较几何可能相交的路径对
+func crossingIssues(routes []model.MapRoutes, byID map[int]model.MapRouteNodes) []apimodel.GraphIssue {
+	issues := make([]apimodel.GraphIssue, 0)
+	rois := make(map[int]pq.Float64Array, len(byID))
+	for id, v := range byID {
+		rois[id] = v.Roi
+	}
+	paths := make([][][2]float64, len(routes))
+	for i, v := range routes {
+		paths[i], _ = routeGeometry(v.Shape, v.ControlPoints, rois[v.StartNodeID], rois[v.EndNodeID])
+	}
+	index := newRouteIndex(routes, rois)
+	for _, pair := range index.pairs() {
+		a, b := routes[pair[0]], routes[pair[1]]
+		if a.StartNodeID == b.StartNodeID || a.StartNodeID == b.EndNodeID || a.EndNodeID == b.StartNodeID || a.EndNodeID == b.EndNodeID {
+			continue
+		}
+		if utils.PolylinesIntersect(paths[pair[0]], paths[pair[1]]) {
+			issues = append(issues, apimodel.GraphIssue{
+				Type:     apimodel.IssueCrossingRoutes,
+				RouteIDs: []int{a.ID, b.ID},
+				Message:  fmt.Sprintf("路径[%s]与[%s]相交但没有公共节点", a.RoutesName, b.RoutesName),
+			})
+		}
+	}
+	return issues
+}
+
+// connectivityIssues 连通性检查。先按无向图划分连通分量，主分量以外的分量逐一报告为不连通；
+// 主分量内再按路径允许的通行方向，从最大强连通分量出发正向、反向求可达节点，
+// 分别报告无法到达及驶入后无法返回的节点
+func connectivityIssues(nodes []model.MapRouteNodes, routes []model.MapRoutes) []apimodel.GraphIssue {
+	issues := make([]apimodel.GraphIssue, 0)
+	forward := make(map[int][]int)
+	backward := make(map[int][]int)
+	for _, v := range routes {
+		if model.PathRoleAllowStartToEnd(v.PathRole) {
+			forward[v.StartNodeID] = append(forward[v.StartNodeID], v.EndNodeID)
+			backward[v.EndNodeID] = append(backward[v.EndNodeID], v.StartNodeID)
+		}
+		if model.PathRoleAllowEndToStart(v.PathRole) {
+			forward[v.EndNodeID] = append(forward[v.EndNodeID], v.StartNodeID)
+			backward[v.StartNodeID] = append(backward[v.StartNodeID], v.EndNodeID)
+		}
+	}
+	parent := make(map[int]int)
+	var find func(int) int
+	find = func(x int) int {
+		if parent[x] != x {
+			parent[x] = find(parent[x])
+		}
+		return parent[x]
+	}
+	for _, v := range routes {
+		for _, id := range []int{v.StartNodeID, v.EndNodeID} {
+			if _, ok := parent[id]; !ok {
+				parent[id] = id
+			}
+		}
+		parent[find(v.StartNodeID)] = find(v.EndNodeID)
+	}
+	ids := make([]int, 0, len(parent))
+	for _, v := range nodes {
+		if _, ok := parent[v.ID]; ok {
+			ids = append(ids, v.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return issues
+	}
+	// 最大强连通分量所在的无向分量为主分量
+	main := largestComponent(stronglyConnected(ids, forward))
+	mainRoot := find(main[0])
+	components := make(map[int][]int)
+	for _, id := range ids {
+		root := find(id)
+		components[root] = append(components[root], id)
+	}
+	list := make([][]int, 0, len(components))
+	for root, members := range components {
+		if root != mainRoot {
+			list = append(list, members)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i]) != len(list[j]) {
+			return len(list[i]) > len(list[j])
+		}
+		return list[i][0] < list[j][0]
+	})
+	for _, members := range list {
+		issues = append(issues, apimodel.GraphIssue{
+			Type:    apimodel.IssueDisconnected,
+			NodeIDs: members,
+			Message: fmt.Sprintf("%d个节点与主路径网络不连通", len(members)),
+		})
+	}
+
+	reached := reachable(main, forward)
+	returned := reachable(main, backward)
+	var unreachable, deadEnd []int
+	for _, id := range components[mainRoot] {
+		if !reached[id] {
+			unreachable = append(unreachable, id)
+		}
+		if !returned[id] {
+			deadEnd = append(deadEnd, id)
+		}
+	}
+	if len(unreachable) > 0 {
+		issues = append(issues, apimodel.GraphIssue{
+			Type:    apimodel.IssueUnreachable,
+			NodeIDs: unreachable,
+			Message: fmt.Sprintf("%d个节点按通行方向无法从主路径网络到达", len(unreachable)),
+		})
+	}
+	if len(deadEnd) > 0 {
+		issues = append(issues, apimodel.GraphIssue{
+			Type:    apimodel.IssueDeadEnd,
+			NodeIDs: deadEnd,
+			Message: fmt.Sprintf("%d个节点按通行方向驶入后无法返回主路径网络", len(deadEnd)),
+		})
+	}
+	return issues
+}
+
+// stronglyConnected 有向图的强连通分量(Kosaraju)，ids为全部节点，adj为出边。
+// 分量内节点保持ids中的顺序
+func stronglyConnected(ids []int, adj map[int][]int) [][]int {
+	reverse := make(map[int][]int)
+	for from, list := range adj {
+		for _, to := range list {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+	// 第一遍按完成顺序记录节点，使用显式栈避免长路径递归过深
+	visited := make(map[int]bool, len(ids))
+	order := make([]int, 0, len(ids))
+	type frame struct {
+		id   int
+		next int
+	}
+	for _, start := range ids {
+		if visited[start] {
+			continue
+		}
+		visited[start] = true
+		stack := []frame{{id: start}}
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.next < len(adj[top.id]) {
+				to := adj[top.id][top.next]
+				top.next++
+				if !visited[to] {
+					visited[to] = true
+					stack = append(stack, frame{id: to})
+				}
+				continue
+			}
+			order = append(order, top.id)
+			stack = stack[:len(stack)-1]
+		}
+	}
+	// 第二遍按完成顺序倒序在反向图上划分分量
+	component := make(map[int]int, len(ids))
+	count := 0
+	for i := len(order) - 1; i >= 0; i-- {
+		start := order[i]
+		if _, ok := component[start]; ok {
+			continue
+		}
+		component[start] = count
+		stack := []int{start}
+		for len(stack) > 0 {
+			id := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, from := range reverse[id] {
+				if _, ok := component[from]; !ok {
+					component[from] = count
+					stack = append(stack, from)
+				}
+			}
+		}
+		count++
+	}
+	components := make([][]int, count)
+	for _, id := range ids {
+		components[component[id]] = append(components[component[id]], id)
+	}
+	return components
+}
+
+// largestComponent 节点最多的分量，数量相同时取首个节点在前者
+func largestComponent(components [][]int) []int {
+	var largest []int
+	for _, v := range components {
+		if len(v) > len(largest) || len(v) == len(largest) && len(v) > 0 && v[0] < largest[0] {
+			largest = v
+		}
+	}
+	return largest
+}
+
+// reachable 从starts出发沿adj可到达的全部节点(含starts)
+func reachable(starts []int, adj map[int][]int) map[int]bool {
+	seen := make(map[int]bool, len(starts))
+	queue := make([]int, 0, len(starts))
+	for _, id := range starts {
+		seen[id] = true
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, to := range adj[id] {
+			if !seen[to] {
+				seen[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	return seen
+}
+
+// issueKey 按问题类型及涉及的节点、路径标识问题，用于比对修复前后的问题列表
+func issueKey(issue apimodel.GraphIssue) string {
+	return fmt.Sprintf("%s|%v|%v", issue.Type, issue.NodeIDs, issue.RouteIDs)
+}
+
+// applyGraphFix 执行自动修复
+func (operator *ResourceOperator) applyGraphFix(infoID int, fix graphFix) error {
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	now := time.Now()
+	for i := range fix.updateRoutes {
+		err := operator.Database.SaveEntity(model.TableNameMapRoutes, &fix.updateRoutes[i])
+		if err != nil {
+			log.Error("合并重复路径失败. err:[%v]", err)
+			return err
+		}
+	}
+	if len(fix.deleteRouteIDs) > 0 {
+		queryParams := model.QueryParams{}
+		queryParams.InQueries = append(queryParams.InQueries, &model.InQuery{Field: model.FieldID, Values: fix.deleteRouteIDs})
+		err := softDeleteEntity(operator.Database, model.TableNameMapRoutes, selector, queryParams, now)
+		if err != nil {
+			log.Error("删除问题路径失败. err:[%v]", err)
+			return err
+		}
+	}
+	if len(fix.deleteNodeIDs) > 0 {
+		queryParams := model.QueryParams{}
+		queryParams.InQueries = append(queryParams.InQueries, &model.InQuery{Field: model.FieldID, Values: fix.deleteNodeIDs})
+		err := softDeleteEntity(operator.Database, model.TableNameMapRouteNodes, selector, queryParams, now)
+		if err != nil {
+			log.Error("删除孤立节点失败. err:[%v]", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// unionRoute 将连接同一对节点的多条路径合并到第一条，各方向的通行权限取并集，行驶方式取先出现者
+func unionRoute(group []model.MapRoutes) model.MapRoutes {
+	keep := group[0]
+	var forward, backward bool
+	var forwardDrive, backwardDrive string
+	for _, v := range group {
+		fwd, bwd := model.PathRoleAllowStartToEnd(v.PathRole), model.PathRoleAllowEndToStart(v.PathRole)
+		fwdDrive, bwdDrive := v.StartToEnd, v.EndToStart
+		if v.StartNodeID != keep.StartNodeID {
+			fwd, bwd = bwd, fwd
+			fwdDrive, bwdDrive = bwdDrive, fwdDrive
+		}
+		if fwd && !forward {
+			forward, forwardDrive = true, fwdDrive
+		}
+		if bwd && !backward {
+			backward, backwardDrive = true, bwdDrive
+		}
+	}
+	switch {
+	case forward && backward:
+		keep.PathRole = model.PathRoleBidirectional
+	case forward:
+		keep.PathRole = model.PathRoleStartToEnd
+	case backward:
+		keep.PathRole = model.PathRoleEndToStart
+	}
+	if forward {
+		keep.StartToEnd = forwardDrive
+	}
+	if backward {
+		keep.EndToStart = backwardDrive
+	}
+	fillRouteRule(&keep)
+	return keep
+}
+
+func nodeIDs(nodes []model.MapRouteNodes) []int {
+	ids := make([]int, 0, len(nodes))
+	for _, v := range nodes {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
+
+func routeIDs(routes []model.MapRoutes) []int {
+	ids := make([]int, 0, len(routes))
+	for _, v := range routes {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
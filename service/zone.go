@@ -0,0 +1,301 @@
+package service
+
+import (
+	"demo-gogo/api/apimodel"
+	"demo-gogo/database/model"
+	"demo-gogo/httpserver/errcode"
+	"demo-gogo/utils"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	log "github.com/wonderivan/logger"
+	"gorm.io/gorm"
+	"math"
+)
+
+// CreateOrUpdateMapZone 创建或更新地图切片上的区域，世界坐标输入按切片坐标系转换为像素坐标存储
+func (operator *ResourceOperator) CreateOrUpdateMapZone(req *apimodel.MapZoneRequest) error {
+	// 开启事务
+	tx, err := operator.TransactionBegin()
+	if err != nil {
+		log.Error("CreateOrUpdateMapZone TransactionBegin Error.err[%v]", err)
+		return err
+	}
+	defer func() {
+		_ = tx.TransactionRollback()
+	}()
+	mapInfo, err := tx.lockSliceForEdit(req.InfoID, req.EditContext)
+	if err != nil {
+		return err
+	}
+	before, err := tx.loadSnapshot(req.InfoID)
+	if err != nil {
+		return err
+	}
+	var opt model.MapZone
+	selector := make(map[string]interface{})
+	// 同一切片内名称唯一
+	selector[model.FieldName] = req.Name
+	selector[model.FieldInfoId] = req.InfoID
+	err = tx.Database.ListEntityByFilter(model.TableNameMapZone, selector, model.OneQuery, &opt)
+	if err != nil {
+		return err
+	}
+	if opt.ID != 0 && opt.ID != req.ID {
+		return fmt.Errorf(errcode.ErrorMsgSuffixParamExists, "区域")
+	}
+	if req.ID > 0 {
+		err = tx.Database.GetEntityByID(model.TableNameMapZone, req.ID, &opt)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "待修改区域")
+			}
+			return err
+		}
+		if opt.InfoID != req.InfoID {
+			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "地图切片内的待修改区域")
+		}
+	}
+
+	opt.Name = req.Name
+	opt.InfoID = req.InfoID
+	opt.Type = req.Type
+	opt.Polygon = req.Polygon
+	opt.MaxSpeed = req.MaxSpeed
+	opt.Heading = req.Heading
+	if !apimodel.IsPixelFrame(req.Frame) {
+		frame, err := newMapFrame(mapInfo)
+		if err != nil {
+			return err
+		}
+		points := toPoints(req.Polygon)
+		for i, p := range points {
+			pixel := frame.revert(pq.Float64Array{p[0], p[1]}, req.Frame)
+			points[i] = [2]float64{pixel[0], pixel[1]}
+		}
+		opt.Polygon = flattenPoints(points)
+		if req.Heading != nil {
+			heading := frame.revertHeading(*req.Heading, req.Frame)
+			opt.Heading = &heading
+		}
+	}
+	if req.ID > 0 {
+		err = tx.Database.SaveEntity(model.TableNameMapZone, &opt)
+		if err != nil {
+			log.Error("区域数据更新失败. err:[%v]", err)
+			return err
+		}
+	} else {
+		err = tx.Database.CreateEntity(model.TableNameMapZone, &opt)
+		if err != nil {
+			log.Error("区域数据创建失败. err:[%v]", err)
+			return err
+		}
+	}
+	err = tx.recordRevision(req.InfoID, before, req.EditContext, RevisionOpSaveZone)
+	if err != nil {
+		return err
+	}
+	err = tx.TransactionCommit()
+	if err != nil {
+		log.Error("CreateOrUpdateMapZone TransactionCommit Error.err[%v]", err)
+		return err
+	}
+	return nil
+}
+
+func (operator *ResourceOperator) ListMapZones(req *apimodel.MapZoneRequest) (*apimodel.MapZoneResponse, error) {
+	var resp apimodel.MapZoneResponse
+	selector := make(map[string]interface{})
+	queryParams := model.QueryParams{}
+	if req.ID > 0 {
+		selector[model.FieldID] = req.ID
+	}
+	if req.Name != "" {
+		selector[model.FieldName] = req.Name
+	}
+	if req.InfoID > 0 {
+		selector[model.FieldInfoId] = req.InfoID
+	}
+	if req.Type != "" {
+		selector[model.FieldType] = req.Type
+	}
+	var count int64
+	var zones []model.MapZone
+	var err error
+	published := apimodel.ReadsPublished(req.Stage, req.InfoID)
+	if published {
+		zones, count, err = operator.listPublishedZones(req)
+	} else {
+		err = operator.Database.CountEntityByFilter(model.TableNameMapZone, selector, model.OneQuery, &count)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 && !published {
+		order := model.Order{
+			Field:     req.OrderBy,
+			Direction: apimodel.OrderAsc,
+		}
+		queryParams.Orders = append(queryParams.Orders, order)
+		if req.PageSize > 0 {
+			queryParams.Limit = &req.PageSize
+			offset := (req.PageNo - 1) * req.PageSize
+			queryParams.Offset = &offset
+		}
+		err = operator.Database.ListEntityByFilter(model.TableNameMapZone, selector, queryParams, &zones)
+		if err != nil {
+			log.Error("区域数据查询失败. err:[%v]", err)
+			return nil, err
+		}
+	}
+	resp.Load(count, zones)
+	if !apimodel.IsPixelFrame(req.Frame) {
+		err = operator.newFrameConverter(req.Frame).zones(resp.List)
+		if err != nil {
+			return nil, err
+		}
+		resp.Frame = req.Frame
+	}
+	return &resp, nil
+}
+
+func (operator *ResourceOperator) DeleteMapZone(req *apimodel.MapZoneRequest) error {
+	var zone model.MapZone
+	err := operator.Database.GetEntityByID(model.TableNameMapZone, req.ID, &zone)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf(errcode.ErrorMsgSuffixParamNotExists, "待删除区域")
+		}
+		return err
+	}
+	return operator.editSlices(req.EditContext, []int{zone.InfoID}, func(tx *ResourceOperator) error {
+		before, err := tx.loadSnapshot(zone.InfoID)
+		if err != nil {
+			return err
+		}
+		selector := make(map[string]interface{})
+		selector[model.FieldID] = req.ID
+		err = tx.Database.DeleteEntityByFilter(model.TableNameMapZone, selector, model.QueryParams{}, &model.MapZone{})
+		if err != nil {
+			log.Error("区域数据删除失败. err:[%v]", err)
+			return err
+		}
+		return tx.recordRevision(zone.InfoID, before, req.EditContext, RevisionOpDeleteZone)
+	})
+}
+
+// listZones 地图切片上的全部区域
+func (operator *ResourceOperator) listZones(infoID int) ([]model.MapZone, error) {
+	var zones []model.MapZone
+	selector := make(map[string]interface{})
+	selector[model.FieldInfoId] = infoID
+	err := operator.Database.ListEntityByFilter(model.TableNameMapZone, selector, model.QueryParams{}, &zones)
+	if err != nil {
+		log.Error("区域数据查询失败. err:[%v]", err)
+		return nil, err
+	}
+	return zones, nil
+}
+
+// forbiddenZone 路径几何穿越的第一个禁行区及进入该区域的位置
+func forbiddenZone(points [][2]float64, zones []model.MapZone) (*model.MapZone, [2]float64, bool) {
+	for i := range zones {
+		if zones[i].Type != model.ZoneForbidden {
+			continue
+		}
+		pieces := utils.ClipPolylineToPolygon(points, toPoints(zones[i].Polygon))
+		if len(pieces) > 0 {
+			return &zones[i], pieces[0][0], true
+		}
+	}
+	return nil, [2]float64{}, false
+}
+
+// checkForbiddenZones 校验待保存路径的几何未穿越切片内的禁行区，端点坐标缺失的路径略过
+func (operator *ResourceOperator) checkForbiddenZones(infoID int, routes []model.MapRoutes) error {
+	if len(routes) == 0 {
+		return nil
+	}
+	zones, err := operator.listZones(infoID)
+	if err != nil || len(zones) == 0 {
+		return err
+	}
+	rois, err := operator.nodeRois(infoID)
+	if err != nil {
+		return err
+	}
+	for _, v := range routes {
+		points, ok := routeGeometry(v.Shape, v.ControlPoints, rois[v.StartNodeID], rois[v.EndNodeID])
+		if !ok {
+			continue
+		}
+		if zone, entry, ok := forbiddenZone(points, zones); ok {
+			log.Warn("路径：[%v] 在[%v]处穿越禁行区[%v]", v.RoutesName, entry, zone.Name)
+			return errors.New(errcode.ErrorMsgRouteForbidden)
+		}
+	}
+	return nil
+}
+
+// zoneEffect 区域对一条路径通行的影响
+type zoneEffect struct {
+	blocked    bool      //穿越禁行区
+	forward    bool      //允许起点驶向终点(不逆向穿越单向区)
+	backward   bool      //允许终点驶向起点
+	soundOff   bool      //途经静音区
+	maxSpeed   float64   //途经限速区的最低限速，0表示不限速
+	slowLength []float64 //途经各限速区的长度(像素)，与slowSpeed一一对应
+	slowSpeed  []float64
+}
+
+// routeZoneEffect 按路径几何(起点至终点方向)计算各区域的影响
+func routeZoneEffect(points [][2]float64, zones []model.MapZone) zoneEffect {
+	effect := zoneEffect{forward: true, backward: true}
+	for _, zone := range zones {
+		pieces := utils.ClipPolylineToPolygon(points, toPoints(zone.Polygon))
+		if len(pieces) == 0 {
+			continue
+		}
+		switch zone.Type {
+		case model.ZoneForbidden:
+			effect.blocked = true
+		case model.ZoneSoundOff:
+			effect.soundOff = true
+		case model.ZoneSlow:
+			effect.maxSpeed = minPositive(effect.maxSpeed, zone.MaxSpeed)
+			var length float64
+			for _, piece := range pieces {
+				length += utils.PolylineLength(piece[:])
+			}
+			effect.slowLength = append(effect.slowLength, length)
+			effect.slowSpeed = append(effect.slowSpeed, zone.MaxSpeed)
+		case model.ZoneOneWay:
+			if zone.Heading == nil {
+				continue
+			}
+			//区域内各段行驶方向与允许朝向相反的方向不可通行
+			hx, hy := math.Cos(*zone.Heading), math.Sin(*zone.Heading)
+			for _, piece := range pieces {
+				dot := (piece[1][0]-piece[0][0])*hx + (piece[1][1]-piece[0][1])*hy
+				if dot < 0 {
+					effect.forward = false
+				} else if dot > 0 {
+					effect.backward = false
+				}
+			}
+		}
+	}
+	return effect
+}
+
+// slowCost 限速区带来的额外通行代价：区域内长度按参考速度与区域限速之比折算为行驶时间
+func (effect zoneEffect) slowCost(speed float64) float64 {
+	var cost float64
+	for i, length := range effect.slowLength {
+		if effect.slowSpeed[i] > 0 && effect.slowSpeed[i] < speed {
+			cost += length * (speed/effect.slowSpeed[i] - 1)
+		}
+	}
+	return cost
+}
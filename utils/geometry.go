@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// BresenhamLine 返回两点间线段经过的全部像素(含端点)，按起点到终点的顺序
+func BresenhamLine(x0, y0, x1, y1 int) []image.Point {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	size := dx
+	if -dy > size {
+		size = -dy
+	}
+	points := make([]image.Point, 0, size+1)
+	e := dx + dy
+	for {
+		points = append(points, image.Pt(x0, y0))
+		if x0 == x1 && y0 == y1 {
+			return points
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// DilateDisc 原地膨胀宽width高height的行优先掩码：与任一为true的像素欧氏距离不超过radius的像素均置为true。
+// 先按列求到最近目标像素的纵向距离，再逐行求下包络得到精确欧氏距离，耗时与像素数线性相关
+func DilateDisc(mask []bool, width, height int, radius float64) {
+	if radius < 1 || width <= 0 || height <= 0 {
+		return
+	}
+	limit := radius * radius
+	//纵向距离超过半径的统一按radius+1处理，只需判断是否在半径内
+	far := int(radius) + 1
+	column := make([]uint16, width*height)
+	for x := 0; x < width; x++ {
+		d := far
+		for y := 0; y < height; y++ {
+			if mask[y*width+x] {
+				d = 0
+			} else if d < far {
+				d++
+			}
+			column[y*width+x] = uint16(d)
+		}
+		d = far
+		for y := height - 1; y >= 0; y-- {
+			if mask[y*width+x] {
+				d = 0
+			} else if d < far {
+				d++
+			}
+			if uint16(d) < column[y*width+x] {
+				column[y*width+x] = uint16(d)
+			}
+		}
+	}
+	f := make([]float64, width)
+	v := make([]int, width)
+	z := make([]float64, width+1)
+	for y := 0; y < height; y++ {
+		row := column[y*width : (y+1)*width]
+		for x, d := range row {
+			f[x] = float64(d) * float64(d)
+		}
+		//抛物线下包络，见Felzenszwalb & Huttenlocher距离变换
+		k := 0
+		v[0], z[0], z[1] = 0, math.Inf(-1), math.Inf(1)
+		for q := 1; q < width; q++ {
+			s := ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+			for s <= z[k] {
+				k--
+				s = ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+			}
+			k++
+			v[k], z[k], z[k+1] = q, s, math.Inf(1)
+		}
+		k = 0
+		for x := 0; x < width; x++ {
+			for z[k+1] < float64(x) {
+				k++
+			}
+			dx := float64(x - v[k])
+			mask[y*width+x] = dx*dx+f[v[k]] <= limit
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// SegmentsIntersect 判断线段p1p2与p3p4是否相交(含端点接触与共线重叠)
+func SegmentsIntersect(p1, p2, p3, p4 [2]float64) bool {
+	d1 := cross(p3, p4, p1)
+	d2 := cross(p3, p4, p2)
+	d3 := cross(p1, p2, p3)
+	d4 := cross(p1, p2, p4)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(p3, p4, p1)) || (d2 == 0 && onSegment(p3, p4, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, p3)) || (d4 == 0 && onSegment(p1, p2, p4))
+}
+
+// cross 向量ab与ac的叉积
+func cross(a, b, c [2]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// onSegment 已知p与线段ab共线时，判断p是否落在线段ab上
+func onSegment(a, b, p [2]float64) bool {
+	return math.Min(a[0], b[0]) <= p[0] && p[0] <= math.Max(a[0], b[0]) &&
+		math.Min(a[1], b[1]) <= p[1] && p[1] <= math.Max(a[1], b[1])
+}
+
+// CubicBezier 三次贝塞尔曲线在参数t∈[0,1]处的坐标，p0、p3为端点，p1、p2为控制点
+func CubicBezier(p0, p1, p2, p3 [2]float64, t float64) [2]float64 {
+	u := 1 - t
+	a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+	return [2]float64{
+		a*p0[0] + b*p1[0] + c*p2[0] + d*p3[0],
+		a*p0[1] + b*p1[1] + c*p2[1] + d*p3[1],
+	}
+}
+
+// SplitCubicBezier 按de Casteljau算法在t处拆分三次贝塞尔曲线，返回前后两段各自的两个控制点
+func SplitCubicBezier(p0, p1, p2, p3 [2]float64, t float64) ([2][2]float64, [2][2]float64) {
+	p01, p12, p23 := lerp(p0, p1, t), lerp(p1, p2, t), lerp(p2, p3, t)
+	p012, p123 := lerp(p01, p12, t), lerp(p12, p23, t)
+	return [2][2]float64{p01, p012}, [2][2]float64{p123, p23}
+}
+
+func lerp(a, b [2]float64, t float64) [2]float64 {
+	return [2]float64{a[0] + (b[0]-a[0])*t, a[1] + (b[1]-a[1])*t}
+}
+
+// PolylineLength 折线各段长度之和
+func PolylineLength(points [][2]float64) float64 {
+	var length float64
+	for i := 0; i+1 < len(points); i++ {
+		length += math.Hypot(points[i+1][0]-points[i][0], points[i+1][1]-points[i][1])
+	}
+	return length
+}
+
+// ClosestPointOnPolyline 折线上距p最近的点，返回该点、所在线段序号、在该线段上的比例[0,1]及距离
+func ClosestPointOnPolyline(p [2]float64, points [][2]float64) ([2]float64, int, float64, float64) {
+	closest, segment, ratio, distance := [2]float64{}, 0, 0.0, math.Inf(1)
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		t := 0.0
+		if square := dx*dx + dy*dy; square > 0 {
+			t = math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/square))
+		}
+		q := lerp(a, b, t)
+		if d := math.Hypot(p[0]-q[0], p[1]-q[1]); d < distance {
+			closest, segment, ratio, distance = q, i, t, d
+		}
+	}
+	return closest, segment, ratio, distance
+}
+
+// PolylinesIntersect 判断两条折线是否有线段相交
+func PolylinesIntersect(a, b [][2]float64) bool {
+	for i := 0; i+1 < len(a); i++ {
+		for j := 0; j+1 < len(b); j++ {
+			if SegmentsIntersect(a[i], a[i+1], b[j], b[j+1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PointInPolygon 射线法判断点p是否位于多边形内部，多边形顶点首尾自动闭合
+func PointInPolygon(p [2]float64, polygon [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// ClipPolylineToPolygon 折线落在多边形内部的部分，按沿折线的先后顺序返回各直线段的起止点
+func ClipPolylineToPolygon(points [][2]float64, polygon [][2]float64) [][2][2]float64 {
+	var pieces [][2][2]float64
+	if len(polygon) < 3 {
+		return pieces
+	}
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		//线段与多边形各边的交点将线段切分为若干小段，小段中点在多边形内则整段在内
+		ts := []float64{0, 1}
+		for j, k := 0, len(polygon)-1; j < len(polygon); k, j = j, j+1 {
+			if t, ok := segmentIntersectRatio(a, b, polygon[k], polygon[j]); ok {
+				ts = append(ts, t)
+			}
+		}
+		sort.Float64s(ts)
+		for n := 0; n+1 < len(ts); n++ {
+			if ts[n+1]-ts[n] <= 1e-9 {
+				continue
+			}
+			if !PointInPolygon(lerp(a, b, (ts[n]+ts[n+1])/2), polygon) {
+				continue
+			}
+			pieces = append(pieces, [2][2]float64{lerp(a, b, ts[n]), lerp(a, b, ts[n+1])})
+		}
+	}
+	return pieces
+}
+
+// segmentIntersectRatio 线段ab与cd的交点在ab上的比例，平行或不相交时返回false
+func segmentIntersectRatio(a, b, c, d [2]float64) (float64, bool) {
+	rx, ry := b[0]-a[0], b[1]-a[1]
+	sx, sy := d[0]-c[0], d[1]-c[1]
+	denominator := rx*sy - ry*sx
+	if denominator == 0 {
+		return 0, false
+	}
+	qx, qy := c[0]-a[0], c[1]-a[1]
+	t := (qx*sy - qy*sx) / denominator
+	u := (qx*ry - qy*rx) / denominator
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return t, true
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBresenhamLine(t *testing.T) {
+	tests := []struct {
+		name           string
+		x0, y0, x1, y1 int
+		want           []image.Point
+	}{
+		{"单点", 2, 3, 2, 3, []image.Point{{2, 3}}},
+		{"水平", 0, 0, 3, 0, []image.Point{{0, 0}, {1, 0}, {2, 0}, {3, 0}}},
+		{"竖直反向", 1, 2, 1, -1, []image.Point{{1, 2}, {1, 1}, {1, 0}, {1, -1}}},
+		{"对角线", 0, 0, 3, 3, []image.Point{{0, 0}, {1, 1}, {2, 2}, {3, 3}}},
+		{"缓坡", 0, 0, 4, 2, []image.Point{{0, 0}, {1, 1}, {2, 1}, {3, 2}, {4, 2}}},
+		{"反向对角线", 3, 0, 0, 3, []image.Point{{3, 0}, {2, 1}, {1, 2}, {0, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BresenhamLine(tt.x0, tt.y0, tt.x1, tt.y1)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BresenhamLine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// dilateBruteForce 逐像素比较欧氏距离的膨胀，作为DilateDisc的参照
+func dilateBruteForce(mask []bool, width, height int, radius float64) []bool {
+	out := make([]bool, len(mask))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for j := 0; j < height && !out[y*width+x]; j++ {
+				for i := 0; i < width; i++ {
+					dx, dy := float64(x-i), float64(y-j)
+					if mask[j*width+i] && dx*dx+dy*dy <= radius*radius {
+						out[y*width+x] = true
+						break
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+func TestDilateDisc(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		radius        float64
+		density       float64
+	}{
+		{"半径小于1不变", 8, 8, 0.5, 0.1},
+		{"单像素半径", 12, 9, 1, 0.05},
+		{"非整数半径", 16, 11, 2.5, 0.03},
+		{"稀疏大半径", 20, 20, 6, 0.01},
+		{"密集", 15, 17, 3, 0.3},
+		{"单行", 30, 1, 4, 0.1},
+		{"单列", 1, 30, 4, 0.1},
+		{"全空", 10, 10, 3, 0},
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mask := make([]bool, tt.width*tt.height)
+			for i := range mask {
+				mask[i] = r.Float64() < tt.density
+			}
+			want := dilateBruteForce(mask, tt.width, tt.height, tt.radius)
+			if tt.radius < 1 {
+				want = append([]bool(nil), mask...)
+			}
+			DilateDisc(mask, tt.width, tt.height, tt.radius)
+			if !reflect.DeepEqual(mask, want) {
+				t.Errorf("DilateDisc() = %v, want %v", mask, want)
+			}
+		})
+	}
+}
+
+func TestSegmentsIntersect(t *testing.T) {
+	tests := []struct {
+		name           string
+		p1, p2, p3, p4 [2]float64
+		want           bool
+	}{
+		{"交叉", [2]float64{0, 0}, [2]float64{2, 2}, [2]float64{0, 2}, [2]float64{2, 0}, true},
+		{"平行", [2]float64{0, 0}, [2]float64{2, 0}, [2]float64{0, 1}, [2]float64{2, 1}, false},
+		{"端点接触", [2]float64{0, 0}, [2]float64{1, 1}, [2]float64{1, 1}, [2]float64{2, 0}, true},
+		{"T形接触", [2]float64{0, 0}, [2]float64{2, 0}, [2]float64{1, 0}, [2]float64{1, 3}, true},
+		{"共线重叠", [2]float64{0, 0}, [2]float64{2, 0}, [2]float64{1, 0}, [2]float64{3, 0}, true},
+		{"共线分离", [2]float64{0, 0}, [2]float64{1, 0}, [2]float64{2, 0}, [2]float64{3, 0}, false},
+		{"延长线相交", [2]float64{0, 0}, [2]float64{1, 1}, [2]float64{3, 0}, [2]float64{2, 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SegmentsIntersect(tt.p1, tt.p2, tt.p3, tt.p4); got != tt.want {
+				t.Errorf("SegmentsIntersect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointInPolygon(t *testing.T) {
+	square := [][2]float64{{0, 0}, {4, 0}, {4, 4}, {0, 4}}
+	concave := [][2]float64{{0, 0}, {4, 0}, {4, 4}, {2, 1}, {0, 4}}
+	tests := []struct {
+		name    string
+		p       [2]float64
+		polygon [][2]float64
+		want    bool
+	}{
+		{"正方形内部", [2]float64{2, 2}, square, true},
+		{"正方形外部", [2]float64{5, 2}, square, false},
+		{"凹多边形凹口内", [2]float64{2, 3}, concave, false},
+		{"凹多边形内部", [2]float64{1, 0.5}, concave, true},
+		{"退化多边形", [2]float64{0, 0}, [][2]float64{{0, 0}, {1, 1}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PointInPolygon(tt.p, tt.polygon); got != tt.want {
+				t.Errorf("PointInPolygon() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClipPolylineToPolygon(t *testing.T) {
+	square := [][2]float64{{0, 0}, {4, 0}, {4, 4}, {0, 4}}
+	concave := [][2]float64{{0, 0}, {4, 0}, {4, 4}, {2, 1}, {0, 4}}
+	tests := []struct {
+		name    string
+		points  [][2]float64
+		polygon [][2]float64
+		want    [][2][2]float64
+	}{
+		{"穿过", [][2]float64{{-2, 2}, {6, 2}}, square, [][2][2]float64{{{0, 2}, {4, 2}}}},
+		{"完全在内", [][2]float64{{1, 1}, {3, 3}}, square, [][2][2]float64{{{1, 1}, {3, 3}}}},
+		{"完全在外", [][2]float64{{5, 5}, {6, 6}}, square, nil},
+		{"折线分段", [][2]float64{{-1, 1}, {2, 1}, {2, 6}}, square, [][2][2]float64{{{0, 1}, {2, 1}}, {{2, 1}, {2, 4}}}},
+		{"凹口分为两段", [][2]float64{{-1, 3}, {5, 3}}, concave, [][2][2]float64{{{0, 3}, {2.0 / 3, 3}}, {{10.0 / 3, 3}, {4, 3}}}},
+		{"多边形不足3点", [][2]float64{{0, 0}, {1, 1}}, [][2]float64{{0, 0}, {2, 2}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClipPolylineToPolygon(tt.points, tt.polygon)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ClipPolylineToPolygon() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				for j := 0; j < 2; j++ {
+					if !pointNear(got[i][j], tt.want[i][j]) {
+						t.Errorf("ClipPolylineToPolygon() = %v, want %v", got, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestClosestPointOnPolyline(t *testing.T) {
+	polyline := [][2]float64{{0, 0}, {4, 0}, {4, 4}}
+	tests := []struct {
+		name        string
+		p           [2]float64
+		wantPoint   [2]float64
+		wantSegment int
+		wantRatio   float64
+		wantDist    float64
+	}{
+		{"第一段中部", [2]float64{1, 1}, [2]float64{1, 0}, 0, 0.25, 1},
+		{"第二段", [2]float64{6, 3}, [2]float64{4, 3}, 1, 0.75, 2},
+		{"起点之外", [2]float64{-3, -4}, [2]float64{0, 0}, 0, 0, 5},
+		{"终点之外", [2]float64{4, 7}, [2]float64{4, 4}, 1, 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			point, segment, ratio, dist := ClosestPointOnPolyline(tt.p, polyline)
+			if !pointNear(point, tt.wantPoint) || segment != tt.wantSegment ||
+				math.Abs(ratio-tt.wantRatio) > 1e-9 || math.Abs(dist-tt.wantDist) > 1e-9 {
+				t.Errorf("ClosestPointOnPolyline() = %v, %d, %v, %v, want %v, %d, %v, %v",
+					point, segment, ratio, dist, tt.wantPoint, tt.wantSegment, tt.wantRatio, tt.wantDist)
+			}
+		})
+	}
+}
+
+func TestPolylineLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		points [][2]float64
+		want   float64
+	}{
+		{"空", nil, 0},
+		{"单点", [][2]float64{{1, 1}}, 0},
+		{"直线", [][2]float64{{0, 0}, {3, 4}}, 5},
+		{"折线", [][2]float64{{0, 0}, {3, 4}, {3, 0}}, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PolylineLength(tt.points); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("PolylineLength() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitCubicBezier(t *testing.T) {
+	p0, p1, p2, p3 := [2]float64{0, 0}, [2]float64{1, 3}, [2]float64{4, 3}, [2]float64{5, 0}
+	tests := []struct {
+		name string
+		t    float64
+	}{
+		{"中点", 0.5},
+		{"靠近起点", 0.2},
+		{"靠近终点", 0.9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, tail := SplitCubicBezier(p0, p1, p2, p3, tt.t)
+			mid := CubicBezier(p0, p1, p2, p3, tt.t)
+			//拆分后的两段曲线分别覆盖原曲线的[0,t]与[t,1]
+			for _, s := range []float64{0, 0.3, 0.7, 1} {
+				if got, want := CubicBezier(p0, head[0], head[1], mid, s), CubicBezier(p0, p1, p2, p3, s*tt.t); !pointNear(got, want) {
+					t.Errorf("前段在%v处 = %v, want %v", s, got, want)
+				}
+				if got, want := CubicBezier(mid, tail[0], tail[1], p3, s), CubicBezier(p0, p1, p2, p3, tt.t+s*(1-tt.t)); !pointNear(got, want) {
+					t.Errorf("后段在%v处 = %v, want %v", s, got, want)
+				}
+			}
+		})
+	}
+}
+
+func pointNear(a, b [2]float64) bool {
+	return math.Abs(a[0]-b[0]) < 1e-9 && math.Abs(a[1]-b[1]) < 1e-9
+}
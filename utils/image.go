@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+)
+
+// ResizeArea 按区域平均将图片缩放为width x height，每个源像素计入其所在的目标像素。
+// 灰度图直接读取像素缓冲，输出灰度图；其它格式输出RGBA
+func ResizeArea(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 || srcW == 0 || srcH == 0 {
+		return image.NewGray(image.Rect(0, 0, 0, 0))
+	}
+	// 只缩小不放大，保证每个目标像素至少对应一个源像素
+	if width > srcW {
+		width = srcW
+	}
+	if height > srcH {
+		height = srcH
+	}
+	// 源像素列/行到目标列/行的映射
+	cols := make([]int, srcW)
+	for x := range cols {
+		cols[x] = x * width / srcW
+	}
+	counts := make([]uint32, width*height)
+	for y := 0; y < srcH; y++ {
+		row := y * height / srcH * width
+		for x := 0; x < srcW; x++ {
+			counts[row+cols[x]]++
+		}
+	}
+
+	if gray, ok := src.(*image.Gray); ok {
+		sums := make([]uint64, width*height)
+		for y := 0; y < srcH; y++ {
+			row := y * height / srcH * width
+			pix := gray.Pix[y*gray.Stride : y*gray.Stride+srcW]
+			for x, v := range pix {
+				sums[row+cols[x]] += uint64(v)
+			}
+		}
+		dst := image.NewGray(image.Rect(0, 0, width, height))
+		for i, sum := range sums {
+			dst.Pix[i] = uint8(sum / uint64(counts[i]))
+		}
+		return dst
+	}
+
+	sums := make([][4]uint64, width*height)
+	for y := 0; y < srcH; y++ {
+		row := y * height / srcH * width
+		for x := 0; x < srcW; x++ {
+			r, g, b, a := src.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			sum := &sums[row+cols[x]]
+			sum[0] += uint64(r)
+			sum[1] += uint64(g)
+			sum[2] += uint64(b)
+			sum[3] += uint64(a)
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, sum := range sums {
+		n := uint64(counts[i])
+		dst.Set(i%width, i/width, color.RGBA64{
+			R: uint16(sum[0] / n), G: uint16(sum[1] / n), B: uint16(sum[2] / n), A: uint16(sum[3] / n),
+		})
+	}
+	return dst
+}
+
+// FlipVertical 返回上下翻转后的灰度图
+func FlipVertical(src *image.Gray) *image.Gray {
+	bounds := src.Bounds()
+	dst := image.NewGray(bounds)
+	width := bounds.Dx()
+	for y := 0; y < bounds.Dy(); y++ {
+		from := src.PixOffset(bounds.Min.X, bounds.Max.Y-1-y)
+		to := dst.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		copy(dst.Pix[to:to+width], src.Pix[from:from+width])
+	}
+	return dst
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestFlipVertical(t *testing.T) {
+	tests := []struct {
+		name string
+		src  *image.Gray
+		want []uint8
+	}{
+		{"单行不变", &image.Gray{Pix: []uint8{1, 2, 3}, Stride: 3, Rect: image.Rect(0, 0, 3, 1)}, []uint8{1, 2, 3}},
+		{"两行", &image.Gray{Pix: []uint8{1, 2, 3, 4}, Stride: 2, Rect: image.Rect(0, 0, 2, 2)}, []uint8{3, 4, 1, 2}},
+		{"三行", &image.Gray{Pix: []uint8{1, 2, 3, 4, 5, 6}, Stride: 2, Rect: image.Rect(0, 0, 2, 3)}, []uint8{5, 6, 3, 4, 1, 2}},
+		{
+			"子图",
+			(&image.Gray{Pix: []uint8{0, 0, 0, 0, 1, 2, 0, 3, 4}, Stride: 3, Rect: image.Rect(0, 0, 3, 3)}).SubImage(image.Rect(1, 1, 3, 3)).(*image.Gray),
+			[]uint8{3, 4, 1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FlipVertical(tt.src)
+			if got.Bounds() != tt.src.Bounds() {
+				t.Fatalf("FlipVertical() bounds = %v, want %v", got.Bounds(), tt.src.Bounds())
+			}
+			var pix []uint8
+			for y := got.Rect.Min.Y; y < got.Rect.Max.Y; y++ {
+				for x := got.Rect.Min.X; x < got.Rect.Max.X; x++ {
+					pix = append(pix, got.GrayAt(x, y).Y)
+				}
+			}
+			if !reflect.DeepEqual(pix, tt.want) {
+				t.Errorf("FlipVertical() = %v, want %v", pix, tt.want)
+			}
+		})
+	}
+}
+
+func TestResizeArea(t *testing.T) {
+	gray := &image.Gray{Pix: []uint8{0, 100, 50, 150, 200, 40, 10, 30}, Stride: 4, Rect: image.Rect(0, 0, 4, 2)}
+	rgba := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	rgba.Set(0, 0, color.RGBA{R: 200, A: 255})
+	rgba.Set(1, 0, color.RGBA{B: 100, A: 255})
+	tests := []struct {
+		name          string
+		src           image.Image
+		width, height int
+		want          image.Image
+	}{
+		{"灰度图缩小一半", gray, 2, 1, &image.Gray{Pix: []uint8{85, 60}, Stride: 2, Rect: image.Rect(0, 0, 2, 1)}},
+		{"灰度图不放大", gray, 8, 4, &image.Gray{Pix: gray.Pix, Stride: 4, Rect: image.Rect(0, 0, 4, 2)}},
+		{"目标尺寸为0", gray, 0, 1, image.NewGray(image.Rect(0, 0, 0, 0))},
+		{"RGBA取平均", rgba, 1, 1, &image.RGBA{Pix: []uint8{100, 0, 50, 255}, Stride: 4, Rect: image.Rect(0, 0, 1, 1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResizeArea(tt.src, tt.width, tt.height); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResizeArea() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+func init() {
+	// 注册后image.Decode可直接识别pgm格式(ROS map_server常用)
+	image.RegisterFormat("pgm", "P5", DecodePGM, DecodePGMConfig)
+	image.RegisterFormat("pgm", "P2", DecodePGM, DecodePGMConfig)
+}
+
+// pgmMaxSize pgm图片的最大边长(像素)
+const pgmMaxSize = 1 << 15
+
+type pgmHeader struct {
+	magic  string
+	width  int
+	height int
+	maxVal int
+}
+
+// readPGMHeader 读取pgm文件头，支持P2(ASCII)与P5(二进制)，允许#注释
+func readPGMHeader(r *bufio.Reader) (*pgmHeader, error) {
+	var header pgmHeader
+	fields := make([]int, 0, 3)
+	magic, err := readPGMToken(r)
+	if err != nil {
+		return nil, err
+	}
+	if magic != "P5" && magic != "P2" {
+		return nil, fmt.Errorf("pgm magic number[%s]不支持", magic)
+	}
+	header.magic = magic
+	for len(fields) < 3 {
+		token, err := readPGMToken(r)
+		if err != nil {
+			return nil, err
+		}
+		var v int
+		if _, err = fmt.Sscanf(token, "%d", &v); err != nil || v <= 0 {
+			return nil, fmt.Errorf("pgm文件头[%s]解析失败", token)
+		}
+		fields = append(fields, v)
+	}
+	header.width, header.height, header.maxVal = fields[0], fields[1], fields[2]
+	if header.maxVal > 65535 {
+		return nil, fmt.Errorf("pgm maxval[%d]超出范围", header.maxVal)
+	}
+	if header.width > pgmMaxSize || header.height > pgmMaxSize {
+		return nil, fmt.Errorf("pgm尺寸[%dx%d]超过上限%d", header.width, header.height, pgmMaxSize)
+	}
+	return &header, nil
+}
+
+// readPGMToken 读取一个以空白分隔的token，跳过注释。P5格式读取maxval后仅消费一个空白字符
+func readPGMToken(r *bufio.Reader) (string, error) {
+	var token []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(token) > 0 {
+				return string(token), nil
+			}
+			return "", err
+		}
+		if b == '#' && len(token) == 0 {
+			if _, err = r.ReadString('\n'); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if b == ' ' || b == '\t' || b == '\n' || b == '\r' {
+			if len(token) > 0 {
+				return string(token), nil
+			}
+			continue
+		}
+		token = append(token, b)
+	}
+}
+
+// DecodePGMConfig 读取pgm图片尺寸
+func DecodePGMConfig(r io.Reader) (image.Config, error) {
+	header, err := readPGMHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	colorModel := color.GrayModel
+	if header.maxVal > 255 {
+		colorModel = color.Gray16Model
+	}
+	return image.Config{ColorModel: colorModel, Width: header.width, Height: header.height}, nil
+}
+
+// DecodePGM 解码pgm图片，maxval不超过255时返回*image.Gray，否则返回*image.Gray16。
+// 像素数据读取完整后才分配图片，文件头声明的尺寸与实际数据不符时不会按声明尺寸分配内存
+func DecodePGM(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	header, err := readPGMHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	wide := header.maxVal > 255
+	pix, err := readPGMPixels(br, header, wide)
+	if err != nil {
+		return nil, err
+	}
+	rect := image.Rect(0, 0, header.width, header.height)
+	if wide {
+		for i := 0; i < len(pix); i += 2 {
+			value := scalePGMValue(int(pix[i])<<8|int(pix[i+1]), header.maxVal, 65535)
+			pix[i], pix[i+1] = uint8(value>>8), uint8(value)
+		}
+		return &image.Gray16{Pix: pix, Stride: 2 * header.width, Rect: rect}, nil
+	}
+	if header.maxVal != 255 {
+		for i, v := range pix {
+			pix[i] = uint8(scalePGMValue(int(v), header.maxVal, 255))
+		}
+	}
+	return &image.Gray{Pix: pix, Stride: header.width, Rect: rect}, nil
+}
+
+// scalePGMValue 将像素值由[0,maxVal]缩放到[0,full]，超出maxVal的值与P2格式一致按maxVal处理
+func scalePGMValue(v, maxVal, full int) int {
+	if v > maxVal {
+		v = maxVal
+	}
+	return v * full / maxVal
+}
+
+// readPGMPixels 读取全部像素的原始值(16位时高字节在前)，缓冲随实际读到的数据增长
+func readPGMPixels(br *bufio.Reader, header *pgmHeader, wide bool) ([]byte, error) {
+	size := header.width * header.height
+	if wide {
+		size *= 2
+	}
+	if header.magic == "P5" {
+		pix, err := io.ReadAll(io.LimitReader(br, int64(size)))
+		if err != nil {
+			return nil, err
+		}
+		if len(pix) < size {
+			return nil, fmt.Errorf("pgm像素数据不完整,已读取%d/%d字节", len(pix), size)
+		}
+		return pix, nil
+	}
+	var pix bytes.Buffer
+	for pix.Len() < size {
+		v, err := readPGMValue(br, header, wide)
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("pgm像素数据不完整,已读取%d/%d字节", pix.Len(), size)
+			}
+			return nil, err
+		}
+		if wide {
+			pix.WriteByte(uint8(v >> 8))
+		}
+		pix.WriteByte(uint8(v))
+	}
+	return pix.Bytes(), nil
+}
+
+func readPGMValue(r *bufio.Reader, header *pgmHeader, wide bool) (int, error) {
+	if header.magic == "P2" {
+		token, err := readPGMToken(r)
+		if err != nil {
+			return 0, err
+		}
+		var v int
+		if _, err = fmt.Sscanf(token, "%d", &v); err != nil {
+			return 0, fmt.Errorf("pgm像素[%s]解析失败", token)
+		}
+		if v > header.maxVal {
+			v = header.maxVal
+		}
+		return v, nil
+	}
+	if wide {
+		hi, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		lo, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		return int(hi)<<8 | int(lo), nil
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return int(b), nil
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScalePGMValue(t *testing.T) {
+	tests := []struct {
+		name            string
+		v, maxVal, full int
+		want            int
+	}{
+		{"最小值", 0, 100, 255, 0},
+		{"最大值", 100, 100, 255, 255},
+		{"中间值", 50, 100, 255, 127},
+		{"超出maxVal按maxVal处理", 200, 100, 255, 255},
+		{"16位", 1023, 1023, 65535, 65535},
+		{"16位超出maxVal", 65535, 1023, 65535, 65535},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scalePGMValue(tt.v, tt.maxVal, tt.full); got != tt.want {
+				t.Errorf("scalePGMValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodePGM(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    image.Image
+		wantErr bool
+	}{
+		{
+			name: "P5",
+			data: "P5\n2 2\n255\n\x00\x40\x80\xff",
+			want: &image.Gray{Pix: []uint8{0, 0x40, 0x80, 0xff}, Stride: 2, Rect: image.Rect(0, 0, 2, 2)},
+		},
+		{
+			name: "P2带注释",
+			data: "P2\n# comment\n3 1\n# maxval\n255\n0 128 255\n",
+			want: &image.Gray{Pix: []uint8{0, 128, 255}, Stride: 3, Rect: image.Rect(0, 0, 3, 1)},
+		},
+		{
+			name: "P5按maxval缩放并截断超出值",
+			data: "P5 2 1 100 \x32\xc8",
+			want: &image.Gray{Pix: []uint8{127, 255}, Stride: 2, Rect: image.Rect(0, 0, 2, 1)},
+		},
+		{
+			name: "P2按maxval缩放并截断超出值",
+			data: "P2 2 1 100 50 200",
+			want: &image.Gray{Pix: []uint8{127, 255}, Stride: 2, Rect: image.Rect(0, 0, 2, 1)},
+		},
+		{
+			name: "16位",
+			data: "P5 2 1 65535 \x12\x34\xff\xff",
+			want: &image.Gray16{Pix: []uint8{0x12, 0x34, 0xff, 0xff}, Stride: 4, Rect: image.Rect(0, 0, 2, 1)},
+		},
+		{name: "不支持的格式", data: "P6 1 1 255 \x00\x00\x00", wantErr: true},
+		{name: "尺寸非法", data: "P5 0 1 255 ", wantErr: true},
+		{name: "maxval超出范围", data: "P5 1 1 70000 \x00", wantErr: true},
+		{name: "尺寸超过上限", data: "P5 40000 1 255 \x00", wantErr: true},
+		{name: "P5数据不完整", data: "P5 2 2 255 \x00\x00", wantErr: true},
+		{name: "P2数据不完整", data: "P2 2 2 255 0 0 0", wantErr: true},
+		{name: "P2像素非数字", data: "P2 1 1 255 x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePGM(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodePGM() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodePGM() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodePGMConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantW     int
+		wantH     int
+		wantWide  bool
+		wantError bool
+	}{
+		{name: "8位", data: "P5\n640 480\n255\n", wantW: 640, wantH: 480},
+		{name: "16位", data: "P2 3 2 4095 ", wantW: 3, wantH: 2, wantWide: true},
+		{name: "文件头不完整", data: "P5 640", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePGMConfig(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantError {
+				t.Fatalf("DecodePGMConfig() error = %v, wantErr %v", err, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+			if got.Width != tt.wantW || got.Height != tt.wantH {
+				t.Errorf("DecodePGMConfig() = %dx%d, want %dx%d", got.Width, got.Height, tt.wantW, tt.wantH)
+			}
+			if wide := got.ColorModel == color.Gray16Model; wide != tt.wantWide {
+				t.Errorf("DecodePGMConfig() 16位 = %v, want %v", wide, tt.wantWide)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// pointField 点记录中的一个标量字段
+type pointField struct {
+	name   string
+	kind   byte //F浮点 I有符号整数 U无符号整数
+	size   int  //字节数
+	offset int  //二进制记录内的字节偏移
+	column int  //ASCII记录内的列序号
+}
+
+// PointCloudReader 流式读取PCD/PLY点云，只解析x/y/z坐标，支持ASCII与二进制编码
+type PointCloudReader struct {
+	Format string //pcd/ply
+	Points int64  //文件头声明的点数
+	r      *bufio.Reader
+	ascii  bool
+	order  binary.ByteOrder
+	record int //二进制记录长度(字节)
+	xyz    [3]pointField
+}
+
+// NewPointCloudReader 读取点云文件头，按文件开头识别PCD与PLY格式
+func NewPointCloudReader(r io.Reader) (*PointCloudReader, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	magic, err := br.Peek(3)
+	if err != nil {
+		return nil, fmt.Errorf("点云文件头读取失败: %v", err)
+	}
+	reader := &PointCloudReader{r: br, order: binary.LittleEndian}
+	if string(magic) == "ply" {
+		reader.Format = "ply"
+		err = reader.readPLYHeader()
+	} else {
+		reader.Format = "pcd"
+		err = reader.readPCDHeader()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// readHeaderLine 读取一行文件头，去掉首尾空白
+func (p *PointCloudReader) readHeaderLine() (string, error) {
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("点云文件头不完整: %v", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func (p *PointCloudReader) readPCDHeader() error {
+	var names, sizes, types, counts []string
+	var width, height int64
+	for {
+		line, err := p.readHeaderLine()
+		if err != nil {
+			return err
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch strings.ToUpper(fields[0]) {
+		case "FIELDS":
+			names = fields[1:]
+		case "SIZE":
+			sizes = fields[1:]
+		case "TYPE":
+			types = fields[1:]
+		case "COUNT":
+			counts = fields[1:]
+		case "WIDTH":
+			if len(fields) > 1 {
+				width, _ = strconv.ParseInt(fields[1], 10, 64)
+			}
+		case "HEIGHT":
+			if len(fields) > 1 {
+				height, _ = strconv.ParseInt(fields[1], 10, 64)
+			}
+		case "POINTS":
+			if len(fields) > 1 {
+				p.Points, _ = strconv.ParseInt(fields[1], 10, 64)
+			}
+		case "DATA":
+			if len(fields) < 2 {
+				return fmt.Errorf("pcd文件头DATA缺少编码")
+			}
+			switch fields[1] {
+			case "ascii":
+				p.ascii = true
+			case "binary":
+			default:
+				return fmt.Errorf("pcd编码[%s]不支持", fields[1])
+			}
+			// 早期版本没有POINTS，点数为WIDTH*HEIGHT
+			if p.Points == 0 {
+				p.Points = width * height
+			}
+			return p.pcdFields(names, sizes, types, counts)
+		}
+	}
+}
+
+// pcdFields 由FIELDS/SIZE/TYPE/COUNT计算x/y/z在记录中的位置
+func (p *PointCloudReader) pcdFields(names, sizes, types, counts []string) error {
+	if len(sizes) != len(names) || len(types) != len(names) {
+		return fmt.Errorf("pcd文件头FIELDS/SIZE/TYPE数量不一致")
+	}
+	found := 0
+	offset, column := 0, 0
+	for i, name := range names {
+		size, err := strconv.Atoi(sizes[i])
+		if err != nil || size <= 0 {
+			return fmt.Errorf("pcd字段[%s]SIZE无效", name)
+		}
+		count := 1
+		if i < len(counts) {
+			if count, err = strconv.Atoi(counts[i]); err != nil || count <= 0 {
+				return fmt.Errorf("pcd字段[%s]COUNT无效", name)
+			}
+		}
+		field := pointField{name: name, kind: types[i][0], size: size, offset: offset, column: column}
+		if idx := strings.Index("xyz", name); len(name) == 1 && idx >= 0 {
+			if err = checkPointField(field); err != nil {
+				return err
+			}
+			p.xyz[idx] = field
+			found++
+		}
+		offset += size * count
+		column += count
+	}
+	if found != 3 {
+		return fmt.Errorf("pcd缺少x/y/z字段")
+	}
+	p.record = offset
+	return nil
+}
+
+// plyTypes PLY属性类型对应的字段类型与字节数
+var plyTypes = map[string]pointField{
+	"char": {kind: 'I', size: 1}, "int8": {kind: 'I', size: 1},
+	"uchar": {kind: 'U', size: 1}, "uint8": {kind: 'U', size: 1},
+	"short": {kind: 'I', size: 2}, "int16": {kind: 'I', size: 2},
+	"ushort": {kind: 'U', size: 2}, "uint16": {kind: 'U', size: 2},
+	"int": {kind: 'I', size: 4}, "int32": {kind: 'I', size: 4},
+	"uint": {kind: 'U', size: 4}, "uint32": {kind: 'U', size: 4},
+	"float": {kind: 'F', size: 4}, "float32": {kind: 'F', size: 4},
+	"double": {kind: 'F', size: 8}, "float64": {kind: 'F', size: 8},
+}
+
+// readPLYHeader 解析PLY文件头，vertex须为第一个元素且不含list属性
+func (p *PointCloudReader) readPLYHeader() error {
+	element := ""
+	vertexSeen := false
+	found, column := 0, 0
+	for {
+		line, err := p.readHeaderLine()
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return fmt.Errorf("ply文件头format缺少编码")
+			}
+			switch fields[1] {
+			case "ascii":
+				p.ascii = true
+			case "binary_little_endian":
+			case "binary_big_endian":
+				p.order = binary.BigEndian
+			default:
+				return fmt.Errorf("ply编码[%s]不支持", fields[1])
+			}
+		case "element":
+			if len(fields) < 3 {
+				return fmt.Errorf("ply文件头element格式错误")
+			}
+			element = fields[1]
+			if element == "vertex" {
+				if p.Points, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+					return fmt.Errorf("ply顶点数[%s]无效", fields[2])
+				}
+				vertexSeen = true
+			} else if !vertexSeen {
+				return fmt.Errorf("ply中vertex须为第一个元素")
+			}
+		case "property":
+			if element != "vertex" {
+				continue
+			}
+			if len(fields) < 3 || fields[1] == "list" {
+				return fmt.Errorf("ply顶点属性[%s]不支持", line)
+			}
+			field, ok := plyTypes[fields[1]]
+			if !ok {
+				return fmt.Errorf("ply属性类型[%s]不支持", fields[1])
+			}
+			field.name, field.offset, field.column = fields[2], p.record, column
+			if err = checkPointField(field); err != nil {
+				return err
+			}
+			if idx := strings.Index("xyz", field.name); len(field.name) == 1 && idx >= 0 {
+				p.xyz[idx] = field
+				found++
+			}
+			p.record += field.size
+			column++
+		case "end_header":
+			if found != 3 {
+				return fmt.Errorf("ply缺少x/y/z属性")
+			}
+			return nil
+		}
+	}
+}
+
+func checkPointField(field pointField) error {
+	switch field.kind {
+	case 'F':
+		if field.size == 4 || field.size == 8 {
+			return nil
+		}
+	case 'I', 'U':
+		if field.size == 1 || field.size == 2 || field.size == 4 || field.size == 8 {
+			return nil
+		}
+	}
+	return fmt.Errorf("点云字段[%s]类型[%c%d]不支持", field.name, field.kind, field.size)
+}
+
+// Read 依次读取各点坐标，跳过含NaN的点，fn返回错误时停止读取
+func (p *PointCloudReader) Read(fn func(x, y, z float64) error) error {
+	if p.ascii {
+		return p.readASCII(fn)
+	}
+	return p.readBinary(fn)
+}
+
+func (p *PointCloudReader) readASCII(fn func(x, y, z float64) error) error {
+	var read int64
+	for read < p.Points {
+		line, err := p.r.ReadString('\n')
+		if err != nil && (err != io.EOF || strings.TrimSpace(line) == "") {
+			if err == io.EOF {
+				return fmt.Errorf("点云数据不完整,已读取%d/%d个点", read, p.Points)
+			}
+			return err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		read++
+		var xyz [3]float64
+		for i, field := range p.xyz {
+			if field.column >= len(fields) {
+				return fmt.Errorf("点云第%d个点字段不足", read)
+			}
+			if xyz[i], err = strconv.ParseFloat(fields[field.column], 64); err != nil {
+				return fmt.Errorf("点云第%d个点坐标[%s]解析失败", read, fields[field.column])
+			}
+		}
+		if math.IsNaN(xyz[0]) || math.IsNaN(xyz[1]) || math.IsNaN(xyz[2]) {
+			continue
+		}
+		if err = fn(xyz[0], xyz[1], xyz[2]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PointCloudReader) readBinary(fn func(x, y, z float64) error) error {
+	buf := make([]byte, p.record)
+	for read := int64(0); read < p.Points; read++ {
+		if _, err := io.ReadFull(p.r, buf); err != nil {
+			return fmt.Errorf("点云数据不完整,已读取%d/%d个点", read, p.Points)
+		}
+		var xyz [3]float64
+		for i, field := range p.xyz {
+			xyz[i] = p.decode(buf[field.offset:field.offset+field.size], field)
+		}
+		if math.IsNaN(xyz[0]) || math.IsNaN(xyz[1]) || math.IsNaN(xyz[2]) {
+			continue
+		}
+		if err := fn(xyz[0], xyz[1], xyz[2]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode 按字段类型解码一个二进制标量
+func (p *PointCloudReader) decode(b []byte, field pointField) float64 {
+	switch field.kind {
+	case 'F':
+		if field.size == 8 {
+			return math.Float64frombits(p.order.Uint64(b))
+		}
+		return float64(math.Float32frombits(p.order.Uint32(b)))
+	case 'I':
+		switch field.size {
+		case 1:
+			return float64(int8(b[0]))
+		case 2:
+			return float64(int16(p.order.Uint16(b)))
+		case 4:
+			return float64(int32(p.order.Uint32(b)))
+		default:
+			return float64(int64(p.order.Uint64(b)))
+		}
+	default:
+		switch field.size {
+		case 1:
+			return float64(b[0])
+		case 2:
+			return float64(p.order.Uint16(b))
+		case 4:
+			return float64(p.order.Uint32(b))
+		default:
+			return float64(p.order.Uint64(b))
+		}
+	}
+}
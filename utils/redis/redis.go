@@ -30,6 +30,10 @@ var (
 	LockError   = fmt.Errorf("获取锁失败")
 	LockTimeOut = fmt.Errorf("获取锁超时")
 	UnLockError = fmt.Errorf("释放锁失败")
+	RenewError  = fmt.Errorf("续期锁失败")
+
+	// lockRetryInterval 锁被占用时的重试间隔
+	lockRetryInterval = 50 * time.Millisecond
 
 	ProcessOrder = map[string]int{
 		ProcessStart: 0,
@@ -65,18 +69,40 @@ type ProgressStruct struct {
 	Params    interface{} `json:"params"`
 }
 
-// LockWithTimeout 获取分布式锁
+// LockWithTimeout 获取分布式锁，锁被占用时持续重试直至timeout，返回本次加锁的uuid
 func LockWithTimeout(lockKey string, timeout time.Duration, lockTime time.Duration) (string, error) {
 	uuid := xid.New().String()
-	end := time.Now().Add(timeout).Unix()
-	for time.Now().Unix() <= end {
-		if result := RedisClient.SetNX(lockKey, uuid, lockTime).Val(); result {
-			return uuid, nil
-		} else {
+	end := time.Now().Add(timeout)
+	for {
+		result, err := RedisClient.SetNX(lockKey, uuid, lockTime).Result()
+		if err != nil {
+			logger.Error("LockWithTimeout 获取锁失败。lockKey:[%#v] err:[%v]", lockKey, err)
 			return "", LockError
 		}
+		if result {
+			return uuid, nil
+		}
+		if !time.Now().Before(end) {
+			return "", LockTimeOut
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// RenewLock 延长分布式锁的过期时间，仅锁的持有者(uuid一致)可以续期
+func RenewLock(lockKey string, uuid string, lockTime time.Duration) error {
+	script := `if redis.call('get', KEYS[1]) == ARGV[1] then
+					redis.call("pexpire", KEYS[1], ARGV[2])
+					return 1
+				else
+					return -1
+				end`
+	result, err := RedisClient.Eval(script, []string{lockKey}, uuid, lockTime.Milliseconds()).Int()
+	if err != nil || result == -1 {
+		logger.Error("RenewLock 续期锁失败。lockKey:[%#v] uuid:[%#v]", lockKey, uuid)
+		return RenewError
 	}
-	return "", LockTimeOut
+	return nil
 }
 
 // UnLock 释放分布式锁
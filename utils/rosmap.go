@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"image"
+	"io"
+)
+
+// RosMapMeta ROS map_server地图描述文件(map.yaml)
+type RosMapMeta struct {
+	Image          string    `yaml:"image"`           //图片相对路径
+	Mode           string    `yaml:"mode,omitempty"`  //trinary/scale/raw
+	Resolution     float64   `yaml:"resolution"`      //米/像素
+	Origin         []float64 `yaml:"origin,flow"`     //左下角像素在世界坐标系下的位姿[x,y,yaw]
+	Negate         int       `yaml:"negate"`          //0/1
+	OccupiedThresh float64   `yaml:"occupied_thresh"` //占用概率阈值
+	FreeThresh     float64   `yaml:"free_thresh"`     //空闲概率阈值
+}
+
+// ParseRosMapYAML 解析并校验ROS地图描述文件
+func ParseRosMapYAML(data []byte) (*RosMapMeta, error) {
+	var meta RosMapMeta
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	if meta.Image == "" {
+		return nil, fmt.Errorf("缺少image字段")
+	}
+	if meta.Resolution <= 0 {
+		return nil, fmt.Errorf("resolution[%v]必须大于0", meta.Resolution)
+	}
+	if len(meta.Origin) == 0 {
+		meta.Origin = []float64{0, 0, 0}
+	}
+	if len(meta.Origin) != 3 {
+		return nil, fmt.Errorf("origin须为[x,y,yaw]")
+	}
+	if meta.Negate != 0 && meta.Negate != 1 {
+		return nil, fmt.Errorf("negate[%d]只能为0或1", meta.Negate)
+	}
+	if meta.OccupiedThresh <= 0 || meta.OccupiedThresh > 1 || meta.FreeThresh < 0 || meta.FreeThresh >= meta.OccupiedThresh {
+		return nil, fmt.Errorf("occupied_thresh[%v]/free_thresh[%v]取值非法", meta.OccupiedThresh, meta.FreeThresh)
+	}
+	return &meta, nil
+}
+
+// MarshalRosMapYAML 生成ROS地图描述文件内容
+func MarshalRosMapYAML(meta RosMapMeta) ([]byte, error) {
+	return yaml.Marshal(meta)
+}
+
+// EncodePGM 将灰度图编码为P5格式pgm
+func EncodePGM(w io.Writer, img *image.Gray) error {
+	bw := bufio.NewWriter(w)
+	size := img.Rect.Size()
+	if _, err := fmt.Fprintf(bw, "P5\n%d %d\n255\n", size.X, size.Y); err != nil {
+		return err
+	}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		offset := img.PixOffset(img.Rect.Min.X, y)
+		if _, err := bw.Write(img.Pix[offset : offset+size.X]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestParseRosMapYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *RosMapMeta
+		wantErr bool
+	}{
+		{
+			name: "完整",
+			data: "image: map.pgm\nmode: trinary\nresolution: 0.05\norigin: [-10.0, -5.0, 0.0]\nnegate: 0\noccupied_thresh: 0.65\nfree_thresh: 0.196\n",
+			want: &RosMapMeta{Image: "map.pgm", Mode: "trinary", Resolution: 0.05, Origin: []float64{-10, -5, 0}, OccupiedThresh: 0.65, FreeThresh: 0.196},
+		},
+		{
+			name: "缺省origin",
+			data: "image: map.pgm\nresolution: 0.1\nnegate: 1\noccupied_thresh: 0.65\nfree_thresh: 0.2\n",
+			want: &RosMapMeta{Image: "map.pgm", Resolution: 0.1, Origin: []float64{0, 0, 0}, Negate: 1, OccupiedThresh: 0.65, FreeThresh: 0.2},
+		},
+		{name: "缺少image", data: "resolution: 0.05\noccupied_thresh: 0.65\nfree_thresh: 0.2\n", wantErr: true},
+		{name: "resolution非法", data: "image: map.pgm\nresolution: 0\noccupied_thresh: 0.65\nfree_thresh: 0.2\n", wantErr: true},
+		{name: "origin长度错误", data: "image: map.pgm\nresolution: 0.05\norigin: [1, 2]\noccupied_thresh: 0.65\nfree_thresh: 0.2\n", wantErr: true},
+		{name: "negate非法", data: "image: map.pgm\nresolution: 0.05\nnegate: 2\noccupied_thresh: 0.65\nfree_thresh: 0.2\n", wantErr: true},
+		{name: "阈值颠倒", data: "image: map.pgm\nresolution: 0.05\noccupied_thresh: 0.2\nfree_thresh: 0.65\n", wantErr: true},
+		{name: "yaml格式错误", data: "image: [", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRosMapYAML([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRosMapYAML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRosMapYAML() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodePGM(t *testing.T) {
+	tests := []struct {
+		name string
+		img  *image.Gray
+	}{
+		{"单像素", &image.Gray{Pix: []uint8{7}, Stride: 1, Rect: image.Rect(0, 0, 1, 1)}},
+		{"多行", &image.Gray{Pix: []uint8{0, 1, 2, 3, 4, 5}, Stride: 3, Rect: image.Rect(0, 0, 3, 2)}},
+		{"子图", image.NewGray(image.Rect(0, 0, 4, 4)).SubImage(image.Rect(1, 1, 3, 4)).(*image.Gray)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodePGM(&buf, tt.img); err != nil {
+				t.Fatalf("EncodePGM() error = %v", err)
+			}
+			decoded, err := DecodePGM(&buf)
+			if err != nil {
+				t.Fatalf("DecodePGM() error = %v", err)
+			}
+			size := tt.img.Rect.Size()
+			if decoded.Bounds() != image.Rect(0, 0, size.X, size.Y) {
+				t.Fatalf("尺寸 = %v, want %v", decoded.Bounds(), size)
+			}
+			for y := 0; y < size.Y; y++ {
+				for x := 0; x < size.X; x++ {
+					got := decoded.(*image.Gray).GrayAt(x, y)
+					want := tt.img.GrayAt(tt.img.Rect.Min.X+x, tt.img.Rect.Min.Y+y)
+					if got != want {
+						t.Errorf("像素(%d,%d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
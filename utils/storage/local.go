@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage 本地目录存储，对象经静态文件服务(gin或nginx)以urlPrefix+key访问
+type LocalStorage struct {
+	root      string
+	urlPrefix string
+}
+
+func NewLocalStorage(root, urlPrefix string) *LocalStorage {
+	return &LocalStorage{root: root, urlPrefix: urlPrefix}
+}
+
+// path key对应的本地路径，拒绝跳出存储目录的key
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" {
+		return "", errors.New("存储对象key为空")
+	}
+	return filepath.Join(s.root, filepath.FromSlash(strings.TrimPrefix(clean, "/"))), nil
+}
+
+func (s *LocalStorage) Put(key string, reader io.Reader, size int64) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免读到写了一半的对象
+	tmp := dst + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(f, reader, size)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (s *LocalStorage) PutFile(key string, src string) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if err = os.Rename(src, dst); err == nil {
+		return nil
+	}
+	// 跨文件系统时退化为复制
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	err = s.Put(key, f, info.Size())
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+func (s *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	return f, err
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return s.urlPrefix + strings.TrimPrefix(key, "/")
+}
+
+func (s *LocalStorage) Key(url string) (string, bool) {
+	if !strings.HasPrefix(url, s.urlPrefix) {
+		return "", false
+	}
+	key := strings.TrimPrefix(url, s.urlPrefix)
+	return key, key != ""
+}
@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"demo-gogo/config"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3DefaultRegion   = "us-east-1"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+
+	// 超过该大小的对象分片上传，单次PUT上限为5GB，分片数上限为10000
+	s3MultipartThreshold = 64 << 20
+	s3PartSize           = 16 << 20
+	s3MaxParts           = 10000
+)
+
+// S3Storage S3兼容的对象存储(如minio)，使用path-style地址与AWS SigV4签名，下载地址为预签名地址
+type S3Storage struct {
+	endpoint  string //scheme://host[:port]
+	bucket    string
+	accessKey string
+	secretKey string
+	region    string
+	expires   time.Duration
+	client    *http.Client
+}
+
+// NewS3Storage 按OSS配置创建S3存储，bucket不存在时自动创建
+func NewS3Storage(ossConf config.OSS) (*S3Storage, error) {
+	endpoint := strings.TrimSuffix(ossConf.Endpoint, "/")
+	if !strings.Contains(endpoint, "://") {
+		if ossConf.UseSSL {
+			endpoint = "https://" + endpoint
+		} else {
+			endpoint = "http://" + endpoint
+		}
+	}
+	if ossConf.Bucket == "" {
+		return nil, errors.New("未配置对象存储bucket")
+	}
+	s := &S3Storage{
+		endpoint:  endpoint,
+		bucket:    ossConf.Bucket,
+		accessKey: ossConf.User,
+		secretKey: ossConf.Password,
+		region:    ossConf.Region,
+		expires:   time.Duration(ossConf.SignExpires) * time.Second,
+		client:    newS3Client(),
+	}
+	if s.region == "" {
+		s.region = s3DefaultRegion
+	}
+	if s.expires <= 0 {
+		s.expires = time.Hour
+	}
+	if err := s.ensureBucket(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newS3Client 仅限制建立连接与等待响应头的时间，不限制整个请求耗时，大文件的上传与读取不会被中断
+func newS3Client() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ResponseHeaderTimeout = time.Minute
+	return &http.Client{Transport: transport}
+}
+
+// objectURL 对象的path-style地址，key按段转义
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u, _ := url.Parse(s.endpoint)
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i, v := range segments {
+		segments[i] = s3Escape(v)
+	}
+	u.Path = "/" + s.bucket
+	u.RawPath = "/" + s3Escape(s.bucket)
+	if key != "" {
+		u.Path += "/" + strings.TrimPrefix(key, "/")
+		u.RawPath += "/" + strings.Join(segments, "/")
+	}
+	return u
+}
+
+func (s *S3Storage) ensureBucket() error {
+	resp, err := s.do(http.MethodHead, "", nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("检查bucket[%s]失败,状态码:%d", s.bucket, resp.StatusCode)
+	}
+	resp, err = s.do(http.MethodPut, "", nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	return s3CheckResponse(resp, http.StatusOK)
+}
+
+// Put 上传对象，超过s3MultipartThreshold时分片上传
+func (s *S3Storage) Put(key string, reader io.Reader, size int64) error {
+	if size > s3MultipartThreshold {
+		return s.putMultipart(key, reader, size)
+	}
+	resp, err := s.do(http.MethodPut, key, nil, reader, size)
+	if err != nil {
+		return err
+	}
+	return s3CheckResponse(resp, http.StatusOK)
+}
+
+type s3InitiateResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type s3CompletePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteUpload struct {
+	XMLName xml.Name         `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletePart `xml:"Part"`
+}
+
+// putMultipart 按顺序从reader读取各分片上传，失败时中止分片上传以释放已上传的分片
+func (s *S3Storage) putMultipart(key string, reader io.Reader, size int64) error {
+	partSize := int64(s3PartSize)
+	if (size+partSize-1)/partSize > s3MaxParts {
+		partSize = (size + s3MaxParts - 1) / s3MaxParts
+	}
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s3CheckResponse(resp, http.StatusOK)
+	}
+	var initiate s3InitiateResult
+	err = xml.NewDecoder(resp.Body).Decode(&initiate)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("对象存储分片上传初始化响应解析失败:%v", err)
+	}
+	err = s.uploadParts(key, initiate.UploadID, reader, size, partSize)
+	if err != nil {
+		if resp, abortErr := s.do(http.MethodDelete, key, url.Values{"uploadId": {initiate.UploadID}}, nil, 0); abortErr == nil {
+			_ = s3CheckResponse(resp, http.StatusNoContent)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *S3Storage) uploadParts(key, uploadID string, reader io.Reader, size, partSize int64) error {
+	complete := s3CompleteUpload{}
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+partSize, number+1 {
+		length := partSize
+		if size-offset < length {
+			length = size - offset
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(http.MethodPut, key, query, io.LimitReader(reader, length), length)
+		if err != nil {
+			return err
+		}
+		etag := resp.Header.Get("ETag")
+		if err = s3CheckResponse(resp, http.StatusOK); err != nil {
+			return err
+		}
+		complete.Parts = append(complete.Parts, s3CompletePart{PartNumber: number, ETag: etag})
+	}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, strings.NewReader(string(body)), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s3CheckResponse(resp, http.StatusOK)
+	}
+	//合并失败时状态码仍可能为200，需检查响应体是否为错误
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("对象存储分片合并响应解析失败:%v", err)
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("对象存储分片合并失败,%s:%s", result.Code, result.Message)
+	}
+	return nil
+}
+
+func (s *S3Storage) PutFile(key string, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	err = s.Put(key, f, info.Size())
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (s *S3Storage) Open(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil, ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3CheckResponse(resp, http.StatusOK)
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil
+	}
+	return s3CheckResponse(resp, http.StatusNoContent, http.StatusOK)
+}
+
+// URL 对象的预签名下载地址，有效期为配置的sign_expires
+func (s *S3Storage) URL(key string) string {
+	u := s.objectURL(key)
+	now := time.Now().UTC()
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(s.expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = s3CanonicalQuery(query)
+	canonical := strings.Join([]string{
+		http.MethodGet, u.EscapedPath(), u.RawQuery,
+		"host:" + u.Host + "\n", "host", s3UnsignedPayload,
+	}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + s.signature(now, canonical)
+	return u.String()
+}
+
+// Key 去掉地址中的签名参数后按path-style还原key
+func (s *S3Storage) Key(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	base := s.objectURL("")
+	if u.Host != base.Host || !strings.HasPrefix(u.Path, base.Path+"/") {
+		return "", false
+	}
+	key := strings.TrimPrefix(u.Path, base.Path+"/")
+	return key, key != ""
+}
+
+// do 发送带SigV4签名头的请求，请求体不参与签名
+func (s *S3Storage) do(method, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	u := s.objectURL(key)
+	u.RawQuery = s3CanonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	now := time.Now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		method, u.EscapedPath(), u.RawQuery,
+		"host:" + u.Host + "\nx-amz-content-sha256:" + s3UnsignedPayload + "\nx-amz-date:" + now.Format(s3TimeFormat) + "\n",
+		signedHeaders, s3UnsignedPayload,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, s.scope(now), signedHeaders, s.signature(now, canonical)))
+	return s.client.Do(req)
+}
+
+func (s *S3Storage) scope(t time.Time) string {
+	return t.Format(s3DateFormat) + "/" + s.region + "/s3/aws4_request"
+}
+
+func (s *S3Storage) signature(t time.Time, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", t.Format(s3TimeFormat), s.scope(t), hex.EncodeToString(hash[:]),
+	}, "\n")
+	key := s3HMAC([]byte("AWS4"+s.secretKey), t.Format(s3DateFormat))
+	key = s3HMAC(key, s.region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+	return hex.EncodeToString(s3HMAC(key, stringToSign))
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape 按SigV4要求转义，仅保留A-Za-z0-9-_.~
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, s3Escape(k)+"="+s3Escape(query.Get(k)))
+	}
+	return strings.Join(pairs, "&")
+}
+
+func s3CheckResponse(resp *http.Response, codes ...int) error {
+	defer resp.Body.Close()
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("对象存储请求失败,状态码:%d,%s", resp.StatusCode, msg)
+}
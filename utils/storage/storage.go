@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"demo-gogo/config"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/wonderivan/logger"
+)
+
+// ErrNotExist 对象不存在
+var ErrNotExist = errors.New("存储对象不存在")
+
+// Storage 对象存储，地图图片、点云等文件统一经此读写。
+// key为存储内以/分隔的相对路径(如maps/xxx.png)，数据库中只保存key，访问地址由URL按需生成
+type Storage interface {
+	// Put 写入对象，size为数据长度
+	Put(key string, reader io.Reader, size int64) error
+	// PutFile 将本地文件写入对象，成功后本地文件被移除
+	PutFile(key string, path string) error
+	// Open 读取对象，对象不存在时返回ErrNotExist
+	Open(key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(key string) error
+	// URL 对象的访问地址，私有存储返回带有效期的签名地址
+	URL(key string) string
+	// Key 将本存储生成的访问地址还原为key，不属于本存储时返回false
+	Key(url string) (string, bool)
+}
+
+// Default 按配置初始化的全局存储，未初始化时为nil
+var Default Storage
+
+// InitStorage 按OSS配置初始化存储：minio为S3兼容存储，nginx为nginx代理的本地目录，其余为gin静态映射的上传目录
+func InitStorage() error {
+	ossConf := config.Conf.OSS
+	switch ossConf.Type {
+	case config.CONF_OSS_MINIO:
+		s3, err := NewS3Storage(ossConf)
+		if err != nil {
+			return err
+		}
+		Default = s3
+	case config.CONF_OSS_NGINX:
+		Default = NewLocalStorage(ossConf.FileSavePath, strings.TrimSuffix(ossConf.Endpoint, "/")+"/")
+	default:
+		root := config.Conf.APP.UploadBasePath
+		Default = NewLocalStorage(root, "/"+strings.TrimPrefix(strings.TrimSuffix(root, "/"), "./")+"/")
+	}
+	logger.Info("文件存储初始化完成,类型:[%s]", ossConf.Type)
+	return nil
+}
+
+// IsKey 是否为存储key，完整地址与绝对路径不是key
+func IsKey(value string) bool {
+	return value != "" && !strings.HasPrefix(value, "/") && !strings.Contains(value, "://")
+}
+
+// Resolve 将存储key或本存储生成的访问地址还原为key。
+// 外部地址、本地路径以及含..路径段的key不属于本存储，返回false
+func Resolve(value string) (string, bool) {
+	if Default == nil || value == "" {
+		return "", false
+	}
+	key := value
+	if !IsKey(key) {
+		var ok bool
+		if key, ok = Default.Key(value); !ok {
+			return "", false
+		}
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+	return key, IsKey(key)
+}
+
+// URL 存储key转为访问地址，其它取值(历史数据中的完整地址等)原样返回
+func URL(value string) string {
+	if Default == nil || !IsKey(value) {
+		return value
+	}
+	return Default.URL(value)
+}
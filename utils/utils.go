@@ -11,7 +11,6 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -358,14 +357,7 @@ func GetDifference(old, new interface{}) string {
 		}
 		val1 := String(valInfo1.Field(i).Interface())
 		val2 := String(valInfo2.Field(i).Interface())
-		tmp1, tmp2 := []byte(val1), []byte(val2)
-		sort.Slice(tmp1, func(i, j int) bool {
-			return tmp1[i] < tmp1[j]
-		})
-		sort.Slice(tmp2, func(i, j int) bool {
-			return tmp2[i] < tmp2[j]
-		})
-		if string(tmp1) != string(tmp2) { //记录改变的属性
+		if val1 != val2 { //记录改变的属性
 			tmp := fmt.Sprintf("%s:%v -> %v;", key, val1, val2)
 			operationLog = operationLog + tmp
 		}